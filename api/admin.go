@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"im/core/auth"
+	"im/core/protocol"
+	pb "im/core/protocol/pb"
+	"io/ioutil"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 授予或收回系统角色
+func SetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.SetRoleReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid := identityFrom(r).UID
+	if !authorize(w, uid, auth.ActionRoleAssign, "没有权限设置角色") {
+		return
+	}
+	if !auth.IsSystemRole(req.Role) {
+		writeResp(w, 1, "不支持的角色", nil)
+		return
+	}
+	if _, err := storageManager.GetUserByUID(req.TargetUid); err != nil {
+		writeResp(w, 1, "用户不存在", nil)
+		return
+	}
+	// 不允许收回自己的管理员角色，避免系统中没有管理员
+	if !req.Grant && req.TargetUid == uid && req.Role == auth.RoleAdmin {
+		writeResp(w, 1, "不能收回自己的管理员角色", nil)
+		return
+	}
+	if req.Grant {
+		err = Authorizer.GrantRole(req.TargetUid, req.Role)
+	} else {
+		err = Authorizer.RevokeRole(req.TargetUid, req.Role)
+	}
+	if err != nil {
+		writeResp(w, 1, "设置角色失败", nil)
+		return
+	}
+	writeResp(w, 0, "设置成功", nil)
+}
+
+// 删除已上传的文件，用于处理违规内容
+func DeleteFileHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.DeleteFileReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	if !authorize(w, identityFrom(r).UID, auth.ActionFileDelete, "没有权限删除文件") {
+		return
+	}
+	if err := fileService.DeleteFile(req.Filename); err != nil {
+		writeResp(w, 1, err.Error(), nil)
+		return
+	}
+	writeResp(w, 0, "文件已删除", nil)
+}
+
+// 监控接口：以JSON返回发送队列状态，只返回统计数据，不包含在线用户
+func QueueStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, identityFrom(r).UID, auth.ActionStatsView, "没有权限查看监控数据") {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.GetQueueStats())
+}
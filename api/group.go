@@ -0,0 +1,472 @@
+package api
+
+import (
+	"fmt"
+	pb "im/core/protocol/pb"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"im/core/auth"
+	"im/core/protocol"
+	"im/core/storage"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const maxGroupNameLen = 64 // 群名称最大长度
+
+// 群角色等级，用于判断管理权限
+var groupRoleRank = map[string]int{
+	storage.GroupRoleMember: 1,
+	storage.GroupRoleAdmin:  2,
+	storage.GroupRoleOwner:  3,
+}
+
+// 操作者是否可以管理目标成员：至少是管理员，且角色高于目标
+func canManageMember(operatorRole, targetRole string) bool {
+	return groupRoleRank[operatorRole] >= groupRoleRank[storage.GroupRoleAdmin] &&
+		groupRoleRank[operatorRole] > groupRoleRank[targetRole]
+}
+
+// 推送群事件通知给指定成员
+func notifyGroupMembers(groupID int64, uids []string, notifType, from, content string) {
+	for _, uid := range uids {
+		if uid == from {
+			continue
+		}
+		notif := &pb.Notification{
+			Type:      notifType,
+			From:      from,
+			To:        uid,
+			Content:   content,
+			Timestamp: time.Now().Unix(),
+			Extra:     fmt.Sprintf("%d", groupID),
+		}
+		_ = protocol.SendNotificationToUser(uid, notif)
+	}
+}
+
+// 获取全部群成员UID
+func groupMemberUIDs(groupID int64) []string {
+	members, err := storageManager.GetGroupMembers(groupID)
+	if err != nil {
+		return nil
+	}
+	var uids []string
+	for _, m := range members {
+		uids = append(uids, m.UserID)
+	}
+	return uids
+}
+
+// 创建群
+func CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.CreateGroupReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if !authorize(w, req.Uid, auth.ActionGroupCreate, "没有权限创建群") {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeResp(w, 1, "群名称不能为空", nil)
+		return
+	}
+	if len([]rune(req.Name)) > maxGroupNameLen {
+		writeResp(w, 1, "群名称过长", nil)
+		return
+	}
+	var members []string
+	for _, uid := range req.MemberUids {
+		if uid == req.Uid {
+			continue
+		}
+		if _, err := storageManager.GetUserByUID(uid); err != nil {
+			writeResp(w, 1, fmt.Sprintf("用户%s不存在", uid), nil)
+			return
+		}
+		members = append(members, uid)
+	}
+	groupID, err := idGenerator.NextGroupID()
+	if err == nil {
+		groupID, err = storageManager.CreateGroup(groupID, req.Name, req.Uid, members)
+	}
+	if err != nil {
+		writeResp(w, 1, "创建群失败", nil)
+		return
+	}
+	notifyGroupMembers(groupID, members, "group_invite", req.Uid, req.Name)
+	resp := &pb.CreateGroupResp{GroupId: groupID, Code: 0, Msg: "创建成功"}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
+
+// 解散群
+func DissolveGroupHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.DissolveGroupReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.GroupId == 0 {
+		writeResp(w, 1, "缺少UID或群ID", nil)
+		return
+	}
+	group, err := storageManager.GetGroup(req.GroupId)
+	if err != nil {
+		writeResp(w, 1, "群不存在", nil)
+		return
+	}
+	if !authorize(w, req.Uid, auth.GroupAction(auth.ActionGroupDissolve, req.GroupId), "只有群主可以解散群") {
+		return
+	}
+	uids := groupMemberUIDs(req.GroupId)
+	if err := storageManager.DeleteGroup(req.GroupId); err != nil {
+		writeResp(w, 1, "解散群失败", nil)
+		return
+	}
+	notifyGroupMembers(req.GroupId, uids, "group_dissolved", req.Uid, group.Name)
+	resp := &pb.DissolveGroupResp{Code: 0, Msg: "群已解散"}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
+
+// 邀请成员入群
+func InviteGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.InviteGroupMemberReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.GroupId == 0 || len(req.MemberUids) == 0 {
+		writeResp(w, 1, "缺少UID或群ID", nil)
+		return
+	}
+	group, err := storageManager.GetGroup(req.GroupId)
+	if err != nil {
+		writeResp(w, 1, "群不存在", nil)
+		return
+	}
+	if !authorize(w, req.Uid, auth.GroupAction(auth.ActionGroupInvite, req.GroupId), "没有权限邀请成员") {
+		return
+	}
+	var invited []string
+	for _, uid := range req.MemberUids {
+		if _, err := storageManager.GetUserByUID(uid); err != nil {
+			writeResp(w, 1, fmt.Sprintf("用户%s不存在", uid), nil)
+			return
+		}
+		if _, err := storageManager.GetGroupMember(req.GroupId, uid); err == nil {
+			continue
+		}
+		if err := storageManager.AddGroupMember(req.GroupId, uid, storage.GroupRoleMember); err != nil {
+			writeResp(w, 1, "邀请失败", nil)
+			return
+		}
+		invited = append(invited, uid)
+	}
+	notifyGroupMembers(req.GroupId, invited, "group_invite", req.Uid, group.Name)
+	resp := &pb.InviteGroupMemberResp{Code: 0, Msg: fmt.Sprintf("已邀请%d人入群", len(invited))}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
+
+// 移除群成员
+func RemoveGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.RemoveGroupMemberReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.GroupId == 0 || req.MemberUid == "" {
+		writeResp(w, 1, "缺少UID或群ID", nil)
+		return
+	}
+	group, err := storageManager.GetGroup(req.GroupId)
+	if err != nil {
+		writeResp(w, 1, "群不存在", nil)
+		return
+	}
+	if !authorize(w, req.Uid, auth.GroupAction(auth.ActionGroupKick, req.GroupId), "没有权限移除该成员") {
+		return
+	}
+	target, err := storageManager.GetGroupMember(req.GroupId, req.MemberUid)
+	if err != nil {
+		writeResp(w, 1, "对方不是群成员", nil)
+		return
+	}
+	if target.Role == storage.GroupRoleOwner {
+		writeResp(w, 1, "不能移除群主", nil)
+		return
+	}
+	// 群内权限只能管理角色低于自己的成员，审核员和系统管理员不受此限制
+	if !Authorizer.Authorize(req.Uid, auth.ActionGroupKick) {
+		operator, err := storageManager.GetGroupMember(req.GroupId, req.Uid)
+		if err != nil || !canManageMember(operator.Role, target.Role) {
+			writeResp(w, codeForbidden, "没有权限移除该成员", nil)
+			return
+		}
+	}
+	if err := storageManager.RemoveGroupMember(req.GroupId, req.MemberUid); err != nil {
+		writeResp(w, 1, "移除成员失败", nil)
+		return
+	}
+	notifyGroupMembers(req.GroupId, []string{req.MemberUid}, "group_removed", req.Uid, group.Name)
+	resp := &pb.RemoveGroupMemberResp{Code: 0, Msg: "已移除"}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
+
+// 退出群
+func LeaveGroupHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.LeaveGroupReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.GroupId == 0 {
+		writeResp(w, 1, "缺少UID或群ID", nil)
+		return
+	}
+	member, err := storageManager.GetGroupMember(req.GroupId, req.Uid)
+	if err != nil {
+		writeResp(w, 1, "不是群成员", nil)
+		return
+	}
+	if member.Role == storage.GroupRoleOwner {
+		writeResp(w, 1, "群主不能退出群，请先解散群", nil)
+		return
+	}
+	if err := storageManager.RemoveGroupMember(req.GroupId, req.Uid); err != nil {
+		writeResp(w, 1, "退出群失败", nil)
+		return
+	}
+	resp := &pb.LeaveGroupResp{Code: 0, Msg: "已退出群"}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
+
+// 修改群名称
+func RenameGroupHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.RenameGroupReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	req.Name = strings.TrimSpace(req.Name)
+	if req.GroupId == 0 || req.Name == "" {
+		writeResp(w, 1, "缺少群ID或群名称", nil)
+		return
+	}
+	if len([]rune(req.Name)) > maxGroupNameLen {
+		writeResp(w, 1, "群名称过长", nil)
+		return
+	}
+	if !authorize(w, req.Uid, auth.GroupAction(auth.ActionGroupRename, req.GroupId), "只有群主或管理员可以修改群名称") {
+		return
+	}
+	if err := storageManager.RenameGroup(req.GroupId, req.Name); err != nil {
+		writeResp(w, 1, "修改群名称失败", nil)
+		return
+	}
+	notifyGroupMembers(req.GroupId, groupMemberUIDs(req.GroupId), "group_renamed", req.Uid, req.Name)
+	resp := &pb.RenameGroupResp{Code: 0, Msg: "群名称已修改"}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
+
+// 设置或取消管理员
+func SetGroupAdminHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.SetGroupAdminReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.GroupId == 0 || req.MemberUid == "" {
+		writeResp(w, 1, "缺少UID或群ID", nil)
+		return
+	}
+	group, err := storageManager.GetGroup(req.GroupId)
+	if err != nil {
+		writeResp(w, 1, "群不存在", nil)
+		return
+	}
+	if !authorize(w, req.Uid, auth.GroupAction(auth.ActionGroupSetAdmin, req.GroupId), "只有群主可以设置管理员") {
+		return
+	}
+	if req.MemberUid == group.OwnerID {
+		writeResp(w, 1, "不能修改群主的角色", nil)
+		return
+	}
+	if _, err := storageManager.GetGroupMember(req.GroupId, req.MemberUid); err != nil {
+		writeResp(w, 1, "对方不是群成员", nil)
+		return
+	}
+	role := storage.GroupRoleMember
+	if req.Admin {
+		role = storage.GroupRoleAdmin
+	}
+	if err := storageManager.SetGroupMemberRole(req.GroupId, req.MemberUid, role); err != nil {
+		writeResp(w, 1, "设置管理员失败", nil)
+		return
+	}
+	resp := &pb.SetGroupAdminResp{Code: 0, Msg: "设置成功"}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
+
+// 获取已加入的群列表
+func GroupListHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.GroupListReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	groups, err := storageManager.GetUserGroups(req.Uid)
+	if err != nil {
+		writeResp(w, 1, "获取群列表失败", nil)
+		return
+	}
+	unread, err := storageManager.GetGroupUnreadCounts(req.Uid)
+	if err != nil {
+		unread = map[int64]int64{}
+	}
+	resp := &pb.GroupListResp{Code: 0, Msg: "ok"}
+	for _, g := range groups {
+		info := &pb.GroupInfo{GroupId: g.ID, Name: g.Name, OwnerUid: g.OwnerID}
+		if member, err := storageManager.GetGroupMember(g.ID, req.Uid); err == nil {
+			info.Role = member.Role
+		}
+		info.MemberCount = int32(len(groupMemberUIDs(g.ID)))
+		info.UnreadCount = unread[g.ID]
+		resp.Groups = append(resp.Groups, info)
+	}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
+
+// 获取群成员列表
+func GroupMembersHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.GroupMembersReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.GroupId == 0 {
+		writeResp(w, 1, "缺少UID或群ID", nil)
+		return
+	}
+	if _, err := storageManager.GetGroupMember(req.GroupId, req.Uid); err != nil {
+		writeResp(w, 1, "不是群成员", nil)
+		return
+	}
+	members, err := storageManager.GetGroupMembers(req.GroupId)
+	if err != nil {
+		writeResp(w, 1, "获取群成员失败", nil)
+		return
+	}
+	resp := &pb.GroupMembersResp{Code: 0, Msg: "ok"}
+	for _, m := range members {
+		info := &pb.GroupMemberInfo{Uid: m.UserID, Role: m.Role, Username: "<未知>"}
+		if user, err := storageManager.GetUserByUID(m.UserID); err == nil {
+			info.Username = user.Username
+		}
+		resp.Members = append(resp.Members, info)
+	}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
@@ -2,11 +2,14 @@ package api
 
 import (
 	"fmt"
+	"im/config"
 	"im/core/auth"
 	pb "im/core/protocol/pb"
 	pbuser "im/core/protocol/pb"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/mail"
 
 	"im/core/protocol"
 	"im/core/service"
@@ -19,7 +22,17 @@ import (
 
 var storageManager = storage.GetStorageManager()
 var fileService = service.NewFileService()
-var nextUID = 1
+var userStore auth.UserStore = auth.NewDBUserStore(storageManager)
+var verifyService = service.NewVerifyService()
+
+var loginGuard = service.NewLoginGuard()
+var idGenerator = service.GetIDGenerator()
+
+const (
+	defaultHistoryLimit = 20  // 聊天记录默认每页条数
+	maxHistoryLimit     = 100 // 聊天记录每页最大条数
+	maxPasswordLen      = 72  // bcrypt 最多使用密码的前72字节
+)
 
 func writeResp(w http.ResponseWriter, code int, msg string, data []byte) {
 	w.Header().Set("Content-Type", "application/x-protobuf")
@@ -39,13 +52,31 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1001, "请求格式错误", nil)
 		return
 	}
-	if len(req.Password) < 3 || len(req.Email) < 5 {
-		writeResp(w, 1002, "密码或邮箱长度不合法", nil)
+	if len(req.Password) < 3 || len(req.Password) > maxPasswordLen || !validEmail(req.Email) {
+		writeResp(w, 1002, "密码长度或邮箱格式不合法", nil)
+		return
+	}
+	// 在消耗验证码之前检查自选UID，避免UID不可用时验证码作废
+	if req.Uid != "" {
+		if err := idGenerator.CheckVanityUID(req.Uid); err != nil {
+			writeResp(w, 1002, err.Error(), nil)
+			return
+		}
+	}
+	// 校验发送到注册邮箱的验证码，确认邮箱归注册者所有
+	if err := verifyService.VerifyCode(req.Email, service.PurposeRegister, req.Code); err != nil {
+		writeResp(w, 1003, err.Error(), nil)
 		return
 	}
-	uid := fmt.Sprintf("%d", nextUID)
-	nextUID++
-	err = storageManager.CreateUser(uid, req.Username, req.Password, req.Email)
+	uid := req.Uid
+	if uid == "" {
+		if uid, err = idGenerator.NextUID(); err != nil {
+			fmt.Printf("分配UID失败: %v\n", err)
+			writeResp(w, 1004, "分配UID失败", nil)
+			return
+		}
+	}
+	_, err = userStore.Register(uid, req.Username, req.Password, req.Email)
 	if err != nil {
 		writeResp(w, 1004, err.Error(), nil)
 		return
@@ -68,27 +99,78 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 2002, "UID和密码不能为空", nil)
 		return
 	}
-	if onlineAccounts[req.Uid] {
-		writeResp(w, 2003, "该账号已在其他地方登录", nil)
+	ip := clientIP(r)
+	if err := loginGuard.Check(req.Uid, ip); err != nil {
+		if _, ok := err.(*service.LoginBlockedError); ok {
+			writeResp(w, 2005, err.Error(), nil)
+			return
+		}
+		writeResp(w, 2006, "服务器错误", nil)
+		return
+	}
+	user, err := userStore.Login(req.Uid, req.Password)
+	if err != nil {
+		loginGuard.Fail(req.Uid, ip, "密码错误")
+		writeResp(w, 2004, err.Error(), nil)
 		return
 	}
-	user, err := storageManager.GetUserByUID(req.Uid)
+	// 开启两步验证的账号先返回挑战，验证码通过后再签发令牌
+	enabled, err := auth.TOTPEnabled(user.UID)
 	if err != nil {
-		writeResp(w, 2004, "用户不存在", nil)
+		writeResp(w, 2006, "查询两步验证状态失败", nil)
 		return
 	}
-	// 验证密码（这里需要实现密码验证逻辑）
-	if user.Password != req.Password {
-		writeResp(w, 2004, "密码错误", nil)
+	if enabled {
+		challenge, err := auth.NewLoginChallenge(user.UID)
+		if err != nil {
+			writeResp(w, 2006, "生成登录验证失败", nil)
+			return
+		}
+		loginGuard.Challenge(user.UID, ip)
+		data, _ := proto.Marshal(&pb.LoginResp{Challenge: challenge})
+		writeResp(w, 2007, "需要两步验证", data)
 		return
 	}
-	onlineAccounts[req.Uid] = true
-	token, err := auth.GenerateToken(user.UID)
+	pair, err := auth.IssueTokens(user.UID)
 	if err != nil {
 		writeResp(w, 2006, "生成token失败", nil)
 		return
 	}
-	writeResp(w, 0, "登录成功", []byte(token))
+	loginGuard.Succeed(user.UID, ip)
+	writeResp(w, 0, "登录成功", marshalTokenPair(pair))
+}
+
+func marshalTokenPair(pair *auth.TokenPair) []byte {
+	data, _ := proto.Marshal(&pb.LoginResp{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+	return data
+}
+
+// 使用刷新令牌换取新的访问令牌和刷新令牌
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.RefreshTokenReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	if req.RefreshToken == "" {
+		writeResp(w, 1, "缺少refresh token", nil)
+		return
+	}
+	pair, err := auth.RefreshTokens(req.RefreshToken)
+	if err != nil {
+		writeResp(w, 1, err.Error(), nil)
+		return
+	}
+	writeResp(w, 0, "刷新成功", marshalTokenPair(pair))
 }
 
 func ResetPwdHandler(w http.ResponseWriter, r *http.Request) {
@@ -102,27 +184,30 @@ func ResetPwdHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Email == "" || req.NewPwd == "" {
-		writeResp(w, 1, "邮箱和新密码不能为空", nil)
+	if req.Email == "" || req.NewPwd == "" || req.Code == "" {
+		writeResp(w, 1, "邮箱、新密码和验证码不能为空", nil)
 		return
 	}
-	// 发送验证码（模拟）
-	// 实际应保存验证码并校验
-	fmt.Printf("向 %s 发送验证码: 123456 (模拟)\n", req.Email)
-	if req.Code != "123456" {
-		writeResp(w, 1, "验证码错误(模拟)", nil)
+	if len(req.NewPwd) < 3 || len(req.NewPwd) > maxPasswordLen {
+		writeResp(w, 1, "新密码长度不合法", nil)
 		return
 	}
-	user, err := storageManager.GetUserByEmail(req.Email)
-	if err != nil {
-		writeResp(w, 1, "用户不存在", nil)
+	if err := verifyService.VerifyCode(req.Email, service.PurposeResetPwd, req.Code); err != nil {
+		writeResp(w, 1, err.Error(), nil)
 		return
 	}
-	err = storageManager.UpdatePassword(user.UID, req.NewPwd)
+	err = userStore.ResetPasswordByEmail(req.Email, req.NewPwd)
 	if err != nil {
 		writeResp(w, 1, err.Error(), nil)
 		return
 	}
+	// 密码重置后此前签发的令牌全部失效
+	if user, err := userStore.GetByEmail(req.Email); err == nil {
+		if err := auth.RevokeUserTokens(user.UID); err != nil {
+			writeResp(w, 1, "吊销旧令牌失败", nil)
+			return
+		}
+	}
 	writeResp(w, 0, "密码重置成功", nil)
 }
 
@@ -137,8 +222,13 @@ func UpdateUsernameHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Uid == "" || req.NewUsername == "" {
-		writeResp(w, 1, "UID和新昵称不能为空", nil)
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.NewUsername == "" {
+		writeResp(w, 1, "新昵称不能为空", nil)
 		return
 	}
 	err = storageManager.UpdateUsername(req.Uid, req.NewUsername)
@@ -160,25 +250,30 @@ func UpdatePwdHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Uid == "" || req.OldPwd == "" || req.NewPwd == "" {
-		writeResp(w, 1, "UID、原密码和新密码不能为空", nil)
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
 		return
 	}
-	// 先验证旧密码
-	user, err := storageManager.GetUserByUID(req.Uid)
-	if err != nil {
-		writeResp(w, 1, "用户不存在", nil)
+	req.Uid = uid
+	if req.OldPwd == "" || req.NewPwd == "" {
+		writeResp(w, 1, "原密码和新密码不能为空", nil)
 		return
 	}
-	if user.Password != req.OldPwd {
-		writeResp(w, 1, "原密码错误", nil)
+	if len(req.NewPwd) < 3 || len(req.NewPwd) > maxPasswordLen {
+		writeResp(w, 1, "新密码长度不合法", nil)
 		return
 	}
-	err = storageManager.UpdatePassword(req.Uid, req.NewPwd)
+	// 校验旧密码后以bcrypt保存新密码
+	err = userStore.UpdatePassword(req.Uid, req.OldPwd, req.NewPwd)
 	if err != nil {
 		writeResp(w, 1, err.Error(), nil)
 		return
 	}
+	// 修改密码后需要重新登录
+	if err := auth.RevokeUserTokens(req.Uid); err != nil {
+		writeResp(w, 1, "吊销旧令牌失败", nil)
+		return
+	}
 	writeResp(w, 0, "密码修改成功", nil)
 }
 
@@ -193,16 +288,7 @@ func TokenCheckHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Token == "" {
-		writeResp(w, 1, "缺少token", nil)
-		return
-	}
-	userID, err := auth.ParseToken(req.Token)
-	if err != nil {
-		writeResp(w, 1, "token无效", nil)
-		return
-	}
-	writeResp(w, 0, "token有效", []byte(userID))
+	writeResp(w, 0, "token有效", []byte(identityFrom(r).UID))
 }
 
 func DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
@@ -216,15 +302,19 @@ func DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Uid == "" {
-		writeResp(w, 1, "UID不能为空", nil)
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
 		return
 	}
+	req.Uid = uid
 	err = storageManager.DeleteUser(req.Uid)
 	if err != nil {
 		writeResp(w, 1, err.Error(), nil)
 		return
 	}
+	if err := auth.RevokeUserTokens(req.Uid); err != nil {
+		fmt.Printf("吊销用户 %s 的令牌失败: %v\n", req.Uid, err)
+	}
 	writeResp(w, 0, "账号已注销", nil)
 }
 
@@ -239,16 +329,7 @@ func UserInfoHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Token == "" {
-		writeResp(w, 1, "缺少token", nil)
-		return
-	}
-	uid, err := auth.ParseToken(req.Token)
-	if err != nil {
-		writeResp(w, 1, "token无效", nil)
-		return
-	}
-	user, err := storageManager.GetUserByUID(uid)
+	user, err := storageManager.GetUserByUID(identityFrom(r).UID)
 	if err != nil {
 		writeResp(w, 1, err.Error(), nil)
 		return
@@ -264,9 +345,6 @@ func UserInfoHandler(w http.ResponseWriter, r *http.Request) {
 	writeResp(w, 0, "ok", data)
 }
 
-// 在线账号管理
-var onlineAccounts = make(map[string]bool)
-
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -278,20 +356,31 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Token == "" {
-		writeResp(w, 1, "缺少token", nil)
+	// 吊销本次登录的访问令牌和刷新令牌，并断开其长连接
+	id := identityFrom(r)
+	if err := auth.RevokeSession(id.UID, id.SessionID); err != nil {
+		writeResp(w, 1, "登出失败", nil)
 		return
 	}
-	userID, err := auth.ParseToken(req.Token)
+	writeResp(w, 0, "已登出", nil)
+}
+
+// 邮箱格式校验
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email && len(email) <= 128
+}
+
+// 客户端IP，用于验证码发送频率限制
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		writeResp(w, 1, "token无效", nil)
-		return
+		return r.RemoteAddr
 	}
-	delete(onlineAccounts, userID)
-	writeResp(w, 0, "已登出", nil)
+	return host
 }
 
-// 邮箱验证码功能预留接口（实际应集成邮件服务）
+// 发送邮箱验证码
 func SendEmailCodeHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -303,12 +392,85 @@ func SendEmailCodeHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Email == "" {
-		writeResp(w, 1, "邮箱不能为空", nil)
+	if !validEmail(req.Email) {
+		writeResp(w, 1, "邮箱格式不合法", nil)
+		return
+	}
+	if !service.IsValidPurpose(req.Purpose) {
+		writeResp(w, 1, "验证码用途不合法", nil)
+		return
+	}
+	_, lookupErr := userStore.GetByEmail(req.Email)
+	registered := lookupErr == nil
+	switch req.Purpose {
+	case service.PurposeChangeEmail:
+		if _, ok := callerUID(w, r, req.Uid); !ok {
+			return
+		}
+		fallthrough
+	case service.PurposeRegister:
+		if registered {
+			writeResp(w, 1, "邮箱已被注册", nil)
+			return
+		}
+	case service.PurposeResetPwd:
+		// 未注册的邮箱同样返回成功，避免被用来探测邮箱是否注册
+		if !registered {
+			writeResp(w, 0, "验证码已发送", nil)
+			return
+		}
+	}
+	if err := verifyService.SendCode(req.Email, req.Purpose, clientIP(r)); err != nil {
+		writeResp(w, 1, err.Error(), nil)
+		return
+	}
+	writeResp(w, 0, "验证码已发送", nil)
+}
+
+// 修改绑定邮箱，需要发送到新邮箱的验证码
+func UpdateEmailHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.UpdateEmailReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if !validEmail(req.NewEmail) {
+		writeResp(w, 1, "邮箱格式不合法", nil)
+		return
+	}
+	user, err := userStore.GetByUID(req.Uid)
+	if err != nil {
+		writeResp(w, 1, err.Error(), nil)
+		return
+	}
+	if user.Email == req.NewEmail {
+		writeResp(w, 1, "新邮箱与当前邮箱相同", nil)
+		return
+	}
+	if err := verifyService.VerifyCode(req.NewEmail, service.PurposeChangeEmail, req.Code); err != nil {
+		writeResp(w, 1, err.Error(), nil)
+		return
+	}
+	if err := userStore.UpdateEmail(req.Uid, req.NewEmail); err != nil {
+		writeResp(w, 1, err.Error(), nil)
 		return
 	}
-	// 这里应调用实际邮件服务发送验证码
-	writeResp(w, 0, "验证码已发送(模拟)", nil)
+	// 通知原邮箱，账号被盗时用户可以及时发现
+	notice := fmt.Sprintf("您的IM账号 %s 绑定的邮箱已修改为 %s。如非本人操作，请立即重置密码。", req.Uid, req.NewEmail)
+	if err := verifyService.Notify(user.Email, "IM 邮箱修改通知", notice); err != nil {
+		fmt.Printf("向 %s 发送邮箱修改通知失败: %v\n", user.Email, err)
+	}
+	writeResp(w, 0, "邮箱修改成功", nil)
 }
 
 // 添加好友请求
@@ -323,11 +485,15 @@ func AddFriendHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
+	uid, ok := callerUID(w, r, req.FromUid)
+	if !ok {
+		return
+	}
+	req.FromUid = uid
 	if req.FromUid == "" || req.ToUid == "" {
 		writeResp(w, 1, "缺少UID", nil)
 		return
 	}
-	// TODO: 校验token
 	storageManager.AddFriendRequest(req.FromUid, req.ToUid, req.VerifyMsg)
 	// 推送好友请求通知
 	notif := &pb.Notification{
@@ -357,12 +523,24 @@ func HandleFriendHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
+	uid, ok := callerUID(w, r, req.ToUid)
+	if !ok {
+		return
+	}
+	req.ToUid = uid
 	if req.FromUid == "" || req.ToUid == "" {
 		writeResp(w, 1, "缺少UID", nil)
 		return
 	}
-	// TODO: 校验token
-	storageManager.HandleFriendRequest(req.FromUid, req.ToUid, req.Accept)
+	if err := storageManager.HandleFriendRequest(req.FromUid, req.ToUid, req.Accept); err != nil {
+		if err == storage.ErrFriendRequestNotFound {
+			writeResp(w, 1, err.Error(), nil)
+			return
+		}
+		fmt.Printf("处理 %s 的好友请求失败: %v\n", req.FromUid, err)
+		writeResp(w, 1, "处理好友请求失败", nil)
+		return
+	}
 	resp := &pb.HandleFriendResp{Code: 0, Msg: "处理成功"}
 	data, _ := proto.Marshal(resp)
 	writeResp(w, 0, "ok", data)
@@ -380,11 +558,11 @@ func FriendListHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Uid == "" {
-		writeResp(w, 1, "缺少UID", nil)
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
 		return
 	}
-	// TODO: 校验token
+	req.Uid = uid
 	friends, err := storageManager.GetFriends(req.Uid)
 	if err != nil {
 		writeResp(w, 1, "获取好友列表失败", nil)
@@ -404,7 +582,82 @@ func FriendListHandler(w http.ResponseWriter, r *http.Request) {
 		remark, _ := storageManager.GetFriendRemark(req.Uid, f)
 		remarks = append(remarks, remark)
 	}
-	resp := &pb.FriendListResp{FriendUids: friends, FriendUsernames: friendUsernames, Remarks: remarks, Code: 0, Msg: "ok"}
+	unread, err := storageManager.GetUnreadCounts(req.Uid)
+	if err != nil {
+		unread = map[string]int64{}
+	}
+	unreadCounts := make([]int64, len(friends))
+	presences := make([]string, len(friends))
+	lastSeens := make([]int64, len(friends))
+	for i, f := range friends {
+		unreadCounts[i] = unread[f]
+		presence := protocol.GetPresence(f)
+		presences[i] = presence.Status
+		lastSeens[i] = presence.LastSeen
+	}
+	resp := &pb.FriendListResp{FriendUids: friends, FriendUsernames: friendUsernames, Remarks: remarks, UnreadCounts: unreadCounts,
+		Presences: presences, LastSeens: lastSeens, Code: 0, Msg: "ok"}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
+
+// 获取聊天记录
+func ChatHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.ChatHistoryReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.FriendUid == "" && req.GroupId == 0 {
+		writeResp(w, 1, "缺少UID", nil)
+		return
+	}
+	if req.GroupId != 0 {
+		if _, err := storageManager.GetGroupMember(req.GroupId, req.Uid); err != nil {
+			writeResp(w, 1, "不是群成员", nil)
+			return
+		}
+	}
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	messages, hasMore, err := storageManager.GetChatHistory(&storage.HistoryQuery{
+		UserID:     req.Uid,
+		FriendID:   req.FriendUid,
+		GroupID:    req.GroupId,
+		BeforeID:   req.BeforeId,
+		AfterID:    req.AfterId,
+		BeforeTime: req.BeforeTime,
+		AfterTime:  req.AfterTime,
+		Limit:      limit,
+	})
+	if err != nil {
+		writeResp(w, 1, "获取聊天记录失败", nil)
+		return
+	}
+	resp := &pb.ChatHistoryResp{HasMore: hasMore, Code: 0, Msg: "ok"}
+	if req.GroupId == 0 {
+		resp.PeerReadId, _ = storageManager.GetReadCursor(req.FriendUid, req.Uid, 0)
+	}
+	for _, m := range messages {
+		resp.Messages = append(resp.Messages, protocol.MessageToPB(m))
+	}
+	protocol.AttachReferences(resp.Messages)
+	protocol.AttachReactions(resp.Messages)
 	data, _ := proto.Marshal(resp)
 	writeResp(w, 0, "ok", data)
 }
@@ -421,11 +674,15 @@ func UpdateRemarkHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Uid == "" || req.FriendUid == "" {
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.FriendUid == "" {
 		writeResp(w, 1, "缺少UID", nil)
 		return
 	}
-	// TODO: 校验token
 	storageManager.SetFriendRemark(req.Uid, req.FriendUid, req.Remark)
 	resp := &pb.UpdateRemarkResp{Code: 0, Msg: "备注设置成功"}
 	data, _ := proto.Marshal(resp)
@@ -444,11 +701,20 @@ func FriendInfoHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Uid == "" || req.FriendUid == "" {
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.FriendUid == "" {
 		writeResp(w, 1, "缺少UID", nil)
 		return
 	}
-	// TODO: 校验token
+	// 只能查看好友的资料，避免任意用户获取他人的邮箱和在线状态
+	if isFriend, err := storageManager.IsFriend(req.Uid, req.FriendUid); err != nil || !isFriend {
+		writeResp(w, 1, "好友不存在", nil)
+		return
+	}
 	user, err := storageManager.GetUserByUID(req.FriendUid)
 	if err != nil {
 		writeResp(w, 1, "好友不存在", nil)
@@ -456,6 +722,7 @@ func FriendInfoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	remark, _ := storageManager.GetFriendRemark(req.Uid, req.FriendUid)
 	dnd, _ := storageManager.GetFriendDND(req.Uid, req.FriendUid)
+	presence := protocol.GetPresence(req.FriendUid)
 	resp := &pb.FriendInfoResp{
 		Uid:      user.UID,
 		Username: user.Username,
@@ -464,7 +731,9 @@ func FriendInfoHandler(w http.ResponseWriter, r *http.Request) {
 		Code:     0,
 		Msg:      "ok",
 		// 新增 dnd 字段
-		Dnd: dnd,
+		Dnd:      dnd,
+		Presence: presence.Status,
+		LastSeen: presence.LastSeen,
 	}
 	data, _ := proto.Marshal(resp)
 	writeResp(w, 0, "ok", data)
@@ -482,11 +751,15 @@ func DeleteFriendHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Uid == "" || req.FriendUid == "" {
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.FriendUid == "" {
 		writeResp(w, 1, "缺少UID", nil)
 		return
 	}
-	// TODO: 校验token
 	storageManager.DeleteFriendship(req.Uid, req.FriendUid)
 	resp := &pb.DeleteFriendResp{Code: 0, Msg: "已删除"}
 	data, _ := proto.Marshal(resp)
@@ -505,7 +778,11 @@ func FriendRequestListHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	// TODO: 校验token
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
 	reqs, err := storageManager.GetFriendRequests(req.Uid)
 	if err != nil {
 		writeResp(w, 1, "获取好友请求失败", nil)
@@ -539,11 +816,15 @@ func SetDNDHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 1, "请求格式错误", nil)
 		return
 	}
-	if req.Uid == "" || req.FriendUid == "" {
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if req.FriendUid == "" {
 		writeResp(w, 1, "缺少UID", nil)
 		return
 	}
-	// TODO: 校验token
 	storageManager.SetFriendDND(req.Uid, req.FriendUid, req.Dnd)
 	resp := &pb.SetDNDResp{Code: 0, Msg: "设置成功"}
 	data, _ := proto.Marshal(resp)
@@ -556,9 +837,16 @@ func UploadFileHandler(w http.ResponseWriter, r *http.Request) {
 		writeResp(w, 4001, "只支持POST方法", nil)
 		return
 	}
+	if !authorize(w, identityFrom(r).UID, auth.ActionFileUpload, "没有权限上传文件") {
+		return
+	}
+
+	// 限制请求体大小，预留1MB给表单的其他部分
+	maxSize := fileService.MaxFileSize()
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1024*1024)
 
 	// 解析multipart表单
-	if err := r.ParseMultipartForm(50 * 1024 * 1024); err != nil {
+	if err := r.ParseMultipartForm(32 * 1024 * 1024); err != nil {
 		writeResp(w, 4002, "解析表单失败", nil)
 		return
 	}
@@ -590,6 +878,10 @@ func DownloadFileHandler(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
+	if !Authorizer.Authorize(identityFrom(r).UID, auth.ActionFileDownload) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
 
 	// 调用业务层获取文件路径
 	filePath, err := fileService.GetFilePath(filename)
@@ -607,27 +899,76 @@ func DownloadFileHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func StartHTTPServer(addr string) {
+	// 注册、登录、刷新令牌和找回密码无需登录，其余接口都需要在 Authorization 头中携带访问令牌
 	http.HandleFunc("/register", RegisterHandler)
 	http.HandleFunc("/login", LoginHandler)
-	http.HandleFunc("/logout", LogoutHandler)
+	http.HandleFunc("/logout", requireAuth(LogoutHandler))
+	http.HandleFunc("/refresh", RefreshTokenHandler)
+	http.HandleFunc("/login_2fa", Login2FAHandler)
+	http.HandleFunc("/totp_setup", requireAuth(TOTPSetupHandler))
+	http.HandleFunc("/totp_enable", requireAuth(TOTPEnableHandler))
+	http.HandleFunc("/totp_disable", requireAuth(TOTPDisableHandler))
 	http.HandleFunc("/reset_pwd", ResetPwdHandler)
-	http.HandleFunc("/update_username", UpdateUsernameHandler)
-	http.HandleFunc("/update_pwd", UpdatePwdHandler)
-	http.HandleFunc("/delete_account", DeleteAccountHandler)
-	http.HandleFunc("/user_info", UserInfoHandler)
-	http.HandleFunc("/token_check", TokenCheckHandler)
-	http.HandleFunc("/add_friend", AddFriendHandler)
-	http.HandleFunc("/handle_friend", HandleFriendHandler)
-	http.HandleFunc("/friend_list", FriendListHandler)
-	http.HandleFunc("/delete_friend", DeleteFriendHandler)
-	http.HandleFunc("/friend_request_list", FriendRequestListHandler)
-	http.HandleFunc("/update_remark", UpdateRemarkHandler)
-	http.HandleFunc("/friend_info", FriendInfoHandler)
-	http.HandleFunc("/set_dnd", SetDNDHandler)
+	http.HandleFunc("/update_username", requireAuth(UpdateUsernameHandler))
+	http.HandleFunc("/update_pwd", requireAuth(UpdatePwdHandler))
+	http.HandleFunc("/update_email", requireAuth(UpdateEmailHandler))
+	http.HandleFunc("/send_email_code", optionalAuth(SendEmailCodeHandler))
+	http.HandleFunc("/delete_account", requireAuth(DeleteAccountHandler))
+	http.HandleFunc("/user_info", requireAuth(UserInfoHandler))
+	http.HandleFunc("/token_check", requireAuth(TokenCheckHandler))
+	http.HandleFunc("/add_friend", requireAuth(AddFriendHandler))
+	http.HandleFunc("/handle_friend", requireAuth(HandleFriendHandler))
+	http.HandleFunc("/friend_list", requireAuth(FriendListHandler))
+	http.HandleFunc("/chat_history", requireAuth(ChatHistoryHandler))
+	http.HandleFunc("/search_messages", requireAuth(SearchMessagesHandler))
+	http.HandleFunc("/delete_friend", requireAuth(DeleteFriendHandler))
+	http.HandleFunc("/friend_request_list", requireAuth(FriendRequestListHandler))
+	http.HandleFunc("/update_remark", requireAuth(UpdateRemarkHandler))
+	http.HandleFunc("/friend_info", requireAuth(FriendInfoHandler))
+	http.HandleFunc("/set_dnd", requireAuth(SetDNDHandler))
+
+	// 群组路由
+	http.HandleFunc("/create_group", requireAuth(CreateGroupHandler))
+	http.HandleFunc("/dissolve_group", requireAuth(DissolveGroupHandler))
+	http.HandleFunc("/invite_group_member", requireAuth(InviteGroupMemberHandler))
+	http.HandleFunc("/remove_group_member", requireAuth(RemoveGroupMemberHandler))
+	http.HandleFunc("/leave_group", requireAuth(LeaveGroupHandler))
+	http.HandleFunc("/rename_group", requireAuth(RenameGroupHandler))
+	http.HandleFunc("/set_group_admin", requireAuth(SetGroupAdminHandler))
+	http.HandleFunc("/group_list", requireAuth(GroupListHandler))
+	http.HandleFunc("/group_members", requireAuth(GroupMembersHandler))
 
 	// 文件上传和下载路由
-	http.HandleFunc("/upload", UploadFileHandler)
-	http.HandleFunc("/uploads/", DownloadFileHandler)
+	http.HandleFunc("/upload", requireAuth(UploadFileHandler))
+	http.HandleFunc("/uploads/", requireAuth(DownloadFileHandler))
+	http.HandleFunc("/delete_file", requireAuth(DeleteFileHandler))
 
-	http.ListenAndServe(addr, nil)
+	// 系统管理路由
+	http.HandleFunc("/set_role", requireAuth(SetRoleHandler))
+	http.HandleFunc("/ws/stats", requireAuth(QueueStatsHandler))
+
+	http.ListenAndServe(addr, withCORS(http.DefaultServeMux))
+}
+
+// 跨域处理，只允许配置中的来源
+func withCORS(next http.Handler) http.Handler {
+	serverConfig := config.GetServerConfig()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			if !serverConfig.AllowOrigin(origin) {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
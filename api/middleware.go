@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"im/core/auth"
+)
+
+// 鉴权相关的响应码
+const (
+	codeUnauthorized = 401 // 缺少token或token无效
+	codeForbidden    = 403 // 请求中的UID与token所属用户不一致
+)
+
+// 通过token解析出的调用者身份
+type Identity struct {
+	UID       string
+	SessionID string
+}
+
+type identityKey struct{}
+
+// 从 Authorization: Bearer <token> 请求头中取出token
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// 解析请求头中的token，成功时返回带有调用者身份的请求
+func withIdentity(r *http.Request) (*http.Request, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		return r, false
+	}
+	claims, err := auth.ParseClaims(token)
+	if err != nil {
+		return r, false
+	}
+	id := &Identity{UID: claims.UserID, SessionID: claims.SessionID}
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id)), true
+}
+
+// requireAuth 要求请求携带有效token，校验通过后将调用者身份放入请求上下文
+func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if bearerToken(r) == "" {
+			writeResp(w, codeUnauthorized, "缺少token", nil)
+			return
+		}
+		r, ok := withIdentity(r)
+		if !ok {
+			writeResp(w, codeUnauthorized, "token无效", nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// optionalAuth 携带有效token时放入调用者身份，未携带或无效时按未登录处理
+func optionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, _ = withIdentity(r)
+		next(w, r)
+	}
+}
+
+// 基于角色的权限控制，HTTP 接口和 WebSocket 消息处理共用同一个实例，角色变化后同时生效
+var Authorizer = auth.NewRBAC(storageManager)
+
+// authorize 判断调用者能否执行操作，没有权限时写入错误响应并返回false
+func authorize(w http.ResponseWriter, uid, action, msg string) bool {
+	if Authorizer.Authorize(uid, action) {
+		return true
+	}
+	writeResp(w, codeForbidden, msg, nil)
+	return false
+}
+
+// 获取请求上下文中的调用者身份，未登录时返回nil
+func identityFrom(r *http.Request) *Identity {
+	id, _ := r.Context().Value(identityKey{}).(*Identity)
+	return id
+}
+
+// callerUID 返回调用者UID，请求体中的UID为空时视为调用者本人
+// UID与调用者不一致时写入错误响应并返回false
+func callerUID(w http.ResponseWriter, r *http.Request, uid string) (string, bool) {
+	id := identityFrom(r)
+	if id == nil {
+		writeResp(w, codeUnauthorized, "未登录", nil)
+		return "", false
+	}
+	if uid != "" && uid != id.UID {
+		writeResp(w, codeForbidden, "无权以其他用户身份操作", nil)
+		return "", false
+	}
+	return id.UID, true
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	pb "im/core/protocol/pb"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"im/core/protocol"
+	"im/core/storage"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	maxSearchKeywordLen   = 64 // 搜索关键词最大长度（字符数）
+	defaultSearchPageSize = 20 // 搜索结果默认每页条数
+	maxSearchPageSize     = 50 // 搜索结果每页最大条数
+	snippetContext        = 20 // 高亮片段中关键词前后保留的字符数
+)
+
+// 搜索条件中的消息类型对应的存储类型，chat 包括单聊、群聊文本和表情
+var searchTypes = map[string][]string{
+	"chat":  {"chat", "group_chat", "emoji"},
+	"image": {"image"},
+	"file":  {"file"},
+}
+
+// 截取关键词附近的片段，并用 <em></em> 标记片段中所有的关键词（不区分大小写）
+func highlightSnippet(text, keyword string) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	kw := []rune(strings.ToLower(keyword))
+	match := func(i int) bool {
+		if i+len(kw) > len(lower) {
+			return false
+		}
+		for j, r := range kw {
+			if lower[i+j] != r {
+				return false
+			}
+		}
+		return true
+	}
+
+	first := -1
+	for i := range lower {
+		if match(i) {
+			first = i
+			break
+		}
+	}
+	start, end := 0, len(runes)
+	if first >= 0 {
+		if first > snippetContext {
+			start = first - snippetContext
+		}
+		if first+len(kw)+snippetContext < end {
+			end = first + len(kw) + snippetContext
+		}
+	} else if end > 2*snippetContext {
+		end = 2 * snippetContext
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		if len(kw) > 0 && i+len(kw) <= end && match(i) {
+			b.WriteString("<em>" + string(runes[i:i+len(kw)]) + "</em>")
+			i += len(kw)
+			continue
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// 搜索聊天消息
+func SearchMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.SearchMessagesReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	keyword := strings.TrimSpace(req.Keyword)
+	if keyword == "" {
+		writeResp(w, 1, "关键词不能为空", nil)
+		return
+	}
+	if utf8.RuneCountInString(keyword) > maxSearchKeywordLen {
+		writeResp(w, 1, "关键词过长", nil)
+		return
+	}
+	if req.GroupId != 0 {
+		if _, err := storageManager.GetGroupMember(req.GroupId, req.Uid); err != nil {
+			writeResp(w, 1, "不是群成员", nil)
+			return
+		}
+	}
+	var types []string
+	for _, t := range req.Types {
+		mapped, ok := searchTypes[t]
+		if !ok {
+			writeResp(w, 1, "不支持的消息类型: "+t, nil)
+			return
+		}
+		types = append(types, mapped...)
+	}
+	page := int(req.Page)
+	if page < 1 {
+		page = 1
+	}
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = defaultSearchPageSize
+	}
+	if pageSize > maxSearchPageSize {
+		pageSize = maxSearchPageSize
+	}
+
+	messages, total, err := storageManager.SearchMessages(&storage.SearchQuery{
+		UserID:    req.Uid,
+		Keyword:   keyword,
+		SenderID:  req.SenderUid,
+		FriendID:  req.FriendUid,
+		GroupID:   req.GroupId,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Types:     types,
+		Offset:    (page - 1) * pageSize,
+		Limit:     pageSize,
+	})
+	if err != nil {
+		writeResp(w, 1, "搜索失败", nil)
+		return
+	}
+	resp := &pb.SearchMessagesResp{Total: int32(total), HasMore: page*pageSize < total, Code: 0, Msg: "ok"}
+	for _, m := range messages {
+		// 图片、文件的内容是下载地址，在文件名中匹配
+		text := m.Content
+		if m.Type == "image" || m.Type == "file" {
+			text = m.Extra
+		}
+		resp.Results = append(resp.Results, &pb.SearchResult{
+			Message: protocol.MessageToPB(m),
+			Snippet: highlightSnippet(text, keyword),
+		})
+	}
+	data, _ := proto.Marshal(resp)
+	writeResp(w, 0, "ok", data)
+}
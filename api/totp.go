@@ -0,0 +1,120 @@
+package api
+
+import (
+	"im/core/auth"
+	pb "im/core/protocol/pb"
+	"io/ioutil"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 登录第二步：校验两步验证码或恢复码后签发令牌
+func Login2FAHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 2001, "请求体读取失败", nil)
+		return
+	}
+	var req pb.Login2FAReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 2001, "请求格式错误", nil)
+		return
+	}
+	if req.Challenge == "" || req.Code == "" {
+		writeResp(w, 2002, "登录验证和验证码不能为空", nil)
+		return
+	}
+	ip := clientIP(r)
+	uid, err := auth.CompleteLoginChallenge(req.Challenge, req.Code)
+	if err != nil {
+		if uid != "" {
+			loginGuard.Fail(uid, ip, "两步验证码错误")
+		}
+		writeResp(w, 2008, err.Error(), nil)
+		return
+	}
+	pair, err := auth.IssueTokens(uid)
+	if err != nil {
+		writeResp(w, 2006, "生成token失败", nil)
+		return
+	}
+	loginGuard.Succeed(uid, ip)
+	writeResp(w, 0, "登录成功", marshalTokenPair(pair))
+}
+
+// 生成两步验证密钥，确认开启前不生效
+func TOTPSetupHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.TOTPSetupReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	secret, uri, err := auth.SetupTOTP(req.Uid)
+	if err != nil {
+		writeResp(w, 1, err.Error(), nil)
+		return
+	}
+	data, _ := proto.Marshal(&pb.TOTPSetupResp{Secret: secret, OtpauthUri: uri})
+	writeResp(w, 0, "ok", data)
+}
+
+// 确认开启两步验证，返回恢复码
+func TOTPEnableHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.TOTPEnableReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	codes, err := auth.EnableTOTP(req.Uid, req.Code)
+	if err != nil {
+		writeResp(w, 1, err.Error(), nil)
+		return
+	}
+	data, _ := proto.Marshal(&pb.TOTPEnableResp{RecoveryCodes: codes})
+	writeResp(w, 0, "两步验证已开启", data)
+}
+
+// 关闭两步验证
+func TOTPDisableHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeResp(w, 1, "请求体读取失败", nil)
+		return
+	}
+	var req pb.TOTPDisableReq
+	if err := proto.Unmarshal(body, &req); err != nil {
+		writeResp(w, 1, "请求格式错误", nil)
+		return
+	}
+	uid, ok := callerUID(w, r, req.Uid)
+	if !ok {
+		return
+	}
+	req.Uid = uid
+	if err := auth.DisableTOTP(req.Uid, req.Code); err != nil {
+		writeResp(w, 1, err.Error(), nil)
+		return
+	}
+	writeResp(w, 0, "两步验证已关闭", nil)
+}
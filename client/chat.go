@@ -10,11 +10,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	pb "im/core/protocol/pb"
 
-	"github.com/gorilla/websocket"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -42,55 +42,49 @@ var emojiMap = map[string]string{
 	":cake:":       "🎂",
 }
 
+// 每次加载的聊天记录条数
+const historyPageSize = 20
+
+// 当前聊天已加载的最早消息ID，用于向前翻页
+var chatOldestMsgID int64
+
 // 扩展的私聊功能
 func wsChatWithFriendExtended(_ interface{}, friendUid string) {
-	c, _, err := websocket.DefaultDialer.Dial("ws://127.0.0.1:8090/ws", nil)
+	c, err := dialWS("chat")
 	if err != nil {
-		fmt.Println("WebSocket 连接失败:", err)
+		fmt.Println(err)
 		return
 	}
 	defer c.Close()
-
-	if savedToken == "" {
-		fmt.Println("请先登录获取token")
-		return
-	}
-
-	// 先进行WebSocket登录
-	loginMsg := &pb.IMMessage{Type: "login", Token: savedToken}
-	b, _ := proto.Marshal(loginMsg)
-	if err := c.WriteMessage(websocket.BinaryMessage, b); err != nil {
-		fmt.Println("WebSocket登录失败:", err)
-		return
-	}
-
-	// 等待登录响应
-	_, loginResp, err := c.ReadMessage()
-	if err != nil {
-		fmt.Println("读取登录响应失败:", err)
-		return
-	}
-	var loginResponse pb.IMMessage
-	if err := proto.Unmarshal(loginResp, &loginResponse); err != nil {
-		fmt.Println("解析登录响应失败:", err)
-		return
-	}
-	if loginResponse.Type == "error" {
-		fmt.Println("WebSocket登录失败:", loginResponse.Content)
-		return
-	}
+	setChatScreenActive(true)
+	defer setChatScreenActive(false)
+	setKeyListener(newTypingNotifier(c, friendUid, 0))
+	defer setKeyListener(nil)
 
 	fmt.Printf("已进入与 %s 的私聊\n", friendUid)
 	fmt.Println("支持的命令:")
 	fmt.Println("  /emoji - 查看可用表情")
 	fmt.Println("  /image <文件路径> - 发送图片")
 	fmt.Println("  /file <文件路径> - 发送文件")
+	fmt.Println("  /download <下载地址> [保存路径] - 下载图片或文件")
+	fmt.Println("  /history - 查看更早的消息")
+	fmt.Println("  /recall [#消息ID] - 撤回消息，默认撤回最后发送的一条")
+	fmt.Println("  /edit [#消息ID] <新内容> - 编辑消息，默认编辑最后发送的一条")
+	fmt.Println("  /reply #消息ID <内容> - 回复消息")
+	fmt.Println("  /forward #消息ID <好友UID|group 群ID> - 转发消息")
+	fmt.Println("  /react #消息ID <表情> - 回应消息，如 /react #12 :thumbsup:")
+	fmt.Println("  /unreact #消息ID <表情> - 取消回应")
+	fmt.Println("  /search <关键词> [from:UID] [type:chat|image|file] [since:日期] [until:日期] [page:N] [all] - 搜索消息")
 	fmt.Println("  /exit - 退出聊天")
 
+	// 加载最近的聊天记录
+	chatOldestMsgID = 0
+	sendRead(c, friendUid, 0, showChatHistory(friendUid))
+
 	quit := make(chan struct{})
 	go func() {
 		for {
-			_, msg, err := c.ReadMessage()
+			im, err := wsRead(c)
 			if err != nil {
 				select {
 				case <-quit:
@@ -100,12 +94,32 @@ func wsChatWithFriendExtended(_ interface{}, friendUid string) {
 				}
 				return
 			}
-			var im pb.IMMessage
-			err = proto.Unmarshal(msg, &im)
-			if err != nil {
-				fmt.Println("收到非Protobuf消息：", string(msg))
-			} else if im.Type == "chat" && im.From == friendUid {
-				displayMessage(&im)
+			if isChatMessage(im.Type) {
+				if !receiveMessage(c, im) {
+					continue
+				}
+				// 对方发来的消息，或自己在其他设备上发给对方的消息
+				if im.GroupId == 0 && (im.From == friendUid || (im.From == savedUID && im.To == friendUid)) {
+					displayMessage(im)
+					if im.From == friendUid {
+						sendRead(c, friendUid, 0, im.MsgId)
+					}
+				} else {
+					fmt.Printf("[%s 发来新消息] %s\n", im.From, formatMessageContent(im))
+				}
+			} else if (im.Type == "recall" || im.Type == "edit") && im.GroupId == 0 &&
+				(im.From == friendUid || (im.From == savedUID && im.To == friendUid)) {
+				displayModification(im)
+			} else if im.Type == "reaction" && im.GroupId == 0 && (im.From == friendUid || wasDisplayed(im.MsgId)) {
+				displayReaction(im)
+			} else if im.Type == "read" && im.GroupId == 0 && im.From == friendUid {
+				fmt.Println("[对方已读]")
+			} else if im.Type == "typing" && im.GroupId == 0 && im.From == friendUid {
+				if shouldShowTyping(im.From) {
+					fmt.Println("[对方正在输入...]")
+				}
+			} else if im.Type == "presence" && im.From == friendUid {
+				fmt.Printf("[对方%s]\n", presenceChangeText[im.Content])
 			} else if im.Type == "error" {
 				fmt.Println("错误消息：", im.Content)
 			}
@@ -143,17 +157,17 @@ func wsChatWithFriendExtended(_ interface{}, friendUid string) {
 				Content:   text,
 				Timestamp: time.Now().Unix(),
 			}
-			b, _ := proto.Marshal(msg)
-			if err := c.WriteMessage(websocket.BinaryMessage, b); err != nil {
-				fmt.Println("发送消息失败:", err)
-				return
+			if err := wsSend(c, msg); err != nil {
+				// 连接断开时后台自动重连，保留在聊天界面
+				fmt.Println("发送消息失败，请稍后重试:", err)
+				continue
 			}
 		}
 	}
 }
 
 // 处理特殊命令
-func handleCommand(cmd string, c *websocket.Conn, friendUid string, quit chan struct{}) {
+func handleCommand(cmd string, c *wsClient, friendUid string, quit chan struct{}) {
 	parts := strings.Fields(cmd)
 	if len(parts) == 0 {
 		return
@@ -174,6 +188,18 @@ func handleCommand(cmd string, c *websocket.Conn, friendUid string, quit chan st
 			return
 		}
 		sendFile(parts[1], c, friendUid)
+	case "/download":
+		handleDownloadCommand(parts)
+	case "/history":
+		showChatHistory(friendUid)
+	case "/recall", "/edit":
+		handleModifyCommand(c, cmd)
+	case "/reply", "/forward":
+		handleReferenceCommand(c, cmd, friendUid, 0)
+	case "/react", "/unreact":
+		handleReactionCommand(c, cmd)
+	case "/search":
+		handleSearchCommand(cmd, friendUid, 0)
 	default:
 		fmt.Println("未知命令:", parts[0])
 	}
@@ -196,7 +222,7 @@ func replaceEmojis(text string) string {
 }
 
 // 发送图片
-func sendImage(filePath string, c *websocket.Conn, friendUid string) {
+func sendImage(filePath string, c *wsClient, friendUid string) {
 	// 上传文件
 	fileInfo, err := uploadFile(filePath)
 	if err != nil {
@@ -217,8 +243,7 @@ func sendImage(filePath string, c *websocket.Conn, friendUid string) {
 		MimeType:  "image/" + strings.ToLower(filepath.Ext(fileInfo.OriginalName)[1:]),
 	}
 
-	b, _ := proto.Marshal(msg)
-	if err := c.WriteMessage(websocket.BinaryMessage, b); err != nil {
+	if err := wsSend(c, msg); err != nil {
 		fmt.Println("发送图片消息失败:", err)
 		return
 	}
@@ -227,7 +252,7 @@ func sendImage(filePath string, c *websocket.Conn, friendUid string) {
 }
 
 // 发送文件
-func sendFile(filePath string, c *websocket.Conn, friendUid string) {
+func sendFile(filePath string, c *wsClient, friendUid string) {
 	// 上传文件
 	fileInfo, err := uploadFile(filePath)
 	if err != nil {
@@ -248,8 +273,7 @@ func sendFile(filePath string, c *websocket.Conn, friendUid string) {
 		MimeType:  getMimeType(fileInfo.OriginalName),
 	}
 
-	b, _ := proto.Marshal(msg)
-	if err := c.WriteMessage(websocket.BinaryMessage, b); err != nil {
+	if err := wsSend(c, msg); err != nil {
 		fmt.Println("发送文件消息失败:", err)
 		return
 	}
@@ -282,7 +306,7 @@ func uploadFile(filePath string) (*pb.FileInfo, error) {
 	writer.Close()
 
 	// 发送上传请求
-	resp, err := http.Post("http://localhost:8081/upload", writer.FormDataContentType(), &buf)
+	resp, err := authPost(httpBaseURL+"/upload", writer.FormDataContentType(), &buf)
 	if err != nil {
 		return nil, fmt.Errorf("上传请求失败: %v", err)
 	}
@@ -306,21 +330,349 @@ func uploadFile(filePath string) (*pb.FileInfo, error) {
 	return &fileInfo, nil
 }
 
+// 处理 /download 命令，下载地址需携带token访问，不能直接用浏览器打开
+func handleDownloadCommand(parts []string) {
+	if len(parts) < 2 {
+		fmt.Println("用法: /download <下载地址> [保存路径]")
+		return
+	}
+	savePath := filepath.Base(parts[1])
+	if len(parts) > 2 {
+		savePath = parts[2]
+	}
+	if err := downloadFile(parts[1], savePath); err != nil {
+		fmt.Println("下载失败:", err)
+		return
+	}
+	fmt.Println("已保存到", savePath)
+}
+
+// 下载文件，url 为消息中的下载地址（如 /uploads/xxx.png）
+func downloadFile(url, savePath string) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = httpBaseURL + url
+	}
+	resp, err := authGet(url)
+	if err != nil {
+		return fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("服务器返回 %s", resp.Status)
+	}
+
+	file, err := os.Create(savePath)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	return nil
+}
+
+// 获取聊天记录，beforeID 为 0 时返回最新一页
+func getChatHistory(req *pb.ChatHistoryReq) (*pb.ChatHistoryResp, error) {
+	var history pb.ChatHistoryResp
+	if _, err := postProto("/chat_history", req, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// 显示比已加载消息更早的一页聊天记录
+// 返回本页最新一条消息的ID
+func showChatHistory(friendUid string) int64 {
+	history, err := getChatHistory(&pb.ChatHistoryReq{
+		Uid:       savedUID,
+		FriendUid: friendUid,
+		Token:     currentToken(),
+		BeforeId:  chatOldestMsgID,
+		Limit:     historyPageSize,
+	})
+	if err != nil {
+		fmt.Println("获取聊天记录失败:", err)
+		return 0
+	}
+	if len(history.Messages) == 0 {
+		fmt.Println("--- 没有更多聊天记录 ---")
+		return 0
+	}
+	fmt.Println("--- 聊天记录 ---")
+	var lastSentID int64
+	for _, m := range history.Messages {
+		markSeen(m.MsgId)
+		displayMessage(m)
+		if m.From == savedUID {
+			lastSentID = m.MsgId
+		}
+	}
+	if lastSentID != 0 && history.PeerReadId >= lastSentID {
+		fmt.Println("[对方已读]")
+	}
+	if history.HasMore {
+		fmt.Println("--- 输入 /history 查看更早的消息 ---")
+	} else {
+		fmt.Println("--- 以上为全部聊天记录 ---")
+	}
+	chatOldestMsgID = history.Messages[0].MsgId
+	return history.Messages[len(history.Messages)-1].MsgId
+}
+
 // 显示消息
 func displayMessage(msg *pb.IMMessage) {
+	rememberMessage(msg)
+	sender := msg.From
+	if msg.MsgId != 0 {
+		sender = fmt.Sprintf("[#%d] %s", msg.MsgId, msg.From)
+	}
+	if msg.ForwardFromUid != "" {
+		sender += fmt.Sprintf(" [转发自 %s]", msg.ForwardFromUid)
+	}
+	if q := msg.Quoted; q != nil {
+		fmt.Printf("  ┆ 回复 [#%d] %s: %s\n", q.MsgId, q.From, formatMessageContent(q))
+	}
+	if msg.Recalled {
+		fmt.Printf("%s: [消息已撤回]\n", sender)
+		return
+	}
 	switch msg.Type {
-	case "chat":
-		fmt.Printf("%s: %s\n", msg.From, msg.Content)
+	case "chat", "group_chat":
+		edited := ""
+		if msg.EditedAt != 0 {
+			edited = " (已编辑)"
+		}
+		fmt.Printf("%s: %s%s\n", sender, msg.Content, edited)
 	case "emoji":
-		fmt.Printf("%s: %s\n", msg.From, msg.Content)
+		fmt.Printf("%s: %s\n", sender, msg.Content)
+	case "image":
+		fmt.Printf("%s: [图片] %s\n", sender, msg.Extra)
+		fmt.Printf("  下载: /download %s\n", msg.Content)
+	case "file":
+		fmt.Printf("%s: [文件] %s\n", sender, msg.Extra)
+		fmt.Printf("  下载: /download %s\n", msg.Content)
+	default:
+		fmt.Printf("%s: [%s] %s\n", sender, msg.Type, msg.Content)
+	}
+	if len(msg.Reactions) > 0 {
+		fmt.Println("  " + formatReactions(msg.Reactions))
+	}
+}
+
+// 回应汇总的单行显示，如 "👍 2  ❤️ 1"
+func formatReactions(reactions []*pb.Reaction) string {
+	var parts []string
+	for _, r := range reactions {
+		parts = append(parts, fmt.Sprintf("%s %d", r.Emoji, len(r.UserIds)))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// 显示回应变化
+func displayReaction(event *pb.IMMessage) {
+	action := "回应了"
+	if event.Extra == "remove" {
+		action = "取消了回应"
+	}
+	summary := ""
+	if len(event.Reactions) > 0 {
+		summary = "  (" + formatReactions(event.Reactions) + ")"
+	}
+	fmt.Printf("[#%d] %s %s %s%s\n", event.MsgId, event.From, action, event.Content, summary)
+}
+
+// 消息是否已在界面上显示过
+func wasDisplayed(msgID int64) bool {
+	displayedMessages.Lock()
+	defer displayedMessages.Unlock()
+	_, ok := displayedMessages.content[msgID]
+	return ok
+}
+
+// 处理 /react、/unreact 命令，表情可以是 emojiMap 中的代码或表情本身
+// 用法: /react #消息ID <表情>，/unreact #消息ID <表情>
+func handleReactionCommand(c *wsClient, cmd string) {
+	parts := strings.Fields(cmd)
+	if len(parts) != 3 {
+		fmt.Printf("用法: %s #消息ID <表情>\n", parts[0])
+		return
+	}
+	msgID, ok := parseMsgIDArg(parts[1])
+	if !ok {
+		fmt.Println("消息ID格式错误:", parts[1])
+		return
+	}
+	emoji := parts[2]
+	if e, ok := emojiMap[emoji]; ok {
+		emoji = e
+	}
+	op := "add"
+	if parts[0] == "/unreact" {
+		op = "remove"
+	}
+	if err := wsSend(c, &pb.IMMessage{Type: "reaction", MsgId: msgID, Content: emoji, Extra: op}); err != nil {
+		fmt.Println("发送失败，请稍后重试:", err)
+	}
+}
+
+// 已显示消息的内容摘要，撤回或编辑时用于划掉原内容
+var displayedMessages = struct {
+	sync.Mutex
+	content map[int64]string
+}{content: make(map[int64]string)}
+
+func rememberMessage(msg *pb.IMMessage) {
+	if msg.MsgId == 0 {
+		return
+	}
+	displayedMessages.Lock()
+	defer displayedMessages.Unlock()
+	displayedMessages.content[msg.MsgId] = formatMessageContent(msg)
+}
+
+// 划线显示文本
+func strikethrough(text string) string {
+	return "\x1b[9m" + text + "\x1b[0m"
+}
+
+// 显示消息被撤回或编辑
+func displayModification(event *pb.IMMessage) {
+	displayedMessages.Lock()
+	original, shown := displayedMessages.content[event.MsgId]
+	if event.Type == "recall" {
+		displayedMessages.content[event.MsgId] = "[消息已撤回]"
+	} else {
+		displayedMessages.content[event.MsgId] = event.Content
+	}
+	displayedMessages.Unlock()
+
+	switch {
+	case event.Type == "recall" && shown:
+		fmt.Printf("[#%d] %s 撤回了一条消息: %s\n", event.MsgId, event.From, strikethrough(original))
+	case event.Type == "recall":
+		fmt.Printf("[#%d] %s 撤回了一条消息\n", event.MsgId, event.From)
+	case shown:
+		fmt.Printf("[#%d] %s 编辑了消息: %s → %s\n", event.MsgId, event.From, strikethrough(original), event.Content)
+	default:
+		fmt.Printf("[#%d] %s 编辑了消息: %s\n", event.MsgId, event.From, event.Content)
+	}
+}
+
+// 解析 #消息ID 参数
+func parseMsgIDArg(arg string) (int64, bool) {
+	var msgID int64
+	if _, err := fmt.Sscanf(arg, "#%d", &msgID); err != nil || msgID <= 0 {
+		return 0, false
+	}
+	return msgID, true
+}
+
+// 处理 /reply、/forward 命令，friendUid 与 groupID 为当前会话
+// 用法: /reply #消息ID <内容>，/forward #消息ID <好友UID>，/forward #消息ID group <群ID>
+func handleReferenceCommand(c *wsClient, cmd string, friendUid string, groupID int64) {
+	parts := strings.Fields(cmd)
+	if len(parts) < 3 {
+		fmt.Println("用法: /reply #消息ID <内容> 或 /forward #消息ID <好友UID|group 群ID>")
+		return
+	}
+	msgID, ok := parseMsgIDArg(parts[1])
+	if !ok {
+		fmt.Println("消息ID格式错误:", parts[1])
+		return
+	}
+	var msg *pb.IMMessage
+	if parts[0] == "/reply" {
+		msg = &pb.IMMessage{
+			Type:      "chat",
+			From:      savedUID,
+			To:        friendUid,
+			Content:   replaceEmojis(strings.Join(parts[2:], " ")),
+			Timestamp: time.Now().Unix(),
+			ReplyToId: msgID,
+		}
+		if groupID != 0 {
+			msg.Type = "group_chat"
+			msg.GroupId = groupID
+		}
+	} else {
+		msg = &pb.IMMessage{Type: "forward", From: savedUID, ForwardFromId: msgID, Timestamp: time.Now().Unix()}
+		if parts[2] == "group" {
+			if len(parts) < 4 {
+				fmt.Println("用法: /forward #消息ID group <群ID>")
+				return
+			}
+			if _, err := fmt.Sscanf(parts[3], "%d", &msg.GroupId); err != nil {
+				fmt.Println("群ID格式错误:", parts[3])
+				return
+			}
+		} else {
+			msg.To = parts[2]
+		}
+	}
+	if err := wsSend(c, msg); err != nil {
+		fmt.Println("发送失败，请稍后重试:", err)
+		return
+	}
+	if parts[0] == "/forward" {
+		fmt.Println("已转发")
+	}
+}
+
+// 处理 /recall、/edit 命令，未指定 #消息ID 时操作自己最后发送的消息
+// 用法: /recall [#消息ID]，/edit [#消息ID] <新内容>
+func handleModifyCommand(c *wsClient, cmd string) {
+	parts := strings.Fields(cmd)
+	args := parts[1:]
+	msgID := lastSentMsgID()
+	if len(args) > 0 && strings.HasPrefix(args[0], "#") {
+		id, ok := parseMsgIDArg(args[0])
+		if !ok {
+			fmt.Println("消息ID格式错误:", args[0])
+			return
+		}
+		msgID = id
+		args = args[1:]
+	}
+	if msgID == 0 {
+		fmt.Println("没有可操作的消息，请指定 #消息ID")
+		return
+	}
+	msg := &pb.IMMessage{Type: "recall", MsgId: msgID}
+	if parts[0] == "/edit" {
+		if len(args) == 0 {
+			fmt.Println("用法: /edit [#消息ID] <新内容>")
+			return
+		}
+		msg.Type = "edit"
+		msg.Content = replaceEmojis(strings.Join(args, " "))
+	}
+	if err := wsSend(c, msg); err != nil {
+		fmt.Println("发送失败，请稍后重试:", err)
+	}
+}
+
+// 是否为聊天消息类型
+func isChatMessage(msgType string) bool {
+	switch msgType {
+	case "chat", "emoji", "image", "file", "group_chat":
+		return true
+	}
+	return false
+}
+
+// 消息内容的单行摘要
+func formatMessageContent(msg *pb.IMMessage) string {
+	if msg.Recalled {
+		return "[消息已撤回]"
+	}
+	switch msg.Type {
 	case "image":
-		fmt.Printf("%s: [图片] %s\n", msg.From, msg.Extra)
-		fmt.Printf("  下载链接: http://localhost:8081%s\n", msg.Content)
+		return "[图片] " + msg.Extra
 	case "file":
-		fmt.Printf("%s: [文件] %s\n", msg.From, msg.Extra)
-		fmt.Printf("  下载链接: http://localhost:8081%s\n", msg.Content)
+		return "[文件] " + msg.Extra
 	default:
-		fmt.Printf("%s: [%s] %s\n", msg.From, msg.Type, msg.Content)
+		return msg.Content
 	}
 }
 
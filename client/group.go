@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pb "im/core/protocol/pb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 群角色显示名称
+var groupRoleNames = map[string]string{
+	"owner":  "群主",
+	"admin":  "管理员",
+	"member": "成员",
+}
+
+func groupMenu(_ interface{}) {
+	for {
+		fmt.Println("1. 我的群组 2. 创建群组 0. 返回")
+		opStr := readLine("选择操作: ", nil)
+		var op int
+		fmt.Sscanf(opStr, "%d", &op)
+		switch op {
+		case 1:
+			groups := getGroupList()
+			if len(groups) == 0 {
+				fmt.Println("暂无群组")
+				continue
+			}
+			for i, g := range groups {
+				fmt.Printf("%d. %s(群ID:%d) %d人 [%s]%s\n", i+1, g.Name, g.GroupId, g.MemberCount, groupRoleNames[g.Role], unreadSuffix(g.UnreadCount))
+			}
+			idxStr := readLine("选择群组编号进入详情(0返回): ", nil)
+			var idx int
+			fmt.Sscanf(idxStr, "%d", &idx)
+			if idx > 0 && idx <= len(groups) {
+				groupDetailMenu(groups[idx-1])
+			}
+		case 2:
+			name := readLine("群名称: ", nil)
+			uids := strings.Fields(strings.ReplaceAll(readLine("邀请成员UID(空格或逗号分隔，可留空): ", nil), ",", " "))
+			createGroup(name, uids)
+		case 0:
+			return
+		}
+	}
+}
+
+func groupDetailMenu(group *pb.GroupInfo) {
+	for {
+		fmt.Printf("群组: %s(群ID:%d)\n", group.Name, group.GroupId)
+		fmt.Println("1. 群聊 2. 成员列表 3. 邀请成员 4. 移除成员 5. 修改群名 6. 设置管理员 7. 退出群 8. 解散群 0. 返回")
+		opStr := readLine("选择操作: ", nil)
+		var op int
+		fmt.Sscanf(opStr, "%d", &op)
+		switch op {
+		case 1:
+			wsGroupChat(group)
+		case 2:
+			members := getGroupMembers(group.GroupId)
+			for _, m := range members {
+				fmt.Printf("  %s(%s) [%s]\n", m.Username, m.Uid, groupRoleNames[m.Role])
+			}
+		case 3:
+			uids := strings.Fields(strings.ReplaceAll(readLine("邀请成员UID(空格或逗号分隔): ", nil), ",", " "))
+			groupRequest("/invite_group_member", &pb.InviteGroupMemberReq{Uid: savedUID, Token: currentToken(), GroupId: group.GroupId, MemberUids: uids}, "邀请成员")
+		case 4:
+			uid := strings.TrimSpace(readLine("要移除的成员UID: ", nil))
+			groupRequest("/remove_group_member", &pb.RemoveGroupMemberReq{Uid: savedUID, Token: currentToken(), GroupId: group.GroupId, MemberUid: uid}, "移除成员")
+		case 5:
+			name := readLine("新群名称: ", nil)
+			if groupRequest("/rename_group", &pb.RenameGroupReq{Uid: savedUID, Token: currentToken(), GroupId: group.GroupId, Name: name}, "修改群名") {
+				group.Name = strings.TrimSpace(name)
+			}
+		case 6:
+			uid := strings.TrimSpace(readLine("成员UID: ", nil))
+			setStr := readLine("设为管理员? (y=设为管理员 n=取消管理员): ", nil)
+			admin := setStr == "y" || setStr == "Y"
+			groupRequest("/set_group_admin", &pb.SetGroupAdminReq{Uid: savedUID, Token: currentToken(), GroupId: group.GroupId, MemberUid: uid, Admin: admin}, "设置管理员")
+		case 7:
+			if groupRequest("/leave_group", &pb.LeaveGroupReq{Uid: savedUID, Token: currentToken(), GroupId: group.GroupId}, "退出群") {
+				return
+			}
+		case 8:
+			confirm := readLine("确定解散该群? (y/n): ", nil)
+			if confirm != "y" && confirm != "Y" {
+				continue
+			}
+			if groupRequest("/dissolve_group", &pb.DissolveGroupReq{Uid: savedUID, Token: currentToken(), GroupId: group.GroupId}, "解散群") {
+				return
+			}
+		case 0:
+			return
+		}
+	}
+}
+
+// 发送群管理请求并打印结果，成功返回 true
+func groupRequest(path string, req proto.Message, action string) bool {
+	resp, err := postProto(path, req, nil)
+	if err != nil {
+		fmt.Printf("%s失败: %v\n", action, err)
+		return false
+	}
+	fmt.Printf("%s响应: %s\n", action, resp.Msg)
+	return true
+}
+
+func createGroup(name string, memberUids []string) {
+	if strings.TrimSpace(name) == "" {
+		fmt.Println("群名称不能为空")
+		return
+	}
+	var resp pb.CreateGroupResp
+	if _, err := postProto("/create_group", &pb.CreateGroupReq{Uid: savedUID, Token: currentToken(), Name: name, MemberUids: memberUids}, &resp); err != nil {
+		fmt.Println("创建群失败:", err)
+		return
+	}
+	fmt.Printf("创建群响应: %s，群ID: %d\n", resp.Msg, resp.GroupId)
+}
+
+func getGroupList() []*pb.GroupInfo {
+	var list pb.GroupListResp
+	if _, err := postProto("/group_list", &pb.GroupListReq{Uid: savedUID, Token: currentToken()}, &list); err != nil {
+		fmt.Println("获取群列表失败:", err)
+		return nil
+	}
+	return list.Groups
+}
+
+func getGroupMembers(groupID int64) []*pb.GroupMemberInfo {
+	var list pb.GroupMembersResp
+	if _, err := postProto("/group_members", &pb.GroupMembersReq{Uid: savedUID, Token: currentToken(), GroupId: groupID}, &list); err != nil {
+		fmt.Println("获取群成员失败:", err)
+		return nil
+	}
+	return list.Members
+}
+
+// 显示一页群聊记录，返回本页最早和最新一条消息的ID
+func showGroupHistory(groupID int64, beforeID int64) (int64, int64) {
+	history, err := getChatHistory(&pb.ChatHistoryReq{
+		Uid:      savedUID,
+		Token:    currentToken(),
+		GroupId:  groupID,
+		BeforeId: beforeID,
+		Limit:    historyPageSize,
+	})
+	if err != nil {
+		fmt.Println("获取聊天记录失败:", err)
+		return beforeID, 0
+	}
+	if len(history.Messages) == 0 {
+		fmt.Println("--- 没有更多聊天记录 ---")
+		return beforeID, 0
+	}
+	fmt.Println("--- 聊天记录 ---")
+	for _, m := range history.Messages {
+		markSeen(m.MsgId)
+		displayMessage(m)
+	}
+	if history.HasMore {
+		fmt.Println("--- 输入 /history 查看更早的消息 ---")
+	}
+	return history.Messages[0].MsgId, history.Messages[len(history.Messages)-1].MsgId
+}
+
+// 群聊
+func wsGroupChat(group *pb.GroupInfo) {
+	c, err := dialWS("group")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+	setChatScreenActive(true)
+	defer setChatScreenActive(false)
+	setKeyListener(newTypingNotifier(c, "", group.GroupId))
+	defer setKeyListener(nil)
+
+	fmt.Printf("已进入群聊 %s，直接输入消息内容发送\n", group.Name)
+	fmt.Println("  /history - 查看更早的消息")
+	fmt.Println("  /download <下载地址> [保存路径] - 下载图片或文件")
+	fmt.Println("  /recall [#消息ID] - 撤回消息，默认撤回最后发送的一条")
+	fmt.Println("  /edit [#消息ID] <新内容> - 编辑消息，默认编辑最后发送的一条")
+	fmt.Println("  /reply #消息ID <内容> - 回复消息")
+	fmt.Println("  /forward #消息ID <好友UID|group 群ID> - 转发消息")
+	fmt.Println("  /react #消息ID <表情> - 回应消息，如 /react #12 :thumbsup:")
+	fmt.Println("  /unreact #消息ID <表情> - 取消回应")
+	fmt.Println("  /search <关键词> [from:UID] [type:chat|image|file] [since:日期] [until:日期] [page:N] [all] - 搜索消息")
+	fmt.Println("  /exit - 退出群聊")
+	oldestID, newestID := showGroupHistory(group.GroupId, 0)
+	sendRead(c, "", group.GroupId, newestID)
+
+	quit := make(chan struct{})
+	go func() {
+		for {
+			im, err := wsRead(c)
+			if err != nil {
+				select {
+				case <-quit:
+				default:
+					fmt.Println("服务器断开：", err)
+				}
+				return
+			}
+			switch {
+			case isChatMessage(im.Type):
+				if !receiveMessage(c, im) {
+					continue
+				}
+				if im.GroupId == group.GroupId {
+					displayMessage(im)
+					if im.From != savedUID {
+						sendRead(c, "", group.GroupId, im.MsgId)
+					}
+				} else {
+					fmt.Printf("[%s 发来新消息] %s\n", im.From, formatMessageContent(im))
+				}
+			case (im.Type == "recall" || im.Type == "edit") && im.GroupId == group.GroupId:
+				displayModification(im)
+			case im.Type == "reaction" && im.GroupId == group.GroupId:
+				displayReaction(im)
+			case im.Type == "typing" && im.GroupId == group.GroupId:
+				if shouldShowTyping(im.From) {
+					fmt.Printf("[%s 正在输入...]\n", im.From)
+				}
+			case im.Type == "notification":
+				fmt.Printf("[通知] 来自%s: %s\n", im.From, im.Content)
+			case im.Type == "error":
+				fmt.Println("错误消息：", im.Content)
+			}
+		}
+	}()
+
+	for {
+		text := readLine("", nil)
+		switch text {
+		case "":
+			continue
+		case "/exit":
+			close(quit)
+			return
+		case "/history":
+			oldestID, _ = showGroupHistory(group.GroupId, oldestID)
+			continue
+		}
+		if strings.HasPrefix(text, "/download") {
+			handleDownloadCommand(strings.Fields(text))
+			continue
+		}
+		if strings.HasPrefix(text, "/recall") || strings.HasPrefix(text, "/edit") {
+			handleModifyCommand(c, text)
+			continue
+		}
+		if strings.HasPrefix(text, "/reply") || strings.HasPrefix(text, "/forward") {
+			handleReferenceCommand(c, text, "", group.GroupId)
+			continue
+		}
+		if strings.HasPrefix(text, "/react") || strings.HasPrefix(text, "/unreact") {
+			handleReactionCommand(c, text)
+			continue
+		}
+		if strings.HasPrefix(text, "/search") {
+			handleSearchCommand(text, "", group.GroupId)
+			continue
+		}
+		msg := &pb.IMMessage{
+			Type:      "group_chat",
+			From:      savedUID,
+			GroupId:   group.GroupId,
+			Content:   replaceEmojis(text),
+			Timestamp: time.Now().Unix(),
+		}
+		if err := wsSend(c, msg); err != nil {
+			// 连接断开时后台自动重连，保留在聊天界面
+			fmt.Println("发送消息失败，请稍后重试:", err)
+			continue
+		}
+	}
+}
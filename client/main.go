@@ -5,7 +5,6 @@ import (
 	"fmt"
 	pb "im/core/protocol/pb"
 	"io/ioutil"
-	"net/http"
 	"strings"
 	"time"
 
@@ -13,7 +12,6 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-var savedToken string
 var savedUID string
 
 // 声明外部依赖，确保 main.go 能访问 user.go、util.go 的符号
@@ -31,8 +29,8 @@ func main() {
 	// l.SetCtrlCAborts(true)
 	var notifyStop chan struct{}
 	for {
-		if savedToken == "" {
-			fmt.Println("1. 注册 2. 登录 0. 退出")
+		if currentToken() == "" {
+			fmt.Println("1. 注册 2. 登录 3. 找回密码 0. 退出")
 			opStr := readLine("选择操作: ", nil)
 			var op int
 			fmt.Sscanf(opStr, "%d", &op)
@@ -41,18 +39,23 @@ func main() {
 				u := readLine("昵称: ", nil)
 				p := readLine("密码: ", nil)
 				email := readLine("邮箱: ", nil)
-				register(u, p, email)
+				uid := readLine("自选UID（留空则自动分配）: ", nil)
+				register(u, p, email, uid)
 			case 2:
 				uid := readLine("UID: ", nil)
 				p := readLine("密码: ", nil)
 				login(uid, p)
-				if savedToken != "" {
+				if currentToken() != "" {
 					if notifyStop != nil {
 						close(notifyStop)
 					}
 					notifyStop = make(chan struct{})
-					go wsNotifyListener(savedToken, notifyStop)
+					go wsNotifyListener(notifyStop)
 				}
+			case 3:
+				email := readLine("邮箱: ", nil)
+				newPwd := readLine("新密码: ", nil)
+				resetPwd(email, newPwd, nil)
 			case 0:
 				return
 			}
@@ -63,7 +66,7 @@ func main() {
 					fmt.Println("[通知]", msg)
 				default:
 				}
-				fmt.Println("1. 好友 2. 个人中心 3. 退出")
+				fmt.Println("1. 好友 2. 群组 3. 个人中心 4. 退出")
 				opStr := readLine("选择操作: ", nil)
 				var op int
 				fmt.Sscanf(opStr, "%d", &op)
@@ -71,8 +74,10 @@ func main() {
 				case 1:
 					friendMenu(nil)
 				case 2:
-					userMenu(nil)
+					groupMenu(nil)
 				case 3:
+					userMenu(nil)
+				case 4:
 					if notifyStop != nil {
 						close(notifyStop)
 					}
@@ -84,32 +89,49 @@ func main() {
 }
 
 // WebSocket通知监听，支持关闭
-func wsNotifyListener(token string, stop chan struct{}) {
-	c, _, err := websocket.DefaultDialer.Dial("ws://127.0.0.1:8090/ws", nil)
+func wsNotifyListener(stop chan struct{}) {
+	c, err := dialWS("notify")
 	if err != nil {
 		return
 	}
-	defer c.Close()
-	loginMsg := &pb.IMMessage{Type: "login", Token: token}
-	b, _ := proto.Marshal(loginMsg)
-	c.WriteMessage(websocket.BinaryMessage, b)
+	setNotifyConn(c)
+	defer setNotifyConn(nil)
+	// 登出时关闭连接，结束阻塞中的读取，不再重连
+	go func() {
+		<-stop
+		c.Close()
+	}()
 	for {
-		select {
-		case <-stop:
-			return
-		default:
-		}
-		_, msg, err := c.ReadMessage()
+		im, err := wsRead(c)
 		if err != nil {
 			return
 		}
-		var im pb.IMMessage
-		err = proto.Unmarshal(msg, &im)
-		if err == nil && im.Type == "notification" {
+		switch im.Type {
+		case "notification":
 			select {
 			case notifyChan <- fmt.Sprintf("来自%s: %s", im.From, im.Content):
 			default:
 			}
+		case "chat", "emoji", "image", "file", "group_chat":
+			// 聊天界面打开时由聊天连接展示，这里只确认送达；自己在其他设备发出的消息不提示
+			if isChatScreenActive() || im.From == savedUID {
+				if im.MsgId != 0 {
+					ackMessage(c, im.MsgId)
+				}
+				continue
+			}
+			if !receiveMessage(c, im) {
+				continue
+			}
+			select {
+			case notifyChan <- fmt.Sprintf("新消息 来自%s: %s", im.From, formatMessageContent(im)):
+			default:
+			}
+		case "presence":
+			select {
+			case notifyChan <- fmt.Sprintf("好友 %s %s", im.From, presenceChangeText[im.Content]):
+			default:
+			}
 		}
 	}
 }
@@ -122,9 +144,10 @@ func friendMenu(_ interface{}) {
 		fmt.Sscanf(opStr, "%d", &op)
 		switch op {
 		case 1:
-			friends := getFriendList(savedUID, savedToken)
-			friendUsernames := getFriendUsernames(savedUID, savedToken)
-			remarks := getFriendRemarks(savedUID, savedToken)
+			friends := getFriendList(savedUID, currentToken())
+			friendUsernames := getFriendUsernames(savedUID, currentToken())
+			remarks := getFriendRemarks(savedUID, currentToken())
+			detail := getFriendListDetail(savedUID, currentToken())
 			if len(friends) == 0 {
 				fmt.Println("暂无好友")
 				continue
@@ -134,7 +157,15 @@ func friendMenu(_ interface{}) {
 				if i < len(remarks) && remarks[i] != "" {
 					name = remarks[i]
 				}
-				fmt.Printf("%d. %s(%s)\n", i+1, name, f)
+				var unread, lastSeen int64
+				var presence string
+				if i < len(detail.UnreadCounts) {
+					unread = detail.UnreadCounts[i]
+				}
+				if i < len(detail.Presences) && i < len(detail.LastSeens) {
+					presence, lastSeen = detail.Presences[i], detail.LastSeens[i]
+				}
+				fmt.Printf("%d. %s(%s) %s%s\n", i+1, name, f, formatPresence(presence, lastSeen), unreadSuffix(unread))
 			}
 			idxStr := readLine("选择好友编号进入详情(0返回): ", nil)
 			var idx int
@@ -145,9 +176,9 @@ func friendMenu(_ interface{}) {
 		case 2:
 			toUid := readLine("对方UID: ", nil)
 			msg := readLine("验证消息: ", nil)
-			addFriend(savedUID, toUid, msg, savedToken)
+			addFriend(savedUID, toUid, msg, currentToken())
 		case 3:
-			fromUids, fromUsernames, msgs := getFriendRequestListWithNames(savedUID, savedToken)
+			fromUids, fromUsernames, msgs := getFriendRequestListWithNames(savedUID, currentToken())
 			if len(fromUids) == 0 {
 				fmt.Println("暂无好友请求")
 				continue
@@ -175,7 +206,7 @@ func friendMenu(_ interface{}) {
 					acceptStr := readLine("同意? (y/n): ", nil)
 					acceptStr = strings.TrimSpace(acceptStr)
 					accept := acceptStr == "y" || acceptStr == "Y"
-					handleFriend(fromUids[idx-1], savedUID, accept, savedToken)
+					handleFriend(fromUids[idx-1], savedUID, accept, currentToken())
 					break
 				} else {
 					fmt.Println("编号超出范围")
@@ -190,7 +221,7 @@ func friendMenu(_ interface{}) {
 func getFriendList(uid, token string) []string {
 	req := &pb.FriendListReq{Uid: uid, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/friend_list", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/friend_list", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("获取好友列表失败:", err)
 		return nil
@@ -213,7 +244,7 @@ func getFriendList(uid, token string) []string {
 func getFriendRequestList(uid, token string) ([]string, []string) {
 	req := &pb.FriendListReq{Uid: uid, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/friend_request_list", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/friend_request_list", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("获取好友请求失败:", err)
 		return nil, nil
@@ -242,21 +273,22 @@ func friendDetailMenu(_ interface{}, friendUid string) {
 		fmt.Sscanf(opStr, "%d", &op)
 		switch op {
 		case 1:
-			info := getFriendInfo(savedUID, friendUid, savedToken)
-			fmt.Printf("UID: %s\n昵称: %s\n邮箱: %s\n备注: %s\n", info.Uid, info.Username, info.Email, info.Remark)
+			info := getFriendInfo(savedUID, friendUid, currentToken())
+			fmt.Printf("UID: %s\n昵称: %s\n邮箱: %s\n备注: %s\n状态: %s\n", info.Uid, info.Username, info.Email, info.Remark,
+				formatPresence(info.Presence, info.LastSeen))
 		case 2:
 			remark := readLine("输入备注: ", nil)
-			setFriendRemark(savedUID, friendUid, remark, savedToken)
+			setFriendRemark(savedUID, friendUid, remark, currentToken())
 		case 3:
-			cur := getDND(savedUID, friendUid, savedToken)
+			cur := getDND(savedUID, friendUid, currentToken())
 			fmt.Printf("当前免打扰状态: %v\n", cur)
 			setStr := readLine("是否开启免打扰? (y/n): ", nil)
 			set := setStr == "y" || setStr == "Y"
-			setDND(savedUID, friendUid, set, savedToken)
+			setDND(savedUID, friendUid, set, currentToken())
 		case 4:
 			wsChatWithFriendExtended(nil, friendUid)
 		case 5:
-			deleteFriend(savedUID, friendUid, savedToken)
+			deleteFriend(savedUID, friendUid, currentToken())
 			return
 		case 0:
 			return
@@ -266,7 +298,7 @@ func friendDetailMenu(_ interface{}, friendUid string) {
 
 func userMenu(_ interface{}) {
 	for {
-		fmt.Println("1. 修改昵称 2. 修改密码 3. 注销账号 4. 查看个人信息 5. 登出 0. 返回")
+		fmt.Println("1. 修改昵称 2. 修改密码 3. 注销账号 4. 查看个人信息 5. 登出 6. 设置在线状态 7. 修改邮箱 8. 两步验证 0. 返回")
 		opStr := readLine("选择操作: ", nil)
 		var op int
 		fmt.Sscanf(opStr, "%d", &op)
@@ -277,10 +309,15 @@ func userMenu(_ interface{}) {
 		case 2:
 			oldPwd := readLine("原密码: ", nil)
 			newPwd := readLine("新密码: ", nil)
-			updatePwd(savedUID, oldPwd, newPwd)
+			if updatePwd(savedUID, oldPwd, newPwd) {
+				clearTokens()
+				savedUID = ""
+				fmt.Println("密码已修改，请重新登录")
+				return
+			}
 		case 3:
 			deleteAccount(savedUID)
-			savedToken = ""
+			clearTokens()
 			savedUID = ""
 			fmt.Println("账号已注销，已退出登录")
 			return
@@ -289,6 +326,21 @@ func userMenu(_ interface{}) {
 		case 5:
 			logout()
 			return
+		case 6:
+			statusStr := readLine("1. 在线 2. 离开: ", nil)
+			status := "online"
+			if statusStr == "2" {
+				status = "away"
+			}
+			if err := sendPresence(status); err != nil {
+				fmt.Println("设置在线状态失败:", err)
+			} else {
+				fmt.Println("在线状态已设置为", presenceChangeText[status])
+			}
+		case 7:
+			updateEmail(readLine("新邮箱: ", nil))
+		case 8:
+			twoFactorMenu()
 		case 0:
 			return
 		}
@@ -296,19 +348,19 @@ func userMenu(_ interface{}) {
 }
 
 func wsChatWithFriend(_ interface{}, friendUid string) {
-	c, _, err := websocket.DefaultDialer.Dial("ws://127.0.0.1:8090/ws", nil)
+	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
 	if err != nil {
 		fmt.Println("WebSocket 连接失败:", err)
 		return
 	}
 	defer c.Close()
-	if savedToken == "" {
+	if currentToken() == "" {
 		fmt.Println("请先登录获取token")
 		return
 	}
 
 	// 先进行WebSocket登录
-	loginMsg := &pb.IMMessage{Type: "login", Token: savedToken}
+	loginMsg := &pb.IMMessage{Type: "login", Token: currentToken()}
 	b, _ := proto.Marshal(loginMsg)
 	if err := c.WriteMessage(websocket.BinaryMessage, b); err != nil {
 		fmt.Println("WebSocket登录失败:", err)
@@ -389,7 +441,7 @@ func wsChatWithFriend(_ interface{}, friendUid string) {
 func getFriendUsernames(uid, token string) []string {
 	req := &pb.FriendListReq{Uid: uid, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/friend_list", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/friend_list", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		return nil
 	}
@@ -409,7 +461,7 @@ func getFriendUsernames(uid, token string) []string {
 func getFriendRequestListWithNames(uid, token string) ([]string, []string, []string) {
 	req := &pb.FriendListReq{Uid: uid, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/friend_request_list", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/friend_request_list", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		return nil, nil, nil
 	}
@@ -426,11 +478,42 @@ func getFriendRequestListWithNames(uid, token string) ([]string, []string, []str
 	return list.FromUids, list.FromUsernames, list.VerifyMsgs
 }
 
+// 获取好友列表的未读数与在线状态
+func getFriendListDetail(uid, token string) *pb.FriendListResp {
+	var list pb.FriendListResp
+	if _, err := postProto("/friend_list", &pb.FriendListReq{Uid: uid, Token: token}, &list); err != nil {
+		return &pb.FriendListResp{}
+	}
+	return &list
+}
+
+// 在线状态显示文本
+func formatPresence(status string, lastSeen int64) string {
+	switch status {
+	case "online":
+		return "[在线]"
+	case "away":
+		return "[离开]"
+	}
+	if lastSeen == 0 {
+		return "[离线]"
+	}
+	return fmt.Sprintf("[离线，最后在线 %s]", time.Unix(lastSeen, 0).Format("01-02 15:04"))
+}
+
+// 未读消息数提示，没有未读时为空
+func unreadSuffix(count int64) string {
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d new)", count)
+}
+
 // 新增 getFriendRemarks、setFriendRemark、getFriendInfo
 func getFriendRemarks(uid, token string) []string {
 	req := &pb.FriendListReq{Uid: uid, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/friend_list", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/friend_list", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		return nil
 	}
@@ -450,7 +533,7 @@ func getFriendRemarks(uid, token string) []string {
 func setFriendRemark(uid, friendUid, remark, token string) {
 	req := &pb.UpdateRemarkReq{Uid: uid, FriendUid: friendUid, Remark: remark, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/update_remark", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/update_remark", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("设置备注失败:", err)
 		return
@@ -468,7 +551,7 @@ func setFriendRemark(uid, friendUid, remark, token string) {
 func getFriendInfo(uid, friendUid, token string) *pb.FriendInfoResp {
 	req := &pb.FriendInfoReq{Uid: uid, FriendUid: friendUid, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/friend_info", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/friend_info", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("获取好友信息失败:", err)
 		return &pb.FriendInfoResp{}
@@ -497,7 +580,7 @@ func getDND(uid, friendUid, token string) bool {
 func setDND(uid, friendUid string, dnd bool, token string) {
 	req := &pb.SetDNDReq{Uid: uid, FriendUid: friendUid, Dnd: dnd, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/set_dnd", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/set_dnd", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("设置免打扰失败:", err)
 		return
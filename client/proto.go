@@ -1,7 +1,79 @@
 package main
 
-// 预留 Protobuf 相关适配函数和 import
-// import (
-// 	"im/core/protocol/pb"
-// 	"google.golang.org/protobuf/proto"
-// )
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"im/config"
+	pb "im/core/protocol/pb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 服务器地址，通过 IM_HTTP_URL / IM_WS_URL 或配置文件修改
+var (
+	httpBaseURL = config.GetClientConfig().HTTPURL
+	wsURL       = config.GetClientConfig().WSURL
+)
+
+// 发送POST请求，token不为空时放入 Authorization 头
+func postWithToken(url, contentType string, body io.Reader, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// 携带当前访问令牌发送GET请求
+func authGet(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := currentToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// 携带当前访问令牌发送POST请求
+func authPost(url, contentType string, body io.Reader) (*http.Response, error) {
+	return postWithToken(url, contentType, body, currentToken())
+}
+
+// 发送 Protobuf 请求并解析 APIResp，out 不为空时解析 Data 字段
+func postProto(path string, req proto.Message, out proto.Message) (*pb.APIResp, error) {
+	return sendProto(path, currentToken(), req, out)
+}
+
+// 同 postProto，使用指定的token，刷新令牌时使用以免重复加锁
+func sendProto(path, token string, req proto.Message, out proto.Message) (*pb.APIResp, error) {
+	b, _ := proto.Marshal(req)
+	r, err := postWithToken(httpBaseURL+path, "application/x-protobuf", bytes.NewReader(b), token)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer r.Body.Close()
+	respBytes, _ := ioutil.ReadAll(r.Body)
+	var resp pb.APIResp
+	if err := proto.Unmarshal(respBytes, &resp); err != nil {
+		return nil, fmt.Errorf("响应解析失败: %v", err)
+	}
+	if resp.Code != 0 {
+		return &resp, fmt.Errorf("%s", resp.Msg)
+	}
+	if out != nil {
+		if err := proto.Unmarshal(resp.Data, out); err != nil {
+			return &resp, fmt.Errorf("数据解析失败: %v", err)
+		}
+	}
+	return &resp, nil
+}
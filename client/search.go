@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pb "im/core/protocol/pb"
+)
+
+const searchPageSize = 10
+
+// 搜索结果中的关键词高亮显示
+var highlightReplacer = strings.NewReplacer("<em>", "\x1b[1;33m", "</em>", "\x1b[0m")
+
+// 处理 /search 命令，默认只搜索当前会话
+// 用法: /search <关键词> [from:UID] [type:chat|image|file] [since:2006-01-02] [until:2006-01-02] [page:N] [all]
+func handleSearchCommand(cmd string, friendUid string, groupID int64) {
+	req := &pb.SearchMessagesReq{Uid: savedUID, Token: currentToken(), FriendUid: friendUid, GroupId: groupID, Page: 1, PageSize: searchPageSize}
+	var keywords []string
+	for _, arg := range strings.Fields(cmd)[1:] {
+		switch {
+		case arg == "all":
+			req.FriendUid, req.GroupId = "", 0
+		case strings.HasPrefix(arg, "from:"):
+			req.SenderUid = strings.TrimPrefix(arg, "from:")
+		case strings.HasPrefix(arg, "type:"):
+			req.Types = append(req.Types, strings.TrimPrefix(arg, "type:"))
+		case strings.HasPrefix(arg, "since:"), strings.HasPrefix(arg, "until:"):
+			day, err := time.ParseInLocation("2006-01-02", arg[strings.Index(arg, ":")+1:], time.Local)
+			if err != nil {
+				fmt.Println("日期格式错误，应为 2006-01-02:", arg)
+				return
+			}
+			if strings.HasPrefix(arg, "since:") {
+				req.StartTime = day.Unix()
+			} else {
+				// 包含结束当天
+				req.EndTime = day.AddDate(0, 0, 1).Unix()
+			}
+		case strings.HasPrefix(arg, "page:"):
+			fmt.Sscanf(strings.TrimPrefix(arg, "page:"), "%d", &req.Page)
+		default:
+			keywords = append(keywords, arg)
+		}
+	}
+	req.Keyword = strings.Join(keywords, " ")
+	if req.Keyword == "" {
+		fmt.Println("用法: /search <关键词> [from:UID] [type:chat|image|file] [since:2006-01-02] [until:2006-01-02] [page:N] [all]")
+		return
+	}
+
+	var resp pb.SearchMessagesResp
+	if _, err := postProto("/search_messages", req, &resp); err != nil {
+		fmt.Println("搜索失败:", err)
+		return
+	}
+	if len(resp.Results) == 0 {
+		fmt.Println("--- 没有找到相关消息 ---")
+		return
+	}
+	fmt.Printf("--- 搜索结果: 共 %d 条，第 %d 页 ---\n", resp.Total, req.Page)
+	for _, r := range resp.Results {
+		m := r.Message
+		where := ""
+		if req.FriendUid == "" && req.GroupId == 0 {
+			where = conversationLabel(m) + " "
+		}
+		fmt.Printf("[#%d] %s %s%s: %s\n", m.MsgId, time.Unix(m.Timestamp, 0).Format("01-02 15:04"), where, m.From,
+			highlightReplacer.Replace(r.Snippet))
+	}
+	if resp.HasMore {
+		fmt.Printf("--- 追加 page:%d 查看下一页 ---\n", req.Page+1)
+	}
+}
+
+// 搜索结果所在会话的显示名称
+func conversationLabel(m *pb.IMMessage) string {
+	if m.GroupId != 0 {
+		return fmt.Sprintf("(群%d)", m.GroupId)
+	}
+	peer := m.To
+	if m.To == savedUID {
+		peer = m.From
+	}
+	return fmt.Sprintf("(与%s)", peer)
+}
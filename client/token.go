@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pb "im/core/protocol/pb"
+)
+
+// 访问令牌到期前多久主动刷新
+const tokenRefreshMargin = time.Minute
+
+// 当前登录的令牌，通知连接重连时会在后台读取，需加锁
+var tokens struct {
+	sync.Mutex
+	access    string
+	refresh   string
+	expiresAt time.Time
+}
+
+// 保存登录或刷新得到的令牌
+func setTokens(resp *pb.LoginResp) {
+	tokens.Lock()
+	defer tokens.Unlock()
+	tokens.access = resp.AccessToken
+	tokens.refresh = resp.RefreshToken
+	tokens.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+}
+
+func clearTokens() {
+	tokens.Lock()
+	defer tokens.Unlock()
+	tokens.access, tokens.refresh = "", ""
+}
+
+// 返回当前访问令牌，即将过期时先用刷新令牌换取新令牌
+// 刷新被服务器拒绝（已登出或已吊销）时清空令牌并返回空字符串
+func currentToken() string {
+	tokens.Lock()
+	defer tokens.Unlock()
+	if tokens.access == "" || time.Until(tokens.expiresAt) > tokenRefreshMargin {
+		return tokens.access
+	}
+	var resp pb.LoginResp
+	apiResp, err := sendProto("/refresh", "", &pb.RefreshTokenReq{RefreshToken: tokens.refresh}, &resp)
+	if err != nil {
+		// 网络错误时继续使用旧令牌，下次再尝试刷新
+		if apiResp != nil {
+			fmt.Println("登录已失效，请重新登录:", err)
+			tokens.access, tokens.refresh = "", ""
+		}
+		return tokens.access
+	}
+	tokens.access = resp.AccessToken
+	tokens.refresh = resp.RefreshToken
+	tokens.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return tokens.access
+}
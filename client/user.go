@@ -11,14 +11,19 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-func register(username, password, email string) {
+// uid 为自选UID，为空时由服务端分配
+func register(username, password, email, uid string) {
 	if len(password) < 3 || len(email) < 5 {
 		fmt.Println("密码或邮箱长度不合法")
 		return
 	}
-	req := &pb.RegisterReq{Username: username, Password: password, Email: email}
+	if !sendEmailCode(&pb.SendEmailCodeReq{Email: email, Purpose: "register"}) {
+		return
+	}
+	code := readLine("请输入邮箱收到的验证码: ", nil)
+	req := &pb.RegisterReq{Username: username, Password: password, Email: email, Code: code, Uid: uid}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/register", "application/x-protobuf", bytes.NewReader(b))
+	r, err := http.Post(httpBaseURL+"/register", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("注册请求失败:", err)
 		return
@@ -32,7 +37,7 @@ func register(username, password, email string) {
 	}
 	fmt.Println("注册响应:", resp.Msg)
 	if resp.Code == 0 {
-		fmt.Println("分配的UID:", string(resp.Data))
+		fmt.Println("你的UID:", string(resp.Data))
 	}
 }
 
@@ -43,7 +48,7 @@ func login(uid, password string) {
 	}
 	req := &pb.LoginReq{Uid: uid, Password: password}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/login", "application/x-protobuf", bytes.NewReader(b))
+	r, err := http.Post(httpBaseURL+"/login", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("登录请求失败:", err)
 		return
@@ -56,22 +61,97 @@ func login(uid, password string) {
 		return
 	}
 	fmt.Println("登录响应:", resp.Msg)
+	if resp.Code == loginNeed2FA {
+		var challengeResp pb.LoginResp
+		if err := proto.Unmarshal(resp.Data, &challengeResp); err != nil {
+			fmt.Println("响应解析失败:", err)
+			return
+		}
+		code := readLine("请输入两步验证码（或恢复码）: ", nil)
+		var tokenResp pb.LoginResp
+		if _, err := postProto("/login_2fa", &pb.Login2FAReq{Challenge: challengeResp.Challenge, Code: code}, &tokenResp); err != nil {
+			fmt.Println("两步验证失败:", err)
+			return
+		}
+		setTokens(&tokenResp)
+		savedUID = uid
+		fmt.Println("登录成功，当前UID:", uid)
+		return
+	}
 	if resp.Code == 0 {
-		savedToken = string(resp.Data)
+		var tokenResp pb.LoginResp
+		if err := proto.Unmarshal(resp.Data, &tokenResp); err != nil {
+			fmt.Println("令牌解析失败:", err)
+			return
+		}
+		setTokens(&tokenResp)
 		savedUID = uid
 		fmt.Println("当前UID:", uid)
 	}
 }
 
+// 登录需要两步验证时服务器返回的状态码
+const loginNeed2FA = 2007
+
+// 两步验证设置
+func twoFactorMenu() {
+	op := readLine("1. 开启两步验证 2. 关闭两步验证 0. 返回: ", nil)
+	switch op {
+	case "1":
+		var setup pb.TOTPSetupResp
+		if _, err := postProto("/totp_setup", &pb.TOTPSetupReq{Uid: savedUID, Token: currentToken()}, &setup); err != nil {
+			fmt.Println("开启两步验证失败:", err)
+			return
+		}
+		fmt.Println("请在验证器应用中添加以下账号（扫描URI生成的二维码或手动输入密钥）:")
+		fmt.Println("  密钥:", setup.Secret)
+		fmt.Println("  URI:", setup.OtpauthUri)
+		code := readLine("请输入验证器显示的验证码以确认: ", nil)
+		var enable pb.TOTPEnableResp
+		if _, err := postProto("/totp_enable", &pb.TOTPEnableReq{Uid: savedUID, Token: currentToken(), Code: code}, &enable); err != nil {
+			fmt.Println("开启两步验证失败:", err)
+			return
+		}
+		fmt.Println("两步验证已开启。请妥善保存以下恢复码，每个只能使用一次，丢失验证器时可用于登录:")
+		for _, c := range enable.RecoveryCodes {
+			fmt.Println("  " + c)
+		}
+	case "2":
+		code := readLine("请输入两步验证码（或恢复码）: ", nil)
+		if _, err := postProto("/totp_disable", &pb.TOTPDisableReq{Uid: savedUID, Token: currentToken(), Code: code}, nil); err != nil {
+			fmt.Println("关闭两步验证失败:", err)
+			return
+		}
+		fmt.Println("两步验证已关闭")
+	}
+}
+
+// 发送邮箱验证码，返回是否发送成功
+func sendEmailCode(req *pb.SendEmailCodeReq) bool {
+	if _, err := postProto("/send_email_code", req, nil); err != nil {
+		fmt.Println("发送验证码失败:", err)
+		return false
+	}
+	fmt.Println("验证码已发送到", req.Email)
+	return true
+}
+
 func resetPwd(email, newPwd string, _ interface{}) {
-	code := readLine("请输入邮箱验证码（模拟，输入123456）: ", nil)
+	if email == "" || newPwd == "" {
+		fmt.Println("邮箱和新密码不能为空")
+		return
+	}
+	if !sendEmailCode(&pb.SendEmailCodeReq{Email: email, Purpose: "reset_pwd"}) {
+		return
+	}
+	code := readLine("请输入邮箱收到的验证码: ", nil)
 	if email == "" || newPwd == "" || code == "" {
 		fmt.Println("邮箱、新密码和验证码不能为空")
 		return
 	}
 	req := &pb.ResetPwdReq{Email: email, NewPwd: newPwd, Code: code}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/reset_pwd", "application/x-protobuf", bytes.NewReader(b))
+	r, err := http.Post(httpBaseURL+"/reset_pwd", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("找回密码请求失败:", err)
 		return
@@ -86,6 +166,24 @@ func resetPwd(email, newPwd string, _ interface{}) {
 	fmt.Println("找回密码响应:", resp.Msg)
 }
 
+// 修改绑定邮箱，验证码发送到新邮箱
+func updateEmail(newEmail string) {
+	if newEmail == "" {
+		fmt.Println("新邮箱不能为空")
+		return
+	}
+	if !sendEmailCode(&pb.SendEmailCodeReq{Email: newEmail, Purpose: "change_email", Uid: savedUID, Token: currentToken()}) {
+		return
+	}
+	code := readLine("请输入新邮箱收到的验证码: ", nil)
+	req := &pb.UpdateEmailReq{Uid: savedUID, Token: currentToken(), NewEmail: newEmail, Code: code}
+	if _, err := postProto("/update_email", req, nil); err != nil {
+		fmt.Println("修改邮箱失败:", err)
+		return
+	}
+	fmt.Println("邮箱修改成功")
+}
+
 func updateUsername(uid, newUsername string) {
 	if uid == "" || newUsername == "" {
 		fmt.Println("UID和新昵称不能为空")
@@ -93,7 +191,7 @@ func updateUsername(uid, newUsername string) {
 	}
 	req := &pb.UpdateUsernameReq{Uid: uid, NewUsername: newUsername}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/update_username", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/update_username", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("修改昵称请求失败:", err)
 		return
@@ -108,26 +206,28 @@ func updateUsername(uid, newUsername string) {
 	fmt.Println("修改昵称响应:", resp.Msg)
 }
 
-func updatePwd(uid, oldPwd, newPwd string) {
+// 修改密码，成功后服务器会吊销全部令牌，返回 true 表示需要重新登录
+func updatePwd(uid, oldPwd, newPwd string) bool {
 	if uid == "" || oldPwd == "" || newPwd == "" {
 		fmt.Println("UID、原密码和新密码不能为空")
-		return
+		return false
 	}
 	req := &pb.UpdatePwdReq{Uid: uid, OldPwd: oldPwd, NewPwd: newPwd}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/update_pwd", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/update_pwd", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("修改密码请求失败:", err)
-		return
+		return false
 	}
 	defer r.Body.Close()
 	respBytes, _ := ioutil.ReadAll(r.Body)
 	var resp pb.APIResp
 	if err := proto.Unmarshal(respBytes, &resp); err != nil {
 		fmt.Println("响应解析失败:", err)
-		return
+		return false
 	}
 	fmt.Println("修改密码响应:", resp.Msg)
+	return resp.Code == 0
 }
 
 func deleteAccount(uid string) {
@@ -137,7 +237,7 @@ func deleteAccount(uid string) {
 	}
 	req := &pb.DeleteAccountReq{Uid: uid}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/delete_account", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/delete_account", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("注销账号请求失败:", err)
 		return
@@ -155,13 +255,13 @@ func deleteAccount(uid string) {
 // 删除 userInfo 的实现
 
 func logout() {
-	if savedToken == "" {
+	if currentToken() == "" {
 		fmt.Println("未登录，无需登出")
 		return
 	}
-	req := &pb.LogoutReq{Token: savedToken}
+	req := &pb.LogoutReq{Token: currentToken()}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/logout", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/logout", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("登出请求失败:", err)
 		return
@@ -175,7 +275,7 @@ func logout() {
 	}
 	fmt.Println("登出响应:", resp.Msg)
 	if resp.Code == 0 {
-		savedToken = ""
+		clearTokens()
 		savedUID = ""
 	}
 }
@@ -188,7 +288,7 @@ func addFriend(fromUid, toUid, verifyMsg, token string) {
 	}
 	req := &pb.AddFriendReq{FromUid: fromUid, ToUid: toUid, VerifyMsg: verifyMsg, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/add_friend", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/add_friend", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("添加好友请求失败:", err)
 		return
@@ -211,7 +311,7 @@ func handleFriend(fromUid, toUid string, accept bool, token string) {
 	}
 	req := &pb.HandleFriendReq{FromUid: fromUid, ToUid: toUid, Accept: accept, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/handle_friend", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/handle_friend", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("处理好友请求失败:", err)
 		return
@@ -234,7 +334,7 @@ func friendList(uid, token string) {
 	}
 	req := &pb.FriendListReq{Uid: uid, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/friend_list", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/friend_list", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("获取好友列表失败:", err)
 		return
@@ -262,7 +362,7 @@ func deleteFriend(uid, friendUid, token string) {
 	}
 	req := &pb.DeleteFriendReq{Uid: uid, FriendUid: friendUid, Token: token}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/delete_friend", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/delete_friend", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("删除好友请求失败:", err)
 		return
@@ -278,13 +378,13 @@ func deleteFriend(uid, friendUid, token string) {
 }
 
 func userInfo() {
-	if savedToken == "" {
+	if currentToken() == "" {
 		fmt.Println("请先登录")
 		return
 	}
-	req := &pb.UserInfoReq{Token: savedToken}
+	req := &pb.UserInfoReq{Token: currentToken()}
 	b, _ := proto.Marshal(req)
-	r, err := http.Post("http://localhost:8081/user_info", "application/x-protobuf", bytes.NewReader(b))
+	r, err := authPost(httpBaseURL+"/user_info", "application/x-protobuf", bytes.NewReader(b))
 	if err != nil {
 		fmt.Println("用户信息请求失败:", err)
 		return
@@ -33,3 +33,17 @@ func readLine(prompt string, _ interface{}) string {
 	}
 	return line
 }
+
+// 设置输入时的按键回调，输入框有内容且不是命令时调用 fn，fn 为 nil 时取消
+func setKeyListener(fn func()) {
+	if fn == nil {
+		rl.Config.Listener = nil
+		return
+	}
+	rl.Config.SetListener(func(line []rune, pos int, key rune) ([]rune, int, bool) {
+		if len(line) > 0 && line[0] != '/' {
+			fn()
+		}
+		return nil, 0, false
+	})
+}
@@ -1 +1,345 @@
 package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "im/core/protocol/pb"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// 超过该时间未收到服务器任何数据（包括心跳 ping）视为服务器已断开
+const serverTimeout = 90 * time.Second
+
+// 本客户端的设备ID，通知连接与聊天连接使用不同后缀，互不顶替
+var deviceID = newDeviceID()
+
+func newDeviceID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("cli-%s-%d", host, os.Getpid())
+}
+
+// 是否处于聊天界面，聊天界面打开时新消息由聊天连接展示
+var chatScreenActive int32
+
+func setChatScreenActive(active bool) {
+	if active {
+		atomic.StoreInt32(&chatScreenActive, 1)
+	} else {
+		atomic.StoreInt32(&chatScreenActive, 0)
+	}
+}
+
+func isChatScreenActive() bool {
+	return atomic.LoadInt32(&chatScreenActive) == 1
+}
+
+const (
+	wsWriteTimeout    = 10 * time.Second // 单次写超时
+	reconnectMinDelay = time.Second      // 断线重连初始等待时间
+	reconnectMaxDelay = 30 * time.Second // 断线重连最长等待时间
+)
+
+var errClientClosed = errors.New("连接已关闭")
+
+// 自动重连的WebSocket连接，连接断开后按指数退避重新登录，并请求补发断线期间的消息
+type wsClient struct {
+	purpose   string
+	mu        sync.Mutex // 保护 conn，gorilla/websocket 不允许并发写，写操作同样在此锁内完成
+	conn      *websocket.Conn
+	lastMsgID int64 // 收到的最后一条消息ID
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// 登录被服务器拒绝（如 token 失效），重连无意义
+type loginRejectedError struct {
+	reason string
+}
+
+func (e *loginRejectedError) Error() string {
+	return "WebSocket登录失败: " + e.reason
+}
+
+// 建立WebSocket连接并使用当前token登录，purpose 区分同一客户端的不同连接
+func dialWS(purpose string) (*wsClient, error) {
+	if currentToken() == "" {
+		return nil, fmt.Errorf("请先登录获取token")
+	}
+	c := &wsClient{purpose: purpose, closed: make(chan struct{})}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 拨号并登录，登录消息携带收到的最后一条消息ID，服务器据此补发断线期间的消息
+func (c *wsClient) connect() error {
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		return fmt.Errorf("WebSocket 连接失败: %v", err)
+	}
+	// 回复服务器心跳，并在收到数据时顺延读超时
+	conn.SetReadDeadline(time.Now().Add(serverTimeout))
+	conn.SetPingHandler(func(appData string) error {
+		conn.SetReadDeadline(time.Now().Add(serverTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(time.Second))
+	})
+
+	// 先进行WebSocket登录
+	login := &pb.IMMessage{
+		Type:     "login",
+		Token:    currentToken(),
+		DeviceId: deviceID + "/" + c.purpose,
+		MsgId:    atomic.LoadInt64(&c.lastMsgID),
+	}
+	b, _ := proto.Marshal(login)
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		conn.Close()
+		return fmt.Errorf("WebSocket登录失败: %v", err)
+	}
+
+	// 等待登录响应
+	_, loginResp, err := conn.ReadMessage()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("读取登录响应失败: %v", err)
+	}
+	var loginResponse pb.IMMessage
+	if err := proto.Unmarshal(loginResp, &loginResponse); err != nil {
+		conn.Close()
+		return fmt.Errorf("解析登录响应失败: %v", err)
+	}
+	if loginResponse.Type == "error" {
+		conn.Close()
+		return &loginRejectedError{reason: loginResponse.Content}
+	}
+	// 首次登录从服务器当前最新消息开始记录，之后的消息都会在重连时补发
+	atomic.CompareAndSwapInt64(&c.lastMsgID, 0, loginResponse.MsgId)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+		conn.Close()
+		return errClientClosed
+	default:
+	}
+	c.conn = conn
+	return nil
+}
+
+// 按指数退避重连，直到成功、连接被关闭或登录被拒绝
+func (c *wsClient) reconnect() error {
+	delay := reconnectMinDelay
+	for {
+		select {
+		case <-c.closed:
+			return errClientClosed
+		case <-time.After(delay):
+		}
+		err := c.connect()
+		if err == nil {
+			return nil
+		}
+		var rejected *loginRejectedError
+		if errors.As(err, &rejected) || errors.Is(err, errClientClosed) {
+			return err
+		}
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+}
+
+func (c *wsClient) current() *websocket.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+// 记录收到的最后一条消息ID
+func (c *wsClient) trackMsgID(msgID int64) {
+	for {
+		last := atomic.LoadInt64(&c.lastMsgID)
+		if msgID <= last || atomic.CompareAndSwapInt64(&c.lastMsgID, last, msgID) {
+			return
+		}
+	}
+}
+
+// 关闭连接，不再重连
+func (c *wsClient) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// 读取一条消息并顺延读超时，连接断开时自动重连，仅在连接被关闭或无法重新登录时返回错误
+func wsRead(c *wsClient) (*pb.IMMessage, error) {
+	for {
+		conn := c.current()
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-c.closed:
+				return nil, err
+			default:
+			}
+			conn.Close()
+			if err := c.reconnect(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(serverTimeout))
+		var im pb.IMMessage
+		if err := proto.Unmarshal(data, &im); err != nil {
+			continue
+		}
+		if im.MsgId != 0 {
+			c.trackMsgID(im.MsgId)
+		}
+		// 自己发出的消息，重连补发时不再重复展示
+		if im.Type == "sent" {
+			markSeen(im.MsgId)
+			atomic.StoreInt64(&lastSent, im.MsgId)
+		}
+		return &im, nil
+	}
+}
+
+// 自己最后发送的消息ID，/recall、/edit 未指定消息时使用
+var lastSent int64
+
+func lastSentMsgID() int64 {
+	return atomic.LoadInt64(&lastSent)
+}
+
+// 发送 IMMessage，重连期间发送会失败，由调用方提示用户重试
+func wsSend(c *wsClient, msg *pb.IMMessage) error {
+	b, _ := proto.Marshal(msg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteMessage(websocket.BinaryMessage, b)
+}
+
+// 向服务器确认消息已送达
+func ackMessage(c *wsClient, msgID int64) error {
+	return wsSend(c, &pb.IMMessage{Type: "ack", MsgId: msgID})
+}
+
+// 上报会话已读到 msgID，单聊传 friendUid，群聊传 groupID
+func sendRead(c *wsClient, friendUid string, groupID, msgID int64) error {
+	if msgID == 0 {
+		return nil
+	}
+	return wsSend(c, &pb.IMMessage{Type: "read", To: friendUid, GroupId: groupID, MsgId: msgID})
+}
+
+const (
+	typingInterval     = 3 * time.Second  // 正在输入提示的最小发送间隔
+	typingShowInterval = 10 * time.Second // 同一用户的正在输入提示最小显示间隔
+)
+
+// 返回按键回调，节流发送正在输入提示，单聊传 friendUid，群聊传 groupID
+func newTypingNotifier(c *wsClient, friendUid string, groupID int64) func() {
+	var last int64
+	return func() {
+		now := time.Now().UnixNano()
+		prev := atomic.LoadInt64(&last)
+		if now-prev < int64(typingInterval) || !atomic.CompareAndSwapInt64(&last, prev, now) {
+			return
+		}
+		// 在输入协程中回调，发送放到后台避免卡住输入
+		go wsSend(c, &pb.IMMessage{Type: "typing", To: friendUid, GroupId: groupID})
+	}
+}
+
+// 各用户上次显示正在输入提示的时间
+var typingShown = struct {
+	sync.Mutex
+	at map[string]time.Time
+}{at: make(map[string]time.Time)}
+
+// 是否显示该用户的正在输入提示，持续输入时不重复刷屏
+func shouldShowTyping(from string) bool {
+	typingShown.Lock()
+	defer typingShown.Unlock()
+	now := time.Now()
+	if now.Sub(typingShown.at[from]) < typingShowInterval {
+		return false
+	}
+	typingShown.at[from] = now
+	return true
+}
+
+// 在线状态变化提示
+var presenceChangeText = map[string]string{
+	"online":  "已上线",
+	"away":    "已离开",
+	"offline": "已下线",
+}
+
+// 通知连接，在线状态通过它上报
+var notifyConn struct {
+	sync.Mutex
+	c *wsClient
+}
+
+func setNotifyConn(c *wsClient) {
+	notifyConn.Lock()
+	defer notifyConn.Unlock()
+	notifyConn.c = c
+}
+
+// 上报在线状态: online 或 away
+func sendPresence(status string) error {
+	notifyConn.Lock()
+	c := notifyConn.c
+	notifyConn.Unlock()
+	if c == nil {
+		return fmt.Errorf("通知连接未建立")
+	}
+	return wsSend(c, &pb.IMMessage{Type: "presence", Content: status})
+}
+
+// 已收到的消息ID，服务器重发时用于去重
+var seenMessages = struct {
+	sync.Mutex
+	ids map[int64]bool
+}{ids: make(map[int64]bool)}
+
+// 标记消息已收到，首次收到返回 true
+func markSeen(msgID int64) bool {
+	seenMessages.Lock()
+	defer seenMessages.Unlock()
+	if seenMessages.ids[msgID] {
+		return false
+	}
+	seenMessages.ids[msgID] = true
+	return true
+}
+
+// 确认并去重收到的消息，返回是否需要展示
+func receiveMessage(c *wsClient, im *pb.IMMessage) bool {
+	if im.MsgId == 0 {
+		return true
+	}
+	ackMessage(c, im.MsgId)
+	return markSeen(im.MsgId)
+}
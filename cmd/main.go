@@ -3,26 +3,63 @@ package main
 import (
 	"fmt"
 	"im/api"
+	"im/config"
+	"im/core/auth"
 	"im/core/plugin"
 	"im/core/protocol"
 	pb "im/core/protocol/pb"
+	"im/core/service"
 	"im/core/storage"
 	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
 
-	"github.com/gorilla/websocket"
 	"google.golang.org/protobuf/proto"
 )
 
+// 消息撤回、编辑的时间窗口
+var messageConfig = config.GetMessageConfig()
+
+// 基于角色的权限控制，与 HTTP 接口共用权限缓存
+var authorizer = api.Authorizer
+
 func main() {
+	// 加载并校验配置，配置错误时拒绝启动
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("配置校验失败:\n%v", err)
+	}
+
 	// 获取存储管理器
 	storageManager := storage.GetStorageManager()
 
-	// 初始化MySQL存储
-	log.Println("正在初始化MySQL存储...")
-	if err := storageManager.InitMySQL(); err != nil {
-		log.Fatal("MySQL存储初始化失败:", err)
+	// 按配置初始化存储
+	log.Println("正在初始化存储...")
+	if err := storageManager.Init(); err != nil {
+		log.Fatal("存储初始化失败:", err)
+	}
+	log.Println("存储初始化成功")
+
+	// 加载令牌吊销列表，重启后已吊销的令牌仍然无效
+	if err := auth.LoadRevokedTokens(); err != nil {
+		log.Fatal("加载令牌吊销列表失败:", err)
+	}
+
+	// 初始化角色权限，并授予配置中的系统管理员角色
+	if err := authorizer.InitPermissions(); err != nil {
+		log.Fatal("初始化角色权限失败:", err)
+	}
+	for _, uid := range cfg.Server.Admins {
+		if err := authorizer.GrantRole(uid, auth.RoleAdmin); err != nil {
+			log.Printf("授予用户 %s 管理员角色失败: %v", uid, err)
+		}
+	}
+
+	// 清理一天前的邮箱验证码记录，发送频率限制只统计最近一小时
+	if err := storageManager.DeleteEmailCodesBefore(time.Now().Add(-24 * time.Hour).Unix()); err != nil {
+		log.Printf("清理过期验证码失败: %v", err)
 	}
-	log.Println("MySQL存储初始化成功")
 
 	// 程序结束时关闭存储
 	defer func() {
@@ -35,46 +72,419 @@ func main() {
 	}
 
 	go func() {
-		fmt.Println("HTTP 用户服务监听于 :8081")
-		api.StartHTTPServer(":8081")
+		fmt.Println("HTTP 用户服务监听于", cfg.Server.HTTPAddr)
+		api.StartHTTPServer(cfg.Server.HTTPAddr)
 	}()
 
 	go func() {
 		wsProto := protocol.NewWSProtocol()
-		wsProto.OnMessage(func(conn *websocket.Conn, data []byte) {
+		wsConfig := cfg.WebSocket
+		wsProto.SendQueueSize = wsConfig.SendQueueSize
+		wsProto.WriteTimeout = wsConfig.WriteTimeout
+		wsProto.PingInterval = wsConfig.PingInterval
+		wsProto.PongTimeout = wsConfig.PongTimeout
+		wsProto.OnMessage(func(conn *protocol.WSConn, data []byte) {
 			var msg pb.IMMessage
 			if err := proto.Unmarshal(data, &msg); err != nil {
-				errMsg := &pb.IMMessage{Type: "error", Content: "消息格式错误"}
-				b, _ := proto.Marshal(errMsg)
-				conn.WriteMessage(websocket.BinaryMessage, b)
+				replyError(conn, "消息格式错误")
 				return
 			}
+			// 引用信息由服务器填充，忽略客户端传入的值
+			msg.Quoted = nil
+			msg.ForwardFromUid = ""
+			msg.Reactions = nil
+			if msg.Type != "forward" {
+				msg.ForwardFromId = 0
+			}
+			switch {
+			case msg.Type == "forward":
+				handleForwardMessage(conn, &msg)
+			case msg.Type == "group_chat":
+				handleGroupMessage(conn, &msg)
+			case msg.Type == "read":
+				handleReadMessage(conn, &msg)
+			case msg.Type == "typing":
+				handleTypingMessage(conn, &msg)
+			case msg.Type == "recall" || msg.Type == "edit":
+				handleModifyMessage(conn, &msg)
+			case msg.Type == "reaction":
+				handleReactionMessage(conn, &msg)
 			// 支持多种消息类型：chat, emoji, image, file
-			if (msg.Type == "chat" || msg.Type == "emoji" || msg.Type == "image" || msg.Type == "file") && msg.To != "" {
-				b, _ := proto.Marshal(&msg)
-				err := protocol.SendToUser(msg.To, b)
-				if err != nil {
-					errMsg := &pb.IMMessage{Type: "error", Content: "对方不在线"}
-					b, _ := proto.Marshal(errMsg)
-					conn.WriteMessage(websocket.BinaryMessage, b)
-				} else {
-					// 聊天通知+免打扰
-					if !protocol.StorageFriendStoreGetDND(msg.To, msg.From) {
-						notif := &pb.Notification{
-							Type:      "chat_message",
-							From:      msg.From,
-							To:        msg.To,
-							Content:   msg.Content,
-							Timestamp: msg.Timestamp,
-						}
-						_ = protocol.SendNotificationToUser(msg.To, notif)
-					}
-				}
+			case protocol.IsStorableType(msg.Type) && msg.To != "":
+				handleChatMessage(conn, &msg)
 			}
 		})
-		fmt.Println("WebSocket 服务监听于 :8090/ws")
-		wsProto.Start(":8090")
+		fmt.Println("WebSocket 服务监听于", cfg.Server.WSAddr+"/ws")
+		wsProto.Start(cfg.Server.WSAddr)
 	}()
 
 	select {} // 阻塞主进程，防止退出
 }
+
+// 回复错误消息
+func replyError(conn *protocol.WSConn, content string) {
+	errMsg := &pb.IMMessage{Type: "error", Content: content}
+	b, _ := proto.Marshal(errMsg)
+	conn.Send(b)
+}
+
+// 持久化消息，回执发送方服务器分配的消息ID并同步到其他设备，recipients 未确认前保留为离线消息
+func saveMessage(conn *protocol.WSConn, msg *pb.IMMessage, recipients []string) bool {
+	if msg.ReplyToId != 0 && !checkReplyTarget(msg) {
+		replyError(conn, "回复的消息不存在或不在当前会话中")
+		return false
+	}
+	stored := protocol.MessageFromPB(msg)
+	id, err := service.GetIDGenerator().NextMessageID()
+	if err == nil {
+		stored.ID = id
+		_, err = storage.GetStorageManager().SaveMessage(stored, recipients)
+	}
+	if err != nil {
+		log.Printf("保存消息失败: %v", err)
+		replyError(conn, "消息发送失败")
+		return false
+	}
+	msg.MsgId = stored.ID
+	protocol.AttachReferences([]*pb.IMMessage{msg})
+	sent := &pb.IMMessage{Type: "sent", To: msg.To, GroupId: msg.GroupId, MsgId: msg.MsgId, Timestamp: msg.Timestamp}
+	b, _ := proto.Marshal(sent)
+	conn.Send(b)
+	// 同步给发送方的其他在线设备
+	b, _ = proto.Marshal(msg)
+	_ = protocol.SyncToOtherDevices(msg.From, conn, b)
+	return true
+}
+
+// 消息所在会话的全部成员：单聊双方或全体群成员
+func conversationMembers(m *storage.Message) []string {
+	if m.GroupID == 0 {
+		return []string{m.FromUserID, m.ToUserID}
+	}
+	members, err := storage.GetStorageManager().GetGroupMembers(m.GroupID)
+	if err != nil {
+		log.Printf("获取群 %d 成员失败: %v", m.GroupID, err)
+		return nil
+	}
+	var uids []string
+	for _, member := range members {
+		uids = append(uids, member.UserID)
+	}
+	return uids
+}
+
+// 回复的消息必须未撤回且属于同一会话，避免把会话外的内容引用给对方
+func checkReplyTarget(msg *pb.IMMessage) bool {
+	target, err := storage.GetStorageManager().GetMessage(msg.ReplyToId)
+	if err != nil || target.Recalled {
+		return false
+	}
+	if msg.GroupId != 0 {
+		return target.GroupID == msg.GroupId
+	}
+	return target.GroupID == 0 &&
+		((target.FromUserID == msg.From && target.ToUserID == msg.To) ||
+			(target.FromUserID == msg.To && target.ToUserID == msg.From))
+}
+
+// 转发消息：复制原消息内容（图片、文件只复制下载地址，无需重新上传），按目标发往好友或群
+func handleForwardMessage(conn *protocol.WSConn, msg *pb.IMMessage) {
+	if msg.ForwardFromId == 0 {
+		replyError(conn, "缺少要转发的消息ID")
+		return
+	}
+	if msg.To == "" && msg.GroupId == 0 {
+		replyError(conn, "缺少转发对象")
+		return
+	}
+	original, err := storage.GetStorageManager().GetMessage(msg.ForwardFromId)
+	if err != nil || original.Recalled || !protocol.CanSeeMessage(msg.From, original) {
+		replyError(conn, "要转发的消息不存在")
+		return
+	}
+	forward := &pb.IMMessage{
+		Type:      original.Type,
+		From:      msg.From,
+		To:        msg.To,
+		GroupId:   msg.GroupId,
+		Content:   original.Content,
+		Extra:     original.Extra,
+		Filename:  original.Filename,
+		Filesize:  original.Filesize,
+		MimeType:  original.MimeType,
+		Timestamp: time.Now().Unix(),
+		// 转发的转发仍指向最初的消息
+		ForwardFromId: original.ID,
+	}
+	if original.ForwardFromID != 0 {
+		forward.ForwardFromId = original.ForwardFromID
+	}
+	if msg.GroupId != 0 {
+		if forward.Type == "chat" {
+			forward.Type = "group_chat"
+		}
+		handleGroupMessage(conn, forward)
+		return
+	}
+	if forward.Type == "group_chat" {
+		forward.Type = "chat"
+	}
+	handleChatMessage(conn, forward)
+}
+
+// 单聊消息
+func handleChatMessage(conn *protocol.WSConn, msg *pb.IMMessage) {
+	msg.GroupId = 0
+	// 先持久化，对方不在线时作为离线消息在其登录后送达
+	if !saveMessage(conn, msg, []string{msg.To}) {
+		return
+	}
+	b, _ := proto.Marshal(msg)
+	if err := protocol.SendWithAck(msg.To, msg.MsgId, b); err != nil {
+		// 对方不在线，消息已保存为离线消息
+		return
+	}
+	// 聊天通知+免打扰
+	if !protocol.StorageFriendStoreGetDND(msg.To, msg.From) {
+		notif := &pb.Notification{
+			Type:      "chat_message",
+			From:      msg.From,
+			To:        msg.To,
+			Content:   msg.Content,
+			Timestamp: msg.Timestamp,
+		}
+		_ = protocol.SendNotificationToUser(msg.To, notif)
+	}
+}
+
+// 群聊消息，扇出给在线成员，离线成员在登录后收到
+func handleGroupMessage(conn *protocol.WSConn, msg *pb.IMMessage) {
+	storageManager := storage.GetStorageManager()
+	if msg.GroupId == 0 {
+		replyError(conn, "缺少群ID")
+		return
+	}
+	if _, err := storageManager.GetGroupMember(msg.GroupId, msg.From); err != nil {
+		replyError(conn, "不是群成员")
+		return
+	}
+	members, err := storageManager.GetGroupMembers(msg.GroupId)
+	if err != nil {
+		replyError(conn, "获取群成员失败")
+		return
+	}
+	var recipients []string
+	for _, m := range members {
+		if m.UserID != msg.From {
+			recipients = append(recipients, m.UserID)
+		}
+	}
+	msg.To = ""
+	if !saveMessage(conn, msg, recipients) {
+		return
+	}
+	b, _ := proto.Marshal(msg)
+	for _, uid := range recipients {
+		// 不在线的成员保留离线消息
+		_ = protocol.SendWithAck(uid, msg.MsgId, b)
+	}
+}
+
+// 已读上报：将会话已读位置推进到 msg_id，单聊时向对方推送已读回执
+func handleReadMessage(conn *protocol.WSConn, msg *pb.IMMessage) {
+	storageManager := storage.GetStorageManager()
+	if msg.MsgId <= 0 {
+		replyError(conn, "缺少消息ID")
+		return
+	}
+	if msg.GroupId != 0 {
+		if _, err := storageManager.GetGroupMember(msg.GroupId, msg.From); err != nil {
+			replyError(conn, "不是群成员")
+			return
+		}
+		msg.To = ""
+	} else if msg.To == "" {
+		replyError(conn, "缺少会话对象")
+		return
+	} else if ok, err := storageManager.IsFriend(msg.From, msg.To); err != nil || !ok {
+		replyError(conn, "对方不是好友")
+		return
+	}
+	advanced, err := storageManager.UpdateReadCursor(msg.From, msg.To, msg.GroupId, msg.MsgId)
+	if err != nil {
+		log.Printf("更新已读位置失败: %v", err)
+		replyError(conn, "更新已读状态失败")
+		return
+	}
+	if !advanced {
+		return
+	}
+	receipt := &pb.IMMessage{Type: "read", From: msg.From, To: msg.To, GroupId: msg.GroupId, MsgId: msg.MsgId, Timestamp: time.Now().Unix()}
+	b, _ := proto.Marshal(receipt)
+	// 同步给自己的其他设备，刷新未读数
+	_ = protocol.SyncToOtherDevices(msg.From, conn, b)
+	if msg.GroupId == 0 {
+		_ = protocol.SendToUser(msg.To, b)
+	}
+}
+
+// 正在输入提示，只转发给在线的对方或群成员，不保存也不需要确认
+func handleTypingMessage(conn *protocol.WSConn, msg *pb.IMMessage) {
+	storageManager := storage.GetStorageManager()
+	typing := &pb.IMMessage{Type: "typing", From: msg.From, To: msg.To, GroupId: msg.GroupId, Timestamp: time.Now().Unix()}
+	if msg.GroupId != 0 {
+		if _, err := storageManager.GetGroupMember(msg.GroupId, msg.From); err != nil {
+			replyError(conn, "不是群成员")
+			return
+		}
+		typing.To = ""
+		b, _ := proto.Marshal(typing)
+		members, err := storageManager.GetGroupMembers(msg.GroupId)
+		if err != nil {
+			return
+		}
+		for _, m := range members {
+			if m.UserID != msg.From {
+				_ = protocol.SendToUser(m.UserID, b)
+			}
+		}
+		return
+	}
+	if msg.To == "" {
+		replyError(conn, "缺少会话对象")
+		return
+	}
+	if ok, err := storageManager.IsFriend(msg.From, msg.To); err != nil || !ok {
+		replyError(conn, "对方不是好友")
+		return
+	}
+	b, _ := proto.Marshal(typing)
+	_ = protocol.SendToUser(msg.To, b)
+}
+
+// 撤回或编辑消息，只有发送者可以在时间窗口内操作，结果推送给会话双方或全体群成员
+// 拥有 message.recall_any 权限的审核员可以随时撤回任何人的消息
+func handleModifyMessage(conn *protocol.WSConn, msg *pb.IMMessage) {
+	storageManager := storage.GetStorageManager()
+	if msg.MsgId <= 0 {
+		replyError(conn, "缺少消息ID")
+		return
+	}
+	target, err := storageManager.GetMessage(msg.MsgId)
+	if err != nil {
+		replyError(conn, "消息不存在")
+		return
+	}
+	if target.Recalled {
+		replyError(conn, "消息已撤回")
+		return
+	}
+	recall := msg.Type == "recall"
+	action, window, permission := "撤回", messageConfig.RecallWindow, auth.ActionMessageRecall
+	if !recall {
+		action, window, permission = "编辑", messageConfig.EditWindow, auth.ActionMessageEdit
+	}
+	moderated := false
+	if target.FromUserID != msg.From {
+		if !recall || !authorizer.Authorize(msg.From, auth.ActionMessageRecallAny) {
+			replyError(conn, "只能操作自己发送的消息")
+			return
+		}
+		moderated = true
+	} else if !authorizer.Authorize(msg.From, permission) {
+		replyError(conn, "没有权限"+action+"消息")
+		return
+	}
+	if !moderated && time.Since(target.CreatedAt) > window {
+		replyError(conn, "超过可"+action+"的时间")
+		return
+	}
+
+	now := time.Now().Unix()
+	event := &pb.IMMessage{Type: msg.Type, From: msg.From, To: target.ToUserID, GroupId: target.GroupID, MsgId: target.ID, Timestamp: now}
+	if recall {
+		err = storageManager.RecallMessage(target.ID)
+		event.Recalled = true
+	} else {
+		if target.Type != "chat" && target.Type != "group_chat" {
+			replyError(conn, "只能编辑文本消息")
+			return
+		}
+		if strings.TrimSpace(msg.Content) == "" {
+			replyError(conn, "消息内容不能为空")
+			return
+		}
+		err = storageManager.EditMessage(target.ID, msg.Content, now)
+		event.Content = msg.Content
+		event.EditedAt = now
+	}
+	if err != nil {
+		log.Printf("%s消息 %d 失败: %v", action, target.ID, err)
+		replyError(conn, action+"失败")
+		return
+	}
+
+	// 尚未确认的原消息重发时推送修改后的内容
+	if updated, err := storageManager.GetMessage(target.ID); err == nil {
+		updatedPB := protocol.MessageToPB(updated)
+		protocol.AttachReferences([]*pb.IMMessage{updatedPB})
+		b, _ := proto.Marshal(updatedPB)
+		protocol.UpdatePendingMessage(target.ID, b)
+	}
+
+	b, _ := proto.Marshal(event)
+	for _, uid := range conversationMembers(target) {
+		_ = protocol.SendToUser(uid, b)
+	}
+}
+
+// 表情回应的最大长度（字符数）
+const maxReactionLen = 8
+
+// 添加或取消表情回应，变化后的回应汇总推送给会话双方或全体群成员
+func handleReactionMessage(conn *protocol.WSConn, msg *pb.IMMessage) {
+	storageManager := storage.GetStorageManager()
+	emoji := strings.TrimSpace(msg.Content)
+	if msg.MsgId <= 0 || emoji == "" {
+		replyError(conn, "缺少消息ID或表情")
+		return
+	}
+	if utf8.RuneCountInString(emoji) > maxReactionLen {
+		replyError(conn, "表情过长")
+		return
+	}
+	if msg.Extra != "add" && msg.Extra != "remove" {
+		replyError(conn, "无效的回应操作")
+		return
+	}
+	target, err := storageManager.GetMessage(msg.MsgId)
+	if err != nil || target.Recalled || !protocol.CanSeeMessage(msg.From, target) {
+		replyError(conn, "消息不存在")
+		return
+	}
+
+	var changed bool
+	if msg.Extra == "add" {
+		changed, err = storageManager.AddReaction(target.ID, msg.From, emoji)
+	} else {
+		changed, err = storageManager.RemoveReaction(target.ID, msg.From, emoji)
+	}
+	if err != nil {
+		log.Printf("更新消息 %d 的回应失败: %v", target.ID, err)
+		replyError(conn, "回应失败")
+		return
+	}
+	if !changed {
+		return
+	}
+
+	event := &pb.IMMessage{Type: "reaction", From: msg.From, To: target.ToUserID, GroupId: target.GroupID, MsgId: target.ID,
+		Content: emoji, Extra: msg.Extra, Timestamp: time.Now().Unix()}
+	if reactions, err := storageManager.GetReactions([]int64{target.ID}); err == nil {
+		event.Reactions = protocol.ReactionsToPB(reactions[target.ID])
+	}
+	b, _ := proto.Marshal(event)
+	for _, uid := range conversationMembers(target) {
+		_ = protocol.SendToUser(uid, b)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"im/config"
+	"im/core/storage"
+)
+
+const usage = `用法: migrate <命令>
+
+命令:
+  up          执行全部未执行的迁移
+  down [n] [--force]
+              回滚最近执行的 n 个迁移，默认为1
+              回滚初始迁移会删除全部表和数据，需要加 --force
+  status      查看迁移执行状态
+
+数据库连接使用与服务端相同的配置文件和环境变量`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("配置校验失败:\n%v", err)
+	}
+	migrator, err := storage.OpenMigrator(&cfg.Database)
+	if err != nil {
+		log.Fatal("打开数据库失败: ", err)
+	}
+	defer migrator.Close()
+
+	switch os.Args[1] {
+	case "up":
+		done, err := migrator.Up()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(done) == 0 {
+			fmt.Println("数据库已是最新版本")
+		}
+	case "down":
+		steps, force := 1, false
+		for _, arg := range os.Args[2:] {
+			if arg == "--force" {
+				force = true
+				continue
+			}
+			if steps, err = strconv.Atoi(arg); err != nil || steps <= 0 {
+				log.Fatalf("回滚数量不合法: %s", arg)
+			}
+		}
+		done, err := migrator.Down(steps, force)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(done) == 0 {
+			fmt.Println("没有可回滚的迁移")
+		}
+	case "status":
+		list, err := migrator.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range list {
+			state := "未执行"
+			if s.AppliedAt > 0 {
+				state = "已执行于 " + time.Unix(s.AppliedAt, 0).Format("2006-01-02 15:04:05")
+			}
+			if s.Unknown {
+				state += "（当前程序中没有该迁移）"
+			}
+			fmt.Printf("%d\t%s\t%s\n", s.Version, s.Description, state)
+		}
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
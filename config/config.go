@@ -0,0 +1,371 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// 完整配置，加载顺序: 默认值 -> YAML配置文件 -> 环境变量（含.env/config.env），后者覆盖前者
+// YAML中的时间使用 "30s"、"15m" 这样的格式，环境变量中的时间单位为秒
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Database   DatabaseConfig   `yaml:"database"`
+	WebSocket  WSConfig         `yaml:"websocket"`
+	Message    MessageConfig    `yaml:"message"`
+	Token      TokenConfig      `yaml:"token"`
+	JWT        JWTConfig        `yaml:"jwt"`
+	Upload     UploadConfig     `yaml:"upload"`
+	Mail       MailConfig       `yaml:"mail"`
+	Verify     VerifyConfig     `yaml:"verify"`
+	LoginGuard LoginGuardConfig `yaml:"login_guard"`
+	UID        UIDConfig        `yaml:"uid"`
+	Client     ClientConfig     `yaml:"client"`
+}
+
+// 服务监听配置
+type ServerConfig struct {
+	HTTPAddr    string   `yaml:"http_addr"`    // HTTP接口监听地址
+	WSAddr      string   `yaml:"ws_addr"`      // WebSocket监听地址
+	CORSOrigins []string `yaml:"cors_origins"` // 允许跨域访问的来源，"*" 表示全部
+	Admins      []string `yaml:"admins"`       // 启动时授予系统管理员角色的UID
+}
+
+// 文件上传配置
+type UploadConfig struct {
+	Dir     string `yaml:"dir"`      // 上传文件保存目录
+	MaxSize int64  `yaml:"max_size"` // 单个文件最大字节数
+}
+
+// 客户端连接的服务器地址
+type ClientConfig struct {
+	HTTPURL string `yaml:"http_url"`
+	WSURL   string `yaml:"ws_url"`
+}
+
+// 初始化配置
+func init() {
+	// 尝试加载.env文件
+	if err := godotenv.Load(); err != nil {
+		// 如果.env文件不存在，尝试加载config.env
+		if err := godotenv.Load("config.env"); err != nil {
+			log.Println("未找到.env或config.env文件，将使用环境变量或默认值")
+		} else {
+			log.Println("已加载config.env文件")
+		}
+	} else {
+		log.Println("已加载.env文件")
+	}
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			HTTPAddr: ":8081",
+			WSAddr:   ":8090",
+		},
+		Database: DatabaseConfig{
+			Driver:   DriverMySQL,
+			Path:     "data/im.db",
+			Host:     "localhost",
+			Port:     3306,
+			Username: "root",
+			Database: "im_system",
+			Charset:  "utf8mb4",
+		},
+		WebSocket: WSConfig{
+			PingInterval:  30 * time.Second,
+			PongTimeout:   60 * time.Second,
+			WriteTimeout:  10 * time.Second,
+			SendQueueSize: 256,
+		},
+		Message: MessageConfig{
+			RecallWindow: 120 * time.Second,
+			EditWindow:   900 * time.Second,
+		},
+		Token: TokenConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Upload: UploadConfig{
+			Dir:     "./uploads",
+			MaxSize: 50 * 1024 * 1024, // 50MB
+		},
+		Mail: MailConfig{
+			Driver: "log",
+			Port:   587,
+		},
+		Verify: VerifyConfig{
+			CodeLength:      6,
+			CodeTTL:         10 * time.Minute,
+			MaxAttempts:     5,
+			SendInterval:    time.Minute,
+			MaxPerEmailHour: 5,
+			MaxPerIPHour:    20,
+		},
+		LoginGuard: LoginGuardConfig{
+			FailureWindow:      15 * time.Minute,
+			MaxAccountFailures: 5,
+			MaxIPFailures:      20,
+			LockoutDuration:    15 * time.Minute,
+			BaseDelay:          time.Second,
+			MaxDelay:           30 * time.Second,
+		},
+		UID: UIDConfig{
+			Strategy: UIDSequence,
+		},
+		Client: ClientConfig{
+			HTTPURL: "http://localhost:8081",
+			WSURL:   "ws://127.0.0.1:8090/ws",
+		},
+	}
+}
+
+// 使用环境变量覆盖配置
+func (c *Config) applyEnv() {
+	c.Server.HTTPAddr = getEnv("HTTP_ADDR", c.Server.HTTPAddr)
+	c.Server.WSAddr = getEnv("WS_ADDR", c.Server.WSAddr)
+	c.Server.CORSOrigins = getEnvAsList("CORS_ORIGINS", c.Server.CORSOrigins)
+	c.Server.Admins = getEnvAsList("IM_ADMINS", c.Server.Admins)
+
+	c.Database.Driver = getEnv("DB_DRIVER", c.Database.Driver)
+	c.Database.Path = getEnv("DB_PATH", c.Database.Path)
+	c.Database.Host = getEnv("DB_HOST", c.Database.Host)
+	c.Database.Port = getEnvAsInt("DB_PORT", c.Database.Port)
+	c.Database.Username = getEnv("DB_USERNAME", c.Database.Username)
+	c.Database.Password = getEnv("DB_PASSWORD", c.Database.Password)
+	c.Database.Database = getEnv("DB_DATABASE", c.Database.Database)
+	c.Database.Charset = getEnv("DB_CHARSET", c.Database.Charset)
+
+	c.WebSocket.PingInterval = getEnvAsSeconds("WS_PING_INTERVAL", c.WebSocket.PingInterval)
+	c.WebSocket.PongTimeout = getEnvAsSeconds("WS_PONG_TIMEOUT", c.WebSocket.PongTimeout)
+	c.WebSocket.WriteTimeout = getEnvAsSeconds("WS_WRITE_TIMEOUT", c.WebSocket.WriteTimeout)
+	c.WebSocket.SendQueueSize = getEnvAsInt("WS_SEND_QUEUE_SIZE", c.WebSocket.SendQueueSize)
+
+	c.Message.RecallWindow = getEnvAsSeconds("MSG_RECALL_WINDOW", c.Message.RecallWindow)
+	c.Message.EditWindow = getEnvAsSeconds("MSG_EDIT_WINDOW", c.Message.EditWindow)
+
+	c.Token.AccessTTL = getEnvAsSeconds("ACCESS_TOKEN_TTL", c.Token.AccessTTL)
+	c.Token.RefreshTTL = getEnvAsSeconds("REFRESH_TOKEN_TTL", c.Token.RefreshTTL)
+
+	c.JWT.Secret = getEnv("JWT_SECRET", c.JWT.Secret)
+	c.JWT.ActiveKeyID = getEnv("JWT_ACTIVE_KEY_ID", c.JWT.ActiveKeyID)
+	c.JWT.AllowDev = getEnvAsBool("JWT_ALLOW_DEV_SECRET", c.JWT.AllowDev)
+	if files := getEnvAsList("JWT_KEY_FILES", nil); files != nil {
+		// 格式: kid1:/path/key1,kid2:/path/key2
+		c.JWT.Keys = nil
+		for _, f := range files {
+			id, file, _ := strings.Cut(f, ":")
+			c.JWT.Keys = append(c.JWT.Keys, JWTKey{ID: id, File: file})
+		}
+	}
+
+	c.Upload.Dir = getEnv("UPLOAD_DIR", c.Upload.Dir)
+	c.Upload.MaxSize = int64(getEnvAsInt("UPLOAD_MAX_SIZE", int(c.Upload.MaxSize)))
+
+	c.Mail.Driver = getEnv("MAIL_DRIVER", c.Mail.Driver)
+	c.Mail.Host = getEnv("SMTP_HOST", c.Mail.Host)
+	c.Mail.Port = getEnvAsInt("SMTP_PORT", c.Mail.Port)
+	c.Mail.Username = getEnv("SMTP_USERNAME", c.Mail.Username)
+	c.Mail.Password = getEnv("SMTP_PASSWORD", c.Mail.Password)
+	c.Mail.From = getEnv("MAIL_FROM", c.Mail.From)
+	c.Mail.FilePath = getEnv("MAIL_FILE_PATH", c.Mail.FilePath)
+
+	c.Verify.CodeLength = getEnvAsInt("VERIFY_CODE_LENGTH", c.Verify.CodeLength)
+	c.Verify.CodeTTL = getEnvAsSeconds("VERIFY_CODE_TTL", c.Verify.CodeTTL)
+	c.Verify.MaxAttempts = getEnvAsInt("VERIFY_MAX_ATTEMPTS", c.Verify.MaxAttempts)
+	c.Verify.SendInterval = getEnvAsSeconds("VERIFY_SEND_INTERVAL", c.Verify.SendInterval)
+	c.Verify.MaxPerEmailHour = getEnvAsInt("VERIFY_MAX_PER_EMAIL_HOUR", c.Verify.MaxPerEmailHour)
+	c.Verify.MaxPerIPHour = getEnvAsInt("VERIFY_MAX_PER_IP_HOUR", c.Verify.MaxPerIPHour)
+
+	c.LoginGuard.FailureWindow = getEnvAsSeconds("LOGIN_FAILURE_WINDOW", c.LoginGuard.FailureWindow)
+	c.LoginGuard.MaxAccountFailures = getEnvAsInt("LOGIN_MAX_ACCOUNT_FAILURES", c.LoginGuard.MaxAccountFailures)
+	c.LoginGuard.MaxIPFailures = getEnvAsInt("LOGIN_MAX_IP_FAILURES", c.LoginGuard.MaxIPFailures)
+	c.LoginGuard.LockoutDuration = getEnvAsSeconds("LOGIN_LOCKOUT_DURATION", c.LoginGuard.LockoutDuration)
+	c.LoginGuard.BaseDelay = getEnvAsSeconds("LOGIN_BASE_DELAY", c.LoginGuard.BaseDelay)
+	c.LoginGuard.MaxDelay = getEnvAsSeconds("LOGIN_MAX_DELAY", c.LoginGuard.MaxDelay)
+
+	c.UID.Strategy = getEnv("UID_STRATEGY", c.UID.Strategy)
+	c.UID.NodeID = int64(getEnvAsInt("UID_NODE_ID", int(c.UID.NodeID)))
+	c.UID.AllowVanity = getEnvAsBool("UID_ALLOW_VANITY", c.UID.AllowVanity)
+
+	c.Client.HTTPURL = getEnv("IM_HTTP_URL", c.Client.HTTPURL)
+	c.Client.WSURL = getEnv("IM_WS_URL", c.Client.WSURL)
+}
+
+// 校验配置，返回全部错误
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Server.HTTPAddr != "", "server.http_addr 不能为空")
+	check(c.Server.WSAddr != "", "server.ws_addr 不能为空")
+	for _, origin := range c.Server.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		check(err == nil && u.Scheme != "" && u.Host != "" && u.Path == "", "server.cors_origins 格式错误: %q", origin)
+	}
+
+	check(c.WebSocket.PingInterval >= 0 && c.WebSocket.PongTimeout >= 0, "websocket 心跳时间不能为负数")
+	check(c.WebSocket.PingInterval == 0 || c.WebSocket.PongTimeout == 0 || c.WebSocket.PingInterval < c.WebSocket.PongTimeout,
+		"websocket.ping_interval 必须小于 websocket.pong_timeout")
+	check(c.WebSocket.WriteTimeout > 0, "websocket.write_timeout 必须大于0")
+	check(c.WebSocket.SendQueueSize > 0, "websocket.send_queue_size 必须大于0")
+
+	check(c.Message.RecallWindow >= 0 && c.Message.EditWindow >= 0, "message 撤回/编辑时间窗口不能为负数")
+
+	check(c.Token.AccessTTL > 0, "token.access_ttl 必须大于0")
+	check(c.Token.RefreshTTL > c.Token.AccessTTL, "token.refresh_ttl 必须大于 token.access_ttl")
+
+	if _, _, err := c.JWT.LoadKeys(); err != nil {
+		errs = append(errs, err)
+	}
+
+	check(c.Upload.Dir != "", "upload.dir 不能为空")
+	check(c.Upload.MaxSize > 0, "upload.max_size 必须大于0")
+
+	for _, err := range []error{c.Database.validate(), c.Mail.validate(), c.Verify.validate(), c.LoginGuard.validate(),
+		c.UID.validate()} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, u := range []string{c.Client.HTTPURL, c.Client.WSURL} {
+		parsed, err := url.Parse(u)
+		check(err == nil && parsed.Host != "", "client 服务器地址格式错误: %q", u)
+	}
+	return errors.Join(errs...)
+}
+
+// 是否允许该来源跨域访问，未携带Origin的请求（非浏览器客户端）总是允许
+func (c *ServerConfig) AllowOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	for _, o := range c.CORSOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+var loaded struct {
+	once sync.Once
+	cfg  *Config
+	err  error
+}
+
+// 读取配置文件，CONFIG_FILE 未设置时尝试当前目录下的 config.yaml
+// 配置文件出错时仍应用环境变量，错误与校验结果一并返回
+func load() (*Config, error) {
+	cfg := defaultConfig()
+	var fileErr error
+	path := os.Getenv("CONFIG_FILE")
+	data, err := os.ReadFile(getEnv("CONFIG_FILE", "config.yaml"))
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			fileErr = fmt.Errorf("解析配置文件失败: %v", err)
+		}
+	case path != "" || !os.IsNotExist(err):
+		fileErr = fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	cfg.applyEnv()
+	return cfg, errors.Join(fileErr, cfg.Validate())
+}
+
+// Load 加载并校验配置，只加载一次，服务启动时应检查返回的错误
+func Load() (*Config, error) {
+	loaded.once.Do(func() {
+		loaded.cfg, loaded.err = load()
+	})
+	return loaded.cfg, loaded.err
+}
+
+// Get 返回已加载的配置，校验错误由启动时的 Load 处理
+func Get() *Config {
+	cfg, _ := Load()
+	return cfg
+}
+
+// 获取服务监听配置
+func GetServerConfig() *ServerConfig {
+	return &Get().Server
+}
+
+// 获取文件上传配置
+func GetUploadConfig() *UploadConfig {
+	return &Get().Upload
+}
+
+// 获取客户端连接配置
+func GetClientConfig() *ClientConfig {
+	return &Get().Client
+}
+
+// 从环境变量获取字符串值
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// 从环境变量获取整数值
+func getEnvAsInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := fmt.Sscanf(value, "%d", &defaultValue); err == nil && intValue == 1 {
+			return defaultValue
+		}
+	}
+	return defaultValue
+}
+
+// 从环境变量获取布尔值，支持 true/false、1/0
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// 从环境变量获取以秒为单位的时间
+func getEnvAsSeconds(key string, defaultValue time.Duration) time.Duration {
+	if os.Getenv(key) == "" {
+		return defaultValue
+	}
+	return time.Duration(getEnvAsInt(key, int(defaultValue/time.Second))) * time.Second
+}
+
+// 从环境变量获取逗号分隔的列表
+func getEnvAsList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
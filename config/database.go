@@ -1,48 +1,29 @@
 package config
 
-import (
-	"fmt"
-	"log"
-	"os"
+import "fmt"
 
-	"github.com/joho/godotenv"
+// 存储驱动
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite" // 嵌入式数据库，数据保存在 Path 指定的文件中
+	DriverMemory = "memory" // 数据只保存在内存中，用于开发和测试
 )
 
 // 数据库配置
 type DatabaseConfig struct {
-	Host     string
-	Port     int
-	Username string
-	Password string
-	Database string
-	Charset  string
-}
-
-// 初始化配置
-func init() {
-	// 尝试加载.env文件
-	if err := godotenv.Load(); err != nil {
-		// 如果.env文件不存在，尝试加载config.env
-		if err := godotenv.Load("config.env"); err != nil {
-			log.Println("未找到.env或config.env文件，将使用环境变量或默认值")
-		} else {
-			log.Println("已加载config.env文件")
-		}
-	} else {
-		log.Println("已加载.env文件")
-	}
+	Driver   string `yaml:"driver"`
+	Path     string `yaml:"path"` // SQLite 数据库文件路径
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+	Charset  string `yaml:"charset"`
 }
 
 // 获取数据库配置
 func GetDatabaseConfig() *DatabaseConfig {
-	return &DatabaseConfig{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnvAsInt("DB_PORT", 3306),
-		Username: getEnv("DB_USERNAME", "root"),
-		Password: getEnv("DB_PASSWORD", ""),
-		Database: getEnv("DB_DATABASE", "im_system"),
-		Charset:  getEnv("DB_CHARSET", "utf8mb4"),
-	}
+	return &Get().Database
 }
 
 // 获取DSN连接字符串
@@ -51,20 +32,22 @@ func (c *DatabaseConfig) GetDSN() string {
 		c.Username, c.Password, c.Host, c.Port, c.Database, c.Charset)
 }
 
-// 从环境变量获取字符串值
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-// 从环境变量获取整数值
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := fmt.Sscanf(value, "%d", &defaultValue); err == nil && intValue == 1 {
-			return defaultValue
+func (c *DatabaseConfig) validate() error {
+	switch c.Driver {
+	case DriverMySQL:
+		if c.Host == "" || c.Database == "" {
+			return fmt.Errorf("database.host 和 database.database 不能为空")
+		}
+		if c.Port <= 0 || c.Port >= 65536 {
+			return fmt.Errorf("database.port 不合法: %d", c.Port)
+		}
+	case DriverSQLite:
+		if c.Path == "" {
+			return fmt.Errorf("使用 sqlite 时 database.path 不能为空")
 		}
+	case DriverMemory:
+	default:
+		return fmt.Errorf("database.driver 不合法: %q，可选 mysql、sqlite、memory", c.Driver)
 	}
-	return defaultValue
+	return nil
 }
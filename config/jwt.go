@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// JWT签名密钥最短长度（字节）
+const minJWTKeyLen = 32
+
+// 仅供本地开发使用的密钥，需同时设置 JWT_ALLOW_DEV_SECRET=true
+const DevJWTSecret = "dev_only_insecure_jwt_secret_do_not_deploy"
+
+// 公开在仓库中的密钥，任何人都能用它伪造令牌，只允许在开发模式下使用
+var publicJWTSecrets = map[string]bool{
+	DevJWTSecret: true,
+	"change_me_to_a_random_string_of_32_bytes_or_more": true,
+}
+
+// JWT签名密钥配置，可以直接配置密钥，也可以从文件读取多个带ID的密钥
+// 轮换密钥时添加新密钥并设为当前密钥，旧密钥保留到其签发的令牌全部过期
+type JWTConfig struct {
+	Secret      string   `yaml:"secret"`        // 直接配置的密钥，ID为 default
+	Keys        []JWTKey `yaml:"keys"`          // 密钥文件列表
+	ActiveKeyID string   `yaml:"active_key_id"` // 签发新令牌使用的密钥ID，为空时使用第一个密钥
+	AllowDev    bool     `yaml:"allow_dev"`     // 允许使用仓库中公开的开发密钥，仅限本地开发
+}
+
+type JWTKey struct {
+	ID   string `yaml:"id"`
+	File string `yaml:"file"`
+}
+
+// 直接配置的密钥使用的ID
+const DefaultJWTKeyID = "default"
+
+// LoadKeys 读取全部签名密钥，返回 kid -> 密钥 以及当前签名使用的kid
+func (c *JWTConfig) LoadKeys() (map[string][]byte, string, error) {
+	keys := make(map[string][]byte)
+	var order []string
+	if c.Secret != "" {
+		keys[DefaultJWTKeyID] = []byte(c.Secret)
+		order = append(order, DefaultJWTKeyID)
+	}
+	for _, k := range c.Keys {
+		if k.ID == "" || k.File == "" {
+			return nil, "", fmt.Errorf("jwt.keys 需要同时配置 id 和 file")
+		}
+		if _, ok := keys[k.ID]; ok {
+			return nil, "", fmt.Errorf("jwt.keys 密钥ID重复: %s", k.ID)
+		}
+		data, err := os.ReadFile(k.File)
+		if err != nil {
+			return nil, "", fmt.Errorf("读取JWT密钥 %s 失败: %v", k.ID, err)
+		}
+		keys[k.ID] = []byte(strings.TrimSpace(string(data)))
+		order = append(order, k.ID)
+	}
+	if len(keys) == 0 {
+		return nil, "", fmt.Errorf("未配置JWT签名密钥，请设置 JWT_SECRET 或 JWT_KEY_FILES")
+	}
+	for id, key := range keys {
+		if len(key) < minJWTKeyLen {
+			return nil, "", fmt.Errorf("JWT密钥 %s 长度不能少于%d字节", id, minJWTKeyLen)
+		}
+		if publicJWTSecrets[string(key)] && !c.AllowDev {
+			return nil, "", fmt.Errorf("JWT密钥 %s 是公开的示例或开发密钥，请替换为随机生成的密钥（本地开发可设置 JWT_ALLOW_DEV_SECRET=true）", id)
+		}
+	}
+	active := c.ActiveKeyID
+	if active == "" {
+		active = order[0]
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, "", fmt.Errorf("jwt.active_key_id 对应的密钥不存在: %s", active)
+	}
+	return keys, active, nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 登录防暴力破解配置
+type LoginGuardConfig struct {
+	FailureWindow      time.Duration `yaml:"failure_window"`       // 统计失败次数的时间窗口
+	MaxAccountFailures int           `yaml:"max_account_failures"` // 窗口内同一账号失败达到该次数后锁定
+	MaxIPFailures      int           `yaml:"max_ip_failures"`      // 窗口内同一IP失败达到该次数后拒绝该IP登录
+	LockoutDuration    time.Duration `yaml:"lockout_duration"`     // 锁定时长，从最后一次失败开始计算
+	BaseDelay          time.Duration `yaml:"base_delay"`           // 首次失败后需要等待的时间，之后每次失败翻倍
+	MaxDelay           time.Duration `yaml:"max_delay"`            // 两次尝试之间最长等待时间
+}
+
+// 获取登录防暴力破解配置
+func GetLoginGuardConfig() *LoginGuardConfig {
+	return &Get().LoginGuard
+}
+
+func (c *LoginGuardConfig) validate() error {
+	if c.FailureWindow <= 0 || c.LockoutDuration <= 0 {
+		return fmt.Errorf("login_guard.failure_window 和 login_guard.lockout_duration 必须大于0")
+	}
+	if c.MaxAccountFailures <= 0 || c.MaxIPFailures <= 0 {
+		return fmt.Errorf("login_guard 失败次数阈值必须大于0")
+	}
+	if c.BaseDelay < 0 || c.MaxDelay < c.BaseDelay {
+		return fmt.Errorf("login_guard.max_delay 不能小于 login_guard.base_delay")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 邮件发送配置
+type MailConfig struct {
+	Driver   string `yaml:"driver"`    // smtp、file 或 log
+	Host     string `yaml:"host"`      // SMTP服务器地址
+	Port     int    `yaml:"port"`      // SMTP端口，465 使用TLS直连，其他端口在服务器支持时使用STARTTLS
+	Username string `yaml:"username"`  // SMTP用户名，为空时不认证
+	Password string `yaml:"password"`  // SMTP密码
+	From     string `yaml:"from"`      // 发件人地址
+	FilePath string `yaml:"file_path"` // file 驱动写入的文件，用于本地测试
+}
+
+// 验证码配置
+type VerifyConfig struct {
+	CodeLength      int           `yaml:"code_length"`        // 验证码位数
+	CodeTTL         time.Duration `yaml:"code_ttl"`           // 验证码有效期
+	MaxAttempts     int           `yaml:"max_attempts"`       // 单个验证码允许的错误次数
+	SendInterval    time.Duration `yaml:"send_interval"`      // 同一邮箱两次发送的最小间隔
+	MaxPerEmailHour int           `yaml:"max_per_email_hour"` // 每个邮箱每小时最多发送次数
+	MaxPerIPHour    int           `yaml:"max_per_ip_hour"`    // 每个IP每小时最多请求次数
+}
+
+// 获取邮件发送配置
+func GetMailConfig() *MailConfig {
+	return &Get().Mail
+}
+
+// 获取验证码配置
+func GetVerifyConfig() *VerifyConfig {
+	return &Get().Verify
+}
+
+func (c *MailConfig) validate() error {
+	switch c.Driver {
+	case "smtp":
+		if c.Host == "" || c.From == "" {
+			return fmt.Errorf("mail.driver 为 smtp 时必须配置 mail.host 和 mail.from")
+		}
+		if c.Port <= 0 || c.Port >= 65536 {
+			return fmt.Errorf("mail.port 不合法: %d", c.Port)
+		}
+	case "file":
+		if c.FilePath == "" {
+			return fmt.Errorf("mail.driver 为 file 时必须配置 mail.file_path")
+		}
+	case "log":
+	default:
+		return fmt.Errorf("mail.driver 只能是 smtp、file 或 log: %q", c.Driver)
+	}
+	return nil
+}
+
+func (c *VerifyConfig) validate() error {
+	if c.CodeLength < 4 || c.CodeLength > 10 {
+		return fmt.Errorf("verify.code_length 必须在4到10之间")
+	}
+	if c.CodeTTL <= 0 || c.MaxAttempts <= 0 || c.SendInterval < 0 || c.MaxPerEmailHour <= 0 || c.MaxPerIPHour <= 0 {
+		return fmt.Errorf("verify 配置的有效期、次数限制必须大于0")
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// 消息配置
+type MessageConfig struct {
+	RecallWindow time.Duration `yaml:"recall_window"` // 发送后允许撤回的时间
+	EditWindow   time.Duration `yaml:"edit_window"`   // 发送后允许编辑的时间
+}
+
+// 获取消息配置
+func GetMessageConfig() *MessageConfig {
+	return &Get().Message
+}
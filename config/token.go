@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// 令牌配置
+type TokenConfig struct {
+	AccessTTL  time.Duration `yaml:"access_ttl"`  // 访问令牌有效期
+	RefreshTTL time.Duration `yaml:"refresh_ttl"` // 刷新令牌有效期，每次刷新后重新计算
+}
+
+// 获取令牌配置
+func GetTokenConfig() *TokenConfig {
+	return &Get().Token
+}
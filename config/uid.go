@@ -0,0 +1,37 @@
+package config
+
+import "fmt"
+
+// ID分配策略
+const (
+	UIDSequence  = "sequence"  // 数据库序列，ID从1开始连续递增，多实例共享同一个数据库即可
+	UIDSnowflake = "snowflake" // 雪花算法，ID按时间递增，不访问数据库，多实例需配置不同的 node_id
+)
+
+// 雪花算法节点ID的最大值，节点ID占10位
+const MaxUIDNodeID = 1023
+
+// 用户UID、消息ID和群ID的分配配置
+// 已有数据后从 snowflake 切换回 sequence 会使新ID小于旧ID，导致消息排序错乱
+type UIDConfig struct {
+	Strategy    string `yaml:"strategy"`     // 分配策略: sequence、snowflake
+	NodeID      int64  `yaml:"node_id"`      // 雪花算法的节点ID，0-1023，多实例部署时每个实例必须不同
+	AllowVanity bool   `yaml:"allow_vanity"` // 是否允许注册时自选UID（以字母开头，不会与分配的数字UID冲突）
+}
+
+// 获取ID分配配置
+func GetUIDConfig() *UIDConfig {
+	return &Get().UID
+}
+
+func (c *UIDConfig) validate() error {
+	switch c.Strategy {
+	case UIDSequence, UIDSnowflake:
+	default:
+		return fmt.Errorf("uid.strategy 不合法: %q，可选 sequence、snowflake", c.Strategy)
+	}
+	if c.NodeID < 0 || c.NodeID > MaxUIDNodeID {
+		return fmt.Errorf("uid.node_id 必须在 0-%d 之间: %d", MaxUIDNodeID, c.NodeID)
+	}
+	return nil
+}
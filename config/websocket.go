@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// WebSocket连接配置
+type WSConfig struct {
+	PingInterval  time.Duration `yaml:"ping_interval"`   // 服务器发送 ping 的间隔
+	PongTimeout   time.Duration `yaml:"pong_timeout"`    // 超过该时间未收到任何数据（包括 pong）则断开连接
+	WriteTimeout  time.Duration `yaml:"write_timeout"`   // 单次写超时
+	SendQueueSize int           `yaml:"send_queue_size"` // 每个连接的发送队列长度
+}
+
+// 获取WebSocket配置
+func GetWSConfig() *WSConfig {
+	return &Get().WebSocket
+}
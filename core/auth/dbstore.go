@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"im/core/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 数据库用户存储，密码使用bcrypt哈希保存
+// 历史遗留的明文密码在下次登录成功后自动重新哈希
+type DBUserStore struct {
+	sm *storage.StorageManager
+}
+
+func NewDBUserStore(sm *storage.StorageManager) *DBUserStore {
+	return &DBUserStore{sm: sm}
+}
+
+// 判断存储的密码是否为bcrypt哈希
+func isBcryptHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// 校验密码，needRehash 表示需要以当前参数重新哈希（明文或cost过低）
+func checkPassword(stored, password string) (ok, needRehash bool) {
+	if !isBcryptHash(stored) {
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || cost < bcrypt.DefaultCost
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func toAuthUser(u *storage.User) *User {
+	return &User{UID: u.UID, Username: u.Username, Password: u.Password, Email: u.Email}
+}
+
+func (d *DBUserStore) Register(uid, username, password, email string) (*User, error) {
+	if _, err := d.sm.GetUserByUID(uid); err == nil {
+		return nil, errors.New("账号已存在")
+	}
+	if _, err := d.sm.GetUserByEmail(email); err == nil {
+		return nil, errors.New("邮箱已被注册")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, errors.New("密码加密失败")
+	}
+	if err := d.sm.CreateUser(uid, username, hash, email); err != nil {
+		return nil, err
+	}
+	return &User{UID: uid, Username: username, Password: hash, Email: email}, nil
+}
+
+func (d *DBUserStore) Login(uid, password string) (*User, error) {
+	user, err := d.sm.GetUserByUID(uid)
+	if err != nil {
+		return nil, errors.New("账号或密码错误")
+	}
+	ok, needRehash := checkPassword(user.Password, password)
+	if !ok {
+		return nil, errors.New("账号或密码错误")
+	}
+	if needRehash {
+		// 重新哈希失败不影响本次登录，下次登录时再尝试
+		if hash, err := hashPassword(password); err == nil && d.sm.UpdatePassword(uid, hash) == nil {
+			user.Password = hash
+		}
+	}
+	return toAuthUser(user), nil
+}
+
+func (d *DBUserStore) GetByUID(uid string) (*User, error) {
+	user, err := d.sm.GetUserByUID(uid)
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	return toAuthUser(user), nil
+}
+
+func (d *DBUserStore) GetByEmail(email string) (*User, error) {
+	user, err := d.sm.GetUserByEmail(email)
+	if err != nil {
+		return nil, errors.New("邮箱未注册")
+	}
+	return toAuthUser(user), nil
+}
+
+func (d *DBUserStore) UpdateUsername(uid, newUsername string) error {
+	return d.sm.UpdateUsername(uid, newUsername)
+}
+
+func (d *DBUserStore) UpdatePassword(uid, oldPwd, newPwd string) error {
+	user, err := d.sm.GetUserByUID(uid)
+	if err != nil {
+		return errors.New("用户不存在")
+	}
+	if ok, _ := checkPassword(user.Password, oldPwd); !ok {
+		return errors.New("原密码错误")
+	}
+	hash, err := hashPassword(newPwd)
+	if err != nil {
+		return errors.New("新密码加密失败")
+	}
+	return d.sm.UpdatePassword(uid, hash)
+}
+
+func (d *DBUserStore) UpdateEmail(uid, newEmail string) error {
+	if user, err := d.sm.GetUserByEmail(newEmail); err == nil && user.UID != uid {
+		return errors.New("邮箱已被注册")
+	}
+	return d.sm.UpdateEmail(uid, newEmail)
+}
+
+func (d *DBUserStore) ResetPasswordByEmail(email, newPwd string) error {
+	user, err := d.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(newPwd)
+	if err != nil {
+		return errors.New("新密码加密失败")
+	}
+	return d.sm.UpdatePassword(user.UID, hash)
+}
+
+func (d *DBUserStore) DeleteAccount(uid string) error {
+	return d.sm.DeleteUser(uid)
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"testing"
+
+	"im/core/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 测试期间让全局存储管理器使用内存存储，结束后恢复
+func useMemoryStore(t *testing.T) *storage.MemoryStorage {
+	t.Helper()
+	sm := storage.GetStorageManager()
+	prev := sm.Store
+	mem := storage.NewMemoryStorage()
+	sm.Store = mem
+	t.Cleanup(func() { sm.Store = prev })
+	return mem
+}
+
+func mustBcrypt(t *testing.T, password string, cost int) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     string
+		password   string
+		wantLogin  bool
+		wantRehash bool
+	}{
+		{"明文密码登录后重新哈希", "secret", "secret", true, true},
+		{"低cost哈希登录后重新哈希", mustBcrypt(t, "secret", bcrypt.MinCost), "secret", true, true},
+		{"当前cost哈希保持不变", mustBcrypt(t, "secret", bcrypt.DefaultCost), "secret", true, false},
+		{"明文密码错误不修改", "secret", "wrong", false, false},
+		{"哈希密码错误不修改", mustBcrypt(t, "secret", bcrypt.MinCost), "wrong", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := useMemoryStore(t)
+			if err := mem.CreateUser("1", "alice", tt.stored, "a@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			_, err := NewDBUserStore(storage.GetStorageManager()).Login("1", tt.password)
+			if (err == nil) != tt.wantLogin {
+				t.Fatalf("Login 返回 %v，期望登录成功为 %v", err, tt.wantLogin)
+			}
+
+			u, err := mem.GetUserByUID("1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rehashed := u.Password != tt.stored; rehashed != tt.wantRehash {
+				t.Fatalf("密码是否重新哈希为 %v，期望 %v", rehashed, tt.wantRehash)
+			}
+			if tt.wantRehash {
+				if cost, err := bcrypt.Cost([]byte(u.Password)); err != nil || cost != bcrypt.DefaultCost {
+					t.Errorf("重新哈希后 cost = %d, %v，期望 %d", cost, err, bcrypt.DefaultCost)
+				}
+				if ok, needRehash := checkPassword(u.Password, tt.password); !ok || needRehash {
+					t.Errorf("重新哈希后校验结果 ok=%v needRehash=%v", ok, needRehash)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"im/core/storage"
+)
+
+// 角色。系统角色保存在 user_roles 表，群内角色由群成员表的角色映射而来
+const (
+	RoleUser        = "user"         // 所有登录用户
+	RoleModerator   = "moderator"    // 内容审核员
+	RoleAdmin       = "admin"        // 系统管理员
+	RoleGroupMember = "group_member" // 群成员
+	RoleGroupAdmin  = "group_admin"  // 群管理员
+	RoleGroupOwner  = "group_owner"  // 群主
+)
+
+// 操作
+const (
+	ActionGroupCreate   = "group.create"
+	ActionGroupInvite   = "group.invite"
+	ActionGroupKick     = "group.kick"
+	ActionGroupRename   = "group.rename"
+	ActionGroupSetAdmin = "group.set_admin"
+	ActionGroupDissolve = "group.dissolve"
+
+	ActionMessageRecall    = "message.recall"     // 撤回自己的消息
+	ActionMessageEdit      = "message.edit"       // 编辑自己的消息
+	ActionMessageRecallAny = "message.recall_any" // 撤回任何人的消息，不受时间限制
+
+	ActionFileUpload   = "file.upload"
+	ActionFileDownload = "file.download"
+	ActionFileDelete   = "file.delete"
+
+	ActionRoleAssign = "role.assign"
+	ActionStatsView  = "stats.view" // 查看连接和发送队列监控数据
+)
+
+// 默认权限，权限表为空时写入数据库，之后以数据库为准
+var DefaultPermissions = map[string][]string{
+	RoleUser:        {ActionGroupCreate, ActionMessageRecall, ActionMessageEdit, ActionFileUpload, ActionFileDownload},
+	RoleGroupMember: {ActionGroupInvite},
+	RoleGroupAdmin:  {ActionGroupInvite, ActionGroupKick, ActionGroupRename},
+	RoleGroupOwner:  {"group.*"},
+	RoleModerator:   {ActionGroupKick, ActionMessageRecallAny, ActionFileDelete},
+	RoleAdmin:       {"*"},
+}
+
+// 可以通过接口授予的系统角色
+var systemRoles = map[string]bool{RoleModerator: true, RoleAdmin: true}
+
+// 是否为系统角色
+func IsSystemRole(role string) bool {
+	return systemRoles[role]
+}
+
+// 群成员表中的角色对应的群内角色
+var groupRoles = map[string]string{
+	storage.GroupRoleMember: RoleGroupMember,
+	storage.GroupRoleAdmin:  RoleGroupAdmin,
+	storage.GroupRoleOwner:  RoleGroupOwner,
+}
+
+// GroupAction 生成限定在某个群内的操作，如 group.kick:42
+func GroupAction(action string, groupID int64) string {
+	return fmt.Sprintf("%s:%d", action, groupID)
+}
+
+// 拆分操作和所在群ID，不限定群时 groupID 为0
+func splitAction(action string) (string, int64) {
+	i := strings.LastIndexByte(action, ':')
+	if i < 0 {
+		return action, 0
+	}
+	groupID, err := strconv.ParseInt(action[i+1:], 10, 64)
+	if err != nil {
+		return action, 0
+	}
+	return action[:i], groupID
+}
+
+// 权限是否匹配操作，支持 * 和 group.* 形式的通配
+func matchAction(pattern, action string) bool {
+	if pattern == "*" || pattern == action {
+		return true
+	}
+	return strings.HasSuffix(pattern, ".*") && strings.HasPrefix(action, pattern[:len(pattern)-1])
+}
+
+// 权限缓存时间，直接修改数据库中的权限后最多延迟这么久生效
+const permissionCacheTTL = time.Minute
+
+// 基于角色的权限控制
+// 用户的角色为 user、系统角色，以及操作限定群时其在该群内的角色
+type RBAC struct {
+	sm *storage.StorageManager
+
+	mu       sync.Mutex
+	perms    map[string][]string
+	loadedAt time.Time
+}
+
+func NewRBAC(sm *storage.StorageManager) *RBAC {
+	return &RBAC{sm: sm}
+}
+
+// InitPermissions 权限表为空时写入默认权限
+func (a *RBAC) InitPermissions() error {
+	return a.sm.InitRolePermissions(DefaultPermissions)
+}
+
+func (a *RBAC) Authenticate(token string) (string, error) {
+	return ParseToken(token)
+}
+
+// 获取角色权限，过期后从数据库重新加载，加载失败时继续使用旧数据
+func (a *RBAC) permissions() map[string][]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.perms != nil && time.Since(a.loadedAt) < permissionCacheTTL {
+		return a.perms
+	}
+	perms, err := a.sm.GetRolePermissions()
+	if err != nil {
+		log.Printf("加载角色权限失败: %v", err)
+		return a.perms
+	}
+	a.perms, a.loadedAt = perms, time.Now()
+	return a.perms
+}
+
+// 用户在操作范围内拥有的角色
+func (a *RBAC) roles(userID string, groupID int64) []string {
+	roles := []string{RoleUser}
+	if sys, err := a.sm.GetUserRoles(userID); err == nil {
+		roles = append(roles, sys...)
+	} else {
+		log.Printf("获取用户 %s 的角色失败: %v", userID, err)
+	}
+	if groupID > 0 {
+		if member, err := a.sm.GetGroupMember(groupID, userID); err == nil {
+			if role, ok := groupRoles[member.Role]; ok {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+// Authorize 判断用户能否执行操作，群内操作使用 GroupAction 生成
+func (a *RBAC) Authorize(userID, action string) bool {
+	if userID == "" {
+		return false
+	}
+	name, groupID := splitAction(action)
+	perms := a.permissions()
+	for _, role := range a.roles(userID, groupID) {
+		for _, pattern := range perms[role] {
+			if matchAction(pattern, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GrantRole 授予系统角色
+func (a *RBAC) GrantRole(userID, role string) error {
+	if !IsSystemRole(role) {
+		return fmt.Errorf("不支持的角色: %s", role)
+	}
+	return a.sm.AddUserRole(userID, role, time.Now().Unix())
+}
+
+// RevokeRole 收回系统角色
+func (a *RBAC) RevokeRole(userID, role string) error {
+	if !IsSystemRole(role) {
+		return fmt.Errorf("不支持的角色: %s", role)
+	}
+	return a.sm.RemoveUserRole(userID, role)
+}
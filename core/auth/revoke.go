@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"im/core/storage"
+)
+
+// 吊销列表: 令牌ID/登录会话ID/用户标识 -> 吊销记录
+// 访问令牌有效期较短，记录只需保留到此前签发的令牌全部过期
+var revoked = struct {
+	sync.RWMutex
+	m map[string]*storage.RevokedToken
+}{m: make(map[string]*storage.RevokedToken)}
+
+// 令牌吊销后的回调，sessionID 为空表示吊销该用户的全部令牌
+var revokeHooks struct {
+	sync.RWMutex
+	fns []func(userID, sessionID string)
+}
+
+// 用户级吊销记录的键，记录之前签发的令牌全部失效
+func userRevokeKey(userID string) string {
+	return "user:" + userID
+}
+
+// 注册令牌吊销回调，用于断开使用该令牌登录的长连接
+func OnTokenRevoked(fn func(userID, sessionID string)) {
+	revokeHooks.Lock()
+	defer revokeHooks.Unlock()
+	revokeHooks.fns = append(revokeHooks.fns, fn)
+}
+
+func notifyRevoked(userID, sessionID string) {
+	revokeHooks.RLock()
+	defer revokeHooks.RUnlock()
+	for _, fn := range revokeHooks.fns {
+		fn(userID, sessionID)
+	}
+}
+
+// LoadRevokedTokens 启动时从数据库加载吊销列表，并清理过期的令牌记录
+func LoadRevokedTokens() error {
+	sm := storage.GetStorageManager()
+	now := time.Now().Unix()
+	if err := sm.DeleteExpiredTokens(now); err != nil {
+		log.Printf("清理过期令牌失败: %v", err)
+	}
+	list, err := sm.GetRevokedTokens(now)
+	if err != nil {
+		return err
+	}
+	revoked.Lock()
+	defer revoked.Unlock()
+	for _, t := range list {
+		revoked.m[t.TokenID] = t
+	}
+	return nil
+}
+
+// 添加吊销记录并持久化，持久化失败时仍在本进程内生效
+func addRevocation(tokenID, userID string) {
+	now := time.Now()
+	t := &storage.RevokedToken{
+		TokenID:   tokenID,
+		UserID:    userID,
+		RevokedAt: now.Unix(),
+		ExpiresAt: now.Add(tokenConfig.AccessTTL).Unix(),
+	}
+	revoked.Lock()
+	for id, r := range revoked.m {
+		if r.ExpiresAt <= t.RevokedAt {
+			delete(revoked.m, id)
+		}
+	}
+	revoked.m[tokenID] = t
+	revoked.Unlock()
+	if err := storage.GetStorageManager().AddRevokedToken(t); err != nil {
+		log.Printf("保存吊销记录 %s 失败: %v", tokenID, err)
+	}
+}
+
+// 令牌是否已被吊销
+func isRevoked(c *Claims) bool {
+	revoked.RLock()
+	defer revoked.RUnlock()
+	if _, ok := revoked.m[c.ID]; ok {
+		return true
+	}
+	if _, ok := revoked.m[c.SessionID]; ok && c.SessionID != "" {
+		return true
+	}
+	// 用户级吊销只影响吊销之前签发的令牌
+	if r, ok := revoked.m[userRevokeKey(c.UserID)]; ok {
+		return c.IssuedAt == nil || c.IssuedAt.Unix() < r.RevokedAt
+	}
+	return false
+}
+
+// RevokeSession 吊销一次登录的全部令牌（登出），并断开该会话的长连接
+func RevokeSession(userID, sessionID string) error {
+	if err := storage.GetStorageManager().RevokeRefreshTokenFamily(sessionID); err != nil {
+		return err
+	}
+	addRevocation(sessionID, userID)
+	notifyRevoked(userID, sessionID)
+	return nil
+}
+
+// RevokeUserTokens 吊销用户此前签发的全部令牌（修改密码、注销账号），并断开其全部长连接
+func RevokeUserTokens(userID string) error {
+	if err := storage.GetStorageManager().RevokeUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	addRevocation(userRevokeKey(userID), userID)
+	notifyRevoked(userID, "")
+	return nil
+}
@@ -1,41 +1,191 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
+	"im/config"
+	"im/core/storage"
+
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// JwtKey 用于签名token，生产环境应通过配置文件设置
-var JwtKey = []byte("your_secret_key")
+var tokenConfig = config.GetTokenConfig()
+
+// 签名密钥: kid -> 密钥，新令牌使用 activeKeyID 签名，校验时按令牌头部的 kid 选择密钥
+// 配置错误时密钥为空，服务启动时会因配置校验失败而退出
+var signingKeys, activeKeyID, _ = config.Get().JWT.LoadKeys()
 
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid"` // 登录会话ID，同一次登录刷新出的令牌相同
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成用户token
-func GenerateToken(userID string) (string, error) {
+// 一次登录或刷新签发的令牌
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // 访问令牌有效期（秒）
+	SessionID    string
+}
+
+var (
+	ErrTokenRevoked   = errors.New("token已失效")
+	ErrRefreshInvalid = errors.New("refresh token无效")
+)
+
+// 生成随机ID
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateToken 为登录会话生成短期访问令牌
+func GenerateToken(userID, sessionID string) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenConfig.AccessTTL)),
 		},
 	}
+	key, ok := signingKeys[activeKeyID]
+	if !ok {
+		return "", errors.New("未配置JWT签名密钥")
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(JwtKey)
+	token.Header["kid"] = activeKeyID
+	return token.SignedString(key)
 }
 
-// ParseToken 校验token并返回userID
-func ParseToken(tokenStr string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return JwtKey, nil
+// 签发新的刷新令牌并保存哈希
+func newRefreshToken(userID, sessionID string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	err := storage.GetStorageManager().SaveRefreshToken(&storage.RefreshToken{
+		TokenHash: hashRefreshToken(token),
+		UserID:    userID,
+		FamilyID:  sessionID,
+		ExpiresAt: time.Now().Add(tokenConfig.RefreshTTL).Unix(),
 	})
 	if err != nil {
 		return "", err
 	}
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.UserID, nil
+	return token, nil
+}
+
+func issuePair(userID, sessionID string) (*TokenPair, error) {
+	refresh, err := newRefreshToken(userID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("保存refresh token失败: %v", err)
+	}
+	access, err := GenerateToken(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(tokenConfig.AccessTTL / time.Second),
+		SessionID:    sessionID,
+	}, nil
+}
+
+// IssueTokens 登录成功后开启新的登录会话并签发令牌
+func IssueTokens(userID string) (*TokenPair, error) {
+	sessionID, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+	return issuePair(userID, sessionID)
+}
+
+// RefreshTokens 使用刷新令牌换取新的令牌，旧刷新令牌随即失效
+// 已使用过的刷新令牌再次出现说明可能被盗用，吊销整个登录会话
+func RefreshTokens(refreshToken string) (*TokenPair, error) {
+	sm := storage.GetStorageManager()
+	hash := hashRefreshToken(refreshToken)
+	t, err := sm.GetRefreshToken(hash)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() >= t.ExpiresAt {
+		return nil, ErrRefreshInvalid
+	}
+	if !t.Revoked {
+		// 并发刷新时只有一个请求能标记成功
+		ok, err := sm.RevokeRefreshToken(hash)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return issuePair(t.UserID, t.FamilyID)
+		}
+	}
+	if err := RevokeSession(t.UserID, t.FamilyID); err != nil {
+		return nil, err
+	}
+	return nil, ErrTokenRevoked
+}
+
+// ParseClaims 校验token签名、有效期及吊销状态
+func ParseClaims(tokenStr string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("不支持的签名算法: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := signingKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("未知的签名密钥: %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("token无效")
+	}
+	if isRevoked(claims) {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// ParseToken 校验token并返回userID
+func ParseToken(tokenStr string) (string, error) {
+	claims, err := ParseClaims(tokenStr)
+	if err != nil {
+		return "", err
 	}
-	return "", err
+	return claims.UserID, nil
 }
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"im/core/storage"
+)
+
+// 测试期间使用固定的签名密钥，结束后恢复
+func useTestSigningKey(t *testing.T) {
+	t.Helper()
+	prevKeys, prevID := signingKeys, activeKeyID
+	signingKeys = map[string][]byte{"test": []byte("0123456789abcdef0123456789abcdef")}
+	activeKeyID = "test"
+	t.Cleanup(func() { signingKeys, activeKeyID = prevKeys, prevID })
+}
+
+func TestRefreshTokensRotation(t *testing.T) {
+	useMemoryStore(t)
+	useTestSigningKey(t)
+
+	first, err := IssueTokens("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RefreshTokens(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("刷新令牌失败: %v", err)
+	}
+	if second.SessionID != first.SessionID || second.RefreshToken == first.RefreshToken {
+		t.Errorf("刷新后应保持会话并轮换刷新令牌: %+v", second)
+	}
+	if claims, err := ParseClaims(second.AccessToken); err != nil || claims.UserID != "1" {
+		t.Errorf("新访问令牌校验 = %+v, %v", claims, err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	useMemoryStore(t)
+	useTestSigningKey(t)
+
+	first, err := IssueTokens("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RefreshTokens(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := IssueTokens("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 已使用过的刷新令牌再次出现，视为被盗用
+	if _, err := RefreshTokens(first.RefreshToken); err != ErrTokenRevoked {
+		t.Fatalf("重复使用刷新令牌返回 %v，期望 ErrTokenRevoked", err)
+	}
+	// 同一会话轮换出的令牌全部失效
+	if _, err := RefreshTokens(second.RefreshToken); err != ErrTokenRevoked {
+		t.Errorf("会话被吊销后刷新返回 %v，期望 ErrTokenRevoked", err)
+	}
+	if _, err := ParseClaims(second.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("会话被吊销后访问令牌校验返回 %v，期望 ErrTokenRevoked", err)
+	}
+	// 其他登录会话不受影响
+	if _, err := RefreshTokens(other.RefreshToken); err != nil {
+		t.Errorf("其他会话刷新失败: %v", err)
+	}
+}
+
+func TestRefreshTokensInvalid(t *testing.T) {
+	mem := useMemoryStore(t)
+	useTestSigningKey(t)
+
+	expired := "expired-token"
+	if err := mem.SaveRefreshToken(&storage.RefreshToken{TokenHash: hashRefreshToken(expired), UserID: "1",
+		FamilyID: "f", ExpiresAt: time.Now().Add(-time.Minute).Unix()}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"不存在的令牌", "unknown-token"},
+		{"已过期的令牌", expired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RefreshTokens(tt.token); err != ErrRefreshInvalid {
+				t.Errorf("RefreshTokens 返回 %v，期望 ErrRefreshInvalid", err)
+			}
+		})
+	}
+}
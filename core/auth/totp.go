@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"im/core/storage"
+)
+
+// TOTP参数（RFC 6238），与常见验证器应用的默认值一致
+const (
+	totpIssuer = "IM"
+	totpPeriod = 30 // 时间步长（秒）
+	totpDigits = 6
+	totpSkew   = 1 // 允许前后各偏差一个时间步
+
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrTOTPNotEnabled  = errors.New("未开启两步验证")
+	ErrTOTPEnabled     = errors.New("已开启两步验证")
+	ErrTOTPCodeInvalid = errors.New("两步验证码错误")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// 计算某个时间步的验证码
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// 校验验证码，返回匹配的时间步
+func matchTOTP(secretB32, code string, now time.Time) (int64, bool) {
+	secret, err := totpEncoding.DecodeString(strings.ToUpper(secretB32))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(totpCode(secret, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// 生成otpauth URI，供验证器应用扫码添加
+func totpURI(uid, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", totpIssuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(totpIssuer + ":" + uid)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// 恢复码哈希，忽略大小写和分隔符
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// 生成恢复码，格式 xxxxx-xxxxx
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes, nil
+}
+
+// 用户是否已开启两步验证
+func TOTPEnabled(uid string) (bool, error) {
+	t, err := storage.GetStorageManager().GetTOTP(uid)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.Enabled, nil
+}
+
+// SetupTOTP 生成新的密钥，需要用 EnableTOTP 确认后才生效，返回Base32密钥和otpauth URI
+func SetupTOTP(uid string) (secret, uri string, err error) {
+	if enabled, err := TOTPEnabled(uid); err != nil {
+		return "", "", err
+	} else if enabled {
+		return "", "", ErrTOTPEnabled
+	}
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = totpEncoding.EncodeToString(b)
+	if err := storage.GetStorageManager().SaveTOTPSecret(uid, secret); err != nil {
+		return "", "", err
+	}
+	return secret, totpURI(uid, secret), nil
+}
+
+// EnableTOTP 使用验证器生成的第一个验证码确认开启，返回一次性恢复码
+func EnableTOTP(uid, code string) ([]string, error) {
+	sm := storage.GetStorageManager()
+	t, err := sm.GetTOTP(uid)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("请先生成两步验证密钥")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Enabled {
+		return nil, ErrTOTPEnabled
+	}
+	step, ok := matchTOTP(t.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrTOTPCodeInvalid
+	}
+	if _, err := sm.UpdateTOTPLastStep(uid, step); err != nil {
+		return nil, err
+	}
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = hashRecoveryCode(c)
+	}
+	if err := sm.EnableTOTP(uid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifySecondFactor 校验两步验证码或恢复码，验证码和恢复码都只能使用一次
+func VerifySecondFactor(uid, code string) error {
+	sm := storage.GetStorageManager()
+	t, err := sm.GetTOTP(uid)
+	if err == sql.ErrNoRows || (err == nil && !t.Enabled) {
+		return ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+	code = strings.TrimSpace(code)
+	if step, ok := matchTOTP(t.Secret, code, time.Now()); ok {
+		if step <= t.LastStep {
+			return ErrTOTPCodeInvalid
+		}
+		updated, err := sm.UpdateTOTPLastStep(uid, step)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return ErrTOTPCodeInvalid
+		}
+		return nil
+	}
+	used, err := sm.UseRecoveryCode(uid, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrTOTPCodeInvalid
+	}
+	return nil
+}
+
+// DisableTOTP 校验验证码或恢复码后关闭两步验证
+func DisableTOTP(uid, code string) error {
+	if err := VerifySecondFactor(uid, code); err != nil {
+		return err
+	}
+	return storage.GetStorageManager().DeleteTOTP(uid)
+}
+
+// 登录第二步的挑战，密码验证通过后签发，验证码校验通过后才签发令牌
+const (
+	challengeTTL         = 5 * time.Minute
+	challengeMaxAttempts = 5
+)
+
+type loginChallenge struct {
+	uid       string
+	expiresAt time.Time
+	attempts  int
+}
+
+var challenges = struct {
+	sync.Mutex
+	m map[string]*loginChallenge
+}{m: make(map[string]*loginChallenge)}
+
+var ErrChallengeInvalid = errors.New("登录验证已过期，请重新登录")
+
+// NewLoginChallenge 密码验证通过后为开启两步验证的用户创建挑战
+func NewLoginChallenge(uid string) (string, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	challenges.Lock()
+	defer challenges.Unlock()
+	for k, c := range challenges.m {
+		if now.After(c.expiresAt) {
+			delete(challenges.m, k)
+		}
+	}
+	challenges.m[id] = &loginChallenge{uid: uid, expiresAt: now.Add(challengeTTL)}
+	return id, nil
+}
+
+// CompleteLoginChallenge 校验挑战对应用户的验证码，成功后挑战失效并返回用户ID
+// 验证码错误时同样返回用户ID，便于记录失败；错误次数过多时挑战失效，需要重新输入密码
+func CompleteLoginChallenge(id, code string) (string, error) {
+	challenges.Lock()
+	c, ok := challenges.m[id]
+	if !ok || time.Now().After(c.expiresAt) {
+		delete(challenges.m, id)
+		challenges.Unlock()
+		return "", ErrChallengeInvalid
+	}
+	c.attempts++
+	if c.attempts > challengeMaxAttempts {
+		delete(challenges.m, id)
+		challenges.Unlock()
+		return "", ErrChallengeInvalid
+	}
+	uid := c.uid
+	challenges.Unlock()
+
+	if err := VerifySecondFactor(uid, code); err != nil {
+		return uid, err
+	}
+	challenges.Lock()
+	delete(challenges.m, id)
+	challenges.Unlock()
+	return uid, nil
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 生成某个时间步的验证码
+func codeAt(t *testing.T, secretB32 string, step int64) string {
+	t.Helper()
+	secret, err := totpEncoding.DecodeString(secretB32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return totpCode(secret, step)
+}
+
+// 开启两步验证，用上一个时间步的验证码确认，返回密钥和恢复码
+func enableTestTOTP(t *testing.T, uid string) (string, []string) {
+	t.Helper()
+	secret, _, err := SetupTOTP(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := EnableTOTP(uid, codeAt(t, secret, time.Now().Unix()/totpPeriod-1))
+	if err != nil {
+		t.Fatalf("开启两步验证失败: %v", err)
+	}
+	return secret, codes
+}
+
+func TestMatchTOTP(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1700000000, 0)
+	current := now.Unix() / totpPeriod
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"当前时间步", codeAt(t, secret, current), true},
+		{"允许偏差一个时间步", codeAt(t, secret, current-1), true},
+		{"超出偏差范围", codeAt(t, secret, current-2), false},
+		{"长度错误", "12345", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := matchTOTP(secret, tt.code, now); ok != tt.want {
+				t.Errorf("matchTOTP = %v，期望 %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySecondFactorRejectsReplay(t *testing.T) {
+	useMemoryStore(t)
+	secret, _ := enableTestTOTP(t, "1")
+	current := time.Now().Unix() / totpPeriod
+
+	steps := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"新的时间步", codeAt(t, secret, current), nil},
+		{"重复使用同一验证码", codeAt(t, secret, current), ErrTOTPCodeInvalid},
+		{"更早的时间步", codeAt(t, secret, current-1), ErrTOTPCodeInvalid},
+		{"错误的验证码", "000000", ErrTOTPCodeInvalid},
+	}
+	for _, st := range steps {
+		if err := VerifySecondFactor("1", st.code); err != st.want {
+			t.Errorf("%s: VerifySecondFactor 返回 %v，期望 %v", st.name, err, st.want)
+		}
+	}
+}
+
+func TestRecoveryCodesSingleUse(t *testing.T) {
+	mem := useMemoryStore(t)
+	_, codes := enableTestTOTP(t, "1")
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("生成了 %d 个恢复码，期望 %d 个", len(codes), recoveryCodeCount)
+	}
+
+	steps := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"首次使用", codes[0], nil},
+		{"重复使用", codes[0], ErrTOTPCodeInvalid},
+		{"忽略大小写和分隔符", strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")), nil},
+		{"不存在的恢复码", "aaaaa-bbbbb", ErrTOTPCodeInvalid},
+	}
+	for _, st := range steps {
+		if err := VerifySecondFactor("1", st.code); err != st.want {
+			t.Errorf("%s: VerifySecondFactor 返回 %v，期望 %v", st.name, err, st.want)
+		}
+	}
+	if n, err := mem.CountRecoveryCodes("1"); err != nil || n != recoveryCodeCount-2 {
+		t.Errorf("剩余恢复码 %d, %v，期望 %d", n, err, recoveryCodeCount-2)
+	}
+}
+
+func TestVerifySecondFactorNotEnabled(t *testing.T) {
+	useMemoryStore(t)
+	if err := VerifySecondFactor("1", "123456"); err != ErrTOTPNotEnabled {
+		t.Errorf("未开启时返回 %v，期望 ErrTOTPNotEnabled", err)
+	}
+	// 只生成密钥还未确认开启
+	if _, _, err := SetupTOTP("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySecondFactor("1", "123456"); err != ErrTOTPNotEnabled {
+		t.Errorf("未确认开启时返回 %v，期望 ErrTOTPNotEnabled", err)
+	}
+}
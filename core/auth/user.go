@@ -20,6 +20,7 @@ type UserStore interface {
 	GetByEmail(email string) (*User, error)
 	UpdateUsername(uid, newUsername string) error
 	UpdatePassword(uid, oldPwd, newPwd string) error
+	UpdateEmail(uid, newEmail string) error
 	ResetPasswordByEmail(email, newPwd string) error
 	DeleteAccount(uid string) error
 }
@@ -101,6 +102,20 @@ func (m *MemUserStore) UpdatePassword(uid, oldPwd, newPwd string) error {
 	return nil
 }
 
+func (m *MemUserStore) UpdateEmail(uid, newEmail string) error {
+	user, ok := users[uid]
+	if !ok {
+		return errors.New("用户不存在")
+	}
+	if other, ok := emailIndex[newEmail]; ok && other != uid {
+		return errors.New("邮箱已被注册")
+	}
+	delete(emailIndex, user.Email)
+	user.Email = newEmail
+	emailIndex[newEmail] = uid
+	return nil
+}
+
 func (m *MemUserStore) ResetPasswordByEmail(email, newPwd string) error {
 	user, err := m.GetByEmail(email)
 	if err != nil {
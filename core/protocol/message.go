@@ -0,0 +1,209 @@
+package protocol
+
+import (
+	"fmt"
+	pb "im/core/protocol/pb"
+	"im/core/storage"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 需要持久化的聊天消息类型
+var storableTypes = map[string]bool{
+	"chat":       true,
+	"emoji":      true,
+	"image":      true,
+	"file":       true,
+	"group_chat": true,
+}
+
+// 判断消息类型是否需要持久化
+func IsStorableType(msgType string) bool {
+	return storableTypes[msgType]
+}
+
+// IMMessage 转换为存储结构
+func MessageFromPB(msg *pb.IMMessage) *storage.Message {
+	return &storage.Message{
+		FromUserID:    msg.From,
+		ToUserID:      msg.To,
+		GroupID:       msg.GroupId,
+		Type:          msg.Type,
+		Content:       msg.Content,
+		Extra:         msg.Extra,
+		Filename:      msg.Filename,
+		Filesize:      msg.Filesize,
+		MimeType:      msg.MimeType,
+		Timestamp:     msg.Timestamp,
+		ReplyToID:     msg.ReplyToId,
+		ForwardFromID: msg.ForwardFromId,
+	}
+}
+
+// 存储结构转换为 IMMessage
+func MessageToPB(m *storage.Message) *pb.IMMessage {
+	return &pb.IMMessage{
+		Type:          m.Type,
+		From:          m.FromUserID,
+		To:            m.ToUserID,
+		Content:       m.Content,
+		Extra:         m.Extra,
+		Timestamp:     m.Timestamp,
+		Filename:      m.Filename,
+		Filesize:      m.Filesize,
+		MimeType:      m.MimeType,
+		MsgId:         m.ID,
+		GroupId:       m.GroupID,
+		Recalled:      m.Recalled,
+		EditedAt:      m.EditedAt,
+		ReplyToId:     m.ReplyToID,
+		ForwardFromId: m.ForwardFromID,
+	}
+}
+
+// 用户能否看到该消息：单聊的双方或群成员
+func CanSeeMessage(userID string, m *storage.Message) bool {
+	if m.GroupID != 0 {
+		_, err := storage.GetStorageManager().GetGroupMember(m.GroupID, userID)
+		return err == nil
+	}
+	return m.FromUserID == userID || m.ToUserID == userID
+}
+
+// 被回复消息的摘要，只保留展示需要的字段
+func quoteOf(m *storage.Message) *pb.IMMessage {
+	return &pb.IMMessage{
+		Type:     m.Type,
+		From:     m.FromUserID,
+		Content:  m.Content,
+		Extra:    m.Extra,
+		MsgId:    m.ID,
+		Recalled: m.Recalled,
+	}
+}
+
+// 为消息填充被回复消息的摘要和转发来源
+func AttachReferences(msgs []*pb.IMMessage) {
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, m := range msgs {
+		for _, id := range []int64{m.ReplyToId, m.ForwardFromId} {
+			if id != 0 && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	refs, err := storage.GetStorageManager().GetMessagesByIDs(ids)
+	if err != nil {
+		return
+	}
+	byID := make(map[int64]*storage.Message, len(refs))
+	for _, r := range refs {
+		byID[r.ID] = r
+	}
+	for _, m := range msgs {
+		if r := byID[m.ReplyToId]; r != nil {
+			m.Quoted = quoteOf(r)
+		}
+		if f := byID[m.ForwardFromId]; f != nil {
+			m.ForwardFromUid = f.FromUserID
+		}
+	}
+}
+
+// 回应汇总转换为 protobuf 结构
+func ReactionsToPB(reactions []*storage.Reaction) []*pb.Reaction {
+	list := make([]*pb.Reaction, 0, len(reactions))
+	for _, r := range reactions {
+		list = append(list, &pb.Reaction{Emoji: r.Emoji, UserIds: r.UserIDs})
+	}
+	return list
+}
+
+// 为消息填充回应汇总
+func AttachReactions(msgs []*pb.IMMessage) {
+	ids := make([]int64, 0, len(msgs))
+	for _, m := range msgs {
+		if m.MsgId != 0 {
+			ids = append(ids, m.MsgId)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	reactions, err := storage.GetStorageManager().GetReactions(ids)
+	if err != nil {
+		return
+	}
+	for _, m := range msgs {
+		if r := reactions[m.MsgId]; len(r) > 0 {
+			m.Reactions = ReactionsToPB(r)
+		}
+	}
+}
+
+// 存储消息批量转换为 IMMessage，并填充引用信息与回应
+func messagesToPB(messages []*storage.Message) []*pb.IMMessage {
+	list := make([]*pb.IMMessage, 0, len(messages))
+	for _, m := range messages {
+		list = append(list, MessageToPB(m))
+	}
+	AttachReferences(list)
+	AttachReactions(list)
+	return list
+}
+
+// 登录后按顺序推送离线消息（包括之前未确认的消息），客户端确认后才从离线表中移除
+func deliverOfflineMessages(userID string, conn *WSConn) (map[int64]bool, error) {
+	messages, err := storage.GetStorageManager().GetOfflineMessages(userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取离线消息失败: %v", err)
+	}
+	delivered := make(map[int64]bool, len(messages))
+	for _, m := range messagesToPB(messages) {
+		b, _ := proto.Marshal(m)
+		if err := conn.enqueue(b, conn.writeTimeout); err != nil {
+			return delivered, fmt.Errorf("推送离线消息失败: %v", err)
+		}
+		pending.add(userID, m.MsgId, b)
+		delivered[m.MsgId] = true
+	}
+	return delivered, nil
+}
+
+const (
+	resumeBatchSize   = 200  // 断线重连补发时每批查询的消息数
+	maxResumeMessages = 1000 // 单次重连最多补发的消息数，更早的消息通过聊天记录接口获取
+)
+
+// 断线重连后补发 afterID 之后的消息，包括已被其他设备确认、不在离线消息表中的消息
+// skip 为已作为离线消息推送过的消息ID
+func resumeMessages(userID string, conn *WSConn, afterID int64, skip map[int64]bool) error {
+	sm := storage.GetStorageManager()
+	sent := 0
+	for sent < maxResumeMessages {
+		messages, err := sm.GetMessagesAfter(userID, afterID, resumeBatchSize)
+		if err != nil {
+			return fmt.Errorf("获取重连补发消息失败: %v", err)
+		}
+		for _, m := range messagesToPB(messages) {
+			afterID = m.MsgId
+			if skip[m.MsgId] {
+				continue
+			}
+			b, _ := proto.Marshal(m)
+			if err := conn.enqueue(b, conn.writeTimeout); err != nil {
+				return fmt.Errorf("推送重连补发消息失败: %v", err)
+			}
+			sent++
+		}
+		if len(messages) < resumeBatchSize {
+			break
+		}
+	}
+	return nil
+}
@@ -323,6 +323,9 @@ type FriendListResp struct {
 	Code            int32                  `protobuf:"varint,3,opt,name=code,proto3" json:"code,omitempty"`
 	Msg             string                 `protobuf:"bytes,4,opt,name=msg,proto3" json:"msg,omitempty"`
 	Remarks         []string               `protobuf:"bytes,5,rep,name=remarks,proto3" json:"remarks,omitempty"`
+	UnreadCounts    []int64                `protobuf:"varint,6,rep,packed,name=unread_counts,json=unreadCounts,proto3" json:"unread_counts,omitempty"` // 与 friend_uids 一一对应的未读消息数
+	Presences       []string               `protobuf:"bytes,7,rep,name=presences,proto3" json:"presences,omitempty"`                                   // 与 friend_uids 一一对应的在线状态: online, away, offline
+	LastSeens       []int64                `protobuf:"varint,8,rep,packed,name=last_seens,json=lastSeens,proto3" json:"last_seens,omitempty"`          // 与 friend_uids 一一对应的最后在线时间（Unix秒）
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -392,6 +395,27 @@ func (x *FriendListResp) GetRemarks() []string {
 	return nil
 }
 
+func (x *FriendListResp) GetUnreadCounts() []int64 {
+	if x != nil {
+		return x.UnreadCounts
+	}
+	return nil
+}
+
+func (x *FriendListResp) GetPresences() []string {
+	if x != nil {
+		return x.Presences
+	}
+	return nil
+}
+
+func (x *FriendListResp) GetLastSeens() []int64 {
+	if x != nil {
+		return x.LastSeens
+	}
+	return nil
+}
+
 // 删除好友
 type DeleteFriendReq struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -773,6 +797,8 @@ type FriendInfoResp struct {
 	Dnd           bool                   `protobuf:"varint,5,opt,name=dnd,proto3" json:"dnd,omitempty"`
 	Code          int32                  `protobuf:"varint,6,opt,name=code,proto3" json:"code,omitempty"`
 	Msg           string                 `protobuf:"bytes,7,opt,name=msg,proto3" json:"msg,omitempty"`
+	Presence      string                 `protobuf:"bytes,8,opt,name=presence,proto3" json:"presence,omitempty"`                  // 在线状态: online, away, offline
+	LastSeen      int64                  `protobuf:"varint,9,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"` // 最后在线时间（Unix秒）
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -856,6 +882,20 @@ func (x *FriendInfoResp) GetMsg() string {
 	return ""
 }
 
+func (x *FriendInfoResp) GetPresence() string {
+	if x != nil {
+		return x.Presence
+	}
+	return ""
+}
+
+func (x *FriendInfoResp) GetLastSeen() int64 {
+	if x != nil {
+		return x.LastSeen
+	}
+	return 0
+}
+
 // 设置消息免打扰
 type SetDNDReq struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -1001,14 +1041,18 @@ const file_core_protocol_friend_proto_rawDesc = "" +
 	"\x03msg\x18\x02 \x01(\tR\x03msg\"7\n" +
 	"\rFriendListReq\x12\x10\n" +
 	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x14\n" +
-	"\x05token\x18\x02 \x01(\tR\x05token\"\x9c\x01\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\"\xfe\x01\n" +
 	"\x0eFriendListResp\x12\x1f\n" +
 	"\vfriend_uids\x18\x01 \x03(\tR\n" +
 	"friendUids\x12)\n" +
 	"\x10friend_usernames\x18\x02 \x03(\tR\x0ffriendUsernames\x12\x12\n" +
 	"\x04code\x18\x03 \x01(\x05R\x04code\x12\x10\n" +
 	"\x03msg\x18\x04 \x01(\tR\x03msg\x12\x18\n" +
-	"\aremarks\x18\x05 \x03(\tR\aremarks\"X\n" +
+	"\aremarks\x18\x05 \x03(\tR\aremarks\x12#\n" +
+	"\runread_counts\x18\x06 \x03(\x03R\funreadCounts\x12\x1c\n" +
+	"\tpresences\x18\a \x03(\tR\tpresences\x12\x1d\n" +
+	"\n" +
+	"last_seens\x18\b \x03(\x03R\tlastSeens\"X\n" +
 	"\x0fDeleteFriendReq\x12\x10\n" +
 	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x1d\n" +
 	"\n" +
@@ -1037,7 +1081,7 @@ const file_core_protocol_friend_proto_rawDesc = "" +
 	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x1d\n" +
 	"\n" +
 	"friend_uid\x18\x02 \x01(\tR\tfriendUid\x12\x14\n" +
-	"\x05token\x18\x03 \x01(\tR\x05token\"\xa4\x01\n" +
+	"\x05token\x18\x03 \x01(\tR\x05token\"\xdd\x01\n" +
 	"\x0eFriendInfoResp\x12\x10\n" +
 	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x1a\n" +
 	"\busername\x18\x02 \x01(\tR\busername\x12\x14\n" +
@@ -1045,7 +1089,9 @@ const file_core_protocol_friend_proto_rawDesc = "" +
 	"\x06remark\x18\x04 \x01(\tR\x06remark\x12\x10\n" +
 	"\x03dnd\x18\x05 \x01(\bR\x03dnd\x12\x12\n" +
 	"\x04code\x18\x06 \x01(\x05R\x04code\x12\x10\n" +
-	"\x03msg\x18\a \x01(\tR\x03msg\"d\n" +
+	"\x03msg\x18\a \x01(\tR\x03msg\x12\x1a\n" +
+	"\bpresence\x18\b \x01(\tR\bpresence\x12\x1b\n" +
+	"\tlast_seen\x18\t \x01(\x03R\blastSeen\"d\n" +
 	"\tSetDNDReq\x12\x10\n" +
 	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x1d\n" +
 	"\n" +
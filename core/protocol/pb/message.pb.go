@@ -22,20 +22,31 @@ const (
 )
 
 type IMMessage struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`                          // 消息类型: text, emoji, image, file, etc.
-	From          string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`                          // 发送方UID
-	To            string                 `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`                              // 接收方UID
-	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`                    // 文本内容、表情代码、图片URL、文件URL等
-	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`               // 消息时间戳
-	Extra         string                 `protobuf:"bytes,6,opt,name=extra,proto3" json:"extra,omitempty"`                        // 扩展字段（如图片缩略图、文件名、文件大小等）
-	Token         string                 `protobuf:"bytes,7,opt,name=token,proto3" json:"token,omitempty"`                        // 登录鉴权token
-	Data          []byte                 `protobuf:"bytes,8,opt,name=data,proto3" json:"data,omitempty"`                          // 二进制数据（图片、文件等）
-	Filename      string                 `protobuf:"bytes,9,opt,name=filename,proto3" json:"filename,omitempty"`                  // 文件名
-	Filesize      int64                  `protobuf:"varint,10,opt,name=filesize,proto3" json:"filesize,omitempty"`                // 文件大小（字节）
-	MimeType      string                 `protobuf:"bytes,11,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"` // MIME类型
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Type      string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`                          // 消息类型: chat, emoji, image, file, group_chat, ack, sent, read, typing, presence, recall, edit, forward, reaction, etc.
+	From      string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`                          // 发送方UID
+	To        string                 `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`                              // 接收方UID
+	Content   string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`                    // 文本内容、表情代码、图片URL、文件URL等
+	Timestamp int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`               // 消息时间戳
+	Extra     string                 `protobuf:"bytes,6,opt,name=extra,proto3" json:"extra,omitempty"`                        // 扩展字段（如图片缩略图、文件名、文件大小等）
+	Token     string                 `protobuf:"bytes,7,opt,name=token,proto3" json:"token,omitempty"`                        // 登录鉴权token
+	Data      []byte                 `protobuf:"bytes,8,opt,name=data,proto3" json:"data,omitempty"`                          // 二进制数据（图片、文件等）
+	Filename  string                 `protobuf:"bytes,9,opt,name=filename,proto3" json:"filename,omitempty"`                  // 文件名
+	Filesize  int64                  `protobuf:"varint,10,opt,name=filesize,proto3" json:"filesize,omitempty"`                // 文件大小（字节）
+	MimeType  string                 `protobuf:"bytes,11,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"` // MIME类型
+	MsgId     int64                  `protobuf:"varint,12,opt,name=msg_id,json=msgId,proto3" json:"msg_id,omitempty"`         // 服务器分配的消息ID，单调递增（会话内有序），ack 消息用它确认送达；
+	// login 请求中为客户端收到的最后一条消息ID，用于断线重连后补发，login 响应中为当前最新消息ID
+	GroupId        int64       `protobuf:"varint,13,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`                       // 群ID，group_chat 消息使用
+	DeviceId       string      `protobuf:"bytes,14,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`                     // 登录设备ID，同一用户可在多个设备同时登录
+	Recalled       bool        `protobuf:"varint,15,opt,name=recalled,proto3" json:"recalled,omitempty"`                                    // 消息已撤回
+	EditedAt       int64       `protobuf:"varint,16,opt,name=edited_at,json=editedAt,proto3" json:"edited_at,omitempty"`                    // 最后编辑时间（Unix秒），未编辑为0
+	ReplyToId      int64       `protobuf:"varint,17,opt,name=reply_to_id,json=replyToId,proto3" json:"reply_to_id,omitempty"`               // 回复的消息ID
+	ForwardFromId  int64       `protobuf:"varint,18,opt,name=forward_from_id,json=forwardFromId,proto3" json:"forward_from_id,omitempty"`   // 转发的原始消息ID，forward 请求中为要转发的消息ID
+	Quoted         *IMMessage  `protobuf:"bytes,19,opt,name=quoted,proto3" json:"quoted,omitempty"`                                         // 被回复消息的摘要，由服务器填充
+	ForwardFromUid string      `protobuf:"bytes,20,opt,name=forward_from_uid,json=forwardFromUid,proto3" json:"forward_from_uid,omitempty"` // 原始消息的发送者，由服务器填充
+	Reactions      []*Reaction `protobuf:"bytes,21,rep,name=reactions,proto3" json:"reactions,omitempty"`                                   // 消息的回应汇总，由服务器填充
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *IMMessage) Reset() {
@@ -145,6 +156,129 @@ func (x *IMMessage) GetMimeType() string {
 	return ""
 }
 
+func (x *IMMessage) GetMsgId() int64 {
+	if x != nil {
+		return x.MsgId
+	}
+	return 0
+}
+
+func (x *IMMessage) GetGroupId() int64 {
+	if x != nil {
+		return x.GroupId
+	}
+	return 0
+}
+
+func (x *IMMessage) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *IMMessage) GetRecalled() bool {
+	if x != nil {
+		return x.Recalled
+	}
+	return false
+}
+
+func (x *IMMessage) GetEditedAt() int64 {
+	if x != nil {
+		return x.EditedAt
+	}
+	return 0
+}
+
+func (x *IMMessage) GetReplyToId() int64 {
+	if x != nil {
+		return x.ReplyToId
+	}
+	return 0
+}
+
+func (x *IMMessage) GetForwardFromId() int64 {
+	if x != nil {
+		return x.ForwardFromId
+	}
+	return 0
+}
+
+func (x *IMMessage) GetQuoted() *IMMessage {
+	if x != nil {
+		return x.Quoted
+	}
+	return nil
+}
+
+func (x *IMMessage) GetForwardFromUid() string {
+	if x != nil {
+		return x.ForwardFromUid
+	}
+	return ""
+}
+
+func (x *IMMessage) GetReactions() []*Reaction {
+	if x != nil {
+		return x.Reactions
+	}
+	return nil
+}
+
+// 消息上某个表情的回应汇总
+type Reaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Emoji         string                 `protobuf:"bytes,1,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	UserIds       []string               `protobuf:"bytes,2,rep,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"` // 按回应时间排序
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Reaction) Reset() {
+	*x = Reaction{}
+	mi := &file_core_protocol_message_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reaction) ProtoMessage() {}
+
+func (x *Reaction) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reaction.ProtoReflect.Descriptor instead.
+func (*Reaction) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Reaction) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+func (x *Reaction) GetUserIds() []string {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
 type APIResp struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
@@ -156,7 +290,7 @@ type APIResp struct {
 
 func (x *APIResp) Reset() {
 	*x = APIResp{}
-	mi := &file_core_protocol_message_proto_msgTypes[1]
+	mi := &file_core_protocol_message_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -168,7 +302,7 @@ func (x *APIResp) String() string {
 func (*APIResp) ProtoMessage() {}
 
 func (x *APIResp) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[1]
+	mi := &file_core_protocol_message_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -181,7 +315,7 @@ func (x *APIResp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use APIResp.ProtoReflect.Descriptor instead.
 func (*APIResp) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{1}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *APIResp) GetCode() int32 {
@@ -210,13 +344,15 @@ type RegisterReq struct {
 	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
 	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
 	Email         string                 `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	Code          string                 `protobuf:"bytes,4,opt,name=code,proto3" json:"code,omitempty"` // 邮箱验证码，用途为 register
+	Uid           string                 `protobuf:"bytes,5,opt,name=uid,proto3" json:"uid,omitempty"`   // 自选UID，为空时由服务端分配
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RegisterReq) Reset() {
 	*x = RegisterReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[2]
+	mi := &file_core_protocol_message_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -228,7 +364,7 @@ func (x *RegisterReq) String() string {
 func (*RegisterReq) ProtoMessage() {}
 
 func (x *RegisterReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[2]
+	mi := &file_core_protocol_message_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -241,7 +377,7 @@ func (x *RegisterReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterReq.ProtoReflect.Descriptor instead.
 func (*RegisterReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{2}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *RegisterReq) GetUsername() string {
@@ -265,6 +401,20 @@ func (x *RegisterReq) GetEmail() string {
 	return ""
 }
 
+func (x *RegisterReq) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *RegisterReq) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
 type LoginReq struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Uid           string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
@@ -275,7 +425,7 @@ type LoginReq struct {
 
 func (x *LoginReq) Reset() {
 	*x = LoginReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[3]
+	mi := &file_core_protocol_message_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -287,7 +437,7 @@ func (x *LoginReq) String() string {
 func (*LoginReq) ProtoMessage() {}
 
 func (x *LoginReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[3]
+	mi := &file_core_protocol_message_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -300,7 +450,7 @@ func (x *LoginReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LoginReq.ProtoReflect.Descriptor instead.
 func (*LoginReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{3}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *LoginReq) GetUid() string {
@@ -317,6 +467,119 @@ func (x *LoginReq) GetPassword() string {
 	return ""
 }
 
+// 登录和刷新令牌的响应，放在 APIResp.data 中
+type LoginResp struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AccessToken   string                 `protobuf:"bytes,1,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`    // 短期访问令牌
+	RefreshToken  string                 `protobuf:"bytes,2,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"` // 刷新令牌，每次使用后轮换
+	ExpiresIn     int64                  `protobuf:"varint,3,opt,name=expires_in,json=expiresIn,proto3" json:"expires_in,omitempty"`         // 访问令牌有效期（秒）
+	Challenge     string                 `protobuf:"bytes,4,opt,name=challenge,proto3" json:"challenge,omitempty"`                           // 需要两步验证时返回，此时不签发令牌
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginResp) Reset() {
+	*x = LoginResp{}
+	mi := &file_core_protocol_message_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginResp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginResp) ProtoMessage() {}
+
+func (x *LoginResp) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginResp.ProtoReflect.Descriptor instead.
+func (*LoginResp) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *LoginResp) GetAccessToken() string {
+	if x != nil {
+		return x.AccessToken
+	}
+	return ""
+}
+
+func (x *LoginResp) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+func (x *LoginResp) GetExpiresIn() int64 {
+	if x != nil {
+		return x.ExpiresIn
+	}
+	return 0
+}
+
+func (x *LoginResp) GetChallenge() string {
+	if x != nil {
+		return x.Challenge
+	}
+	return ""
+}
+
+type RefreshTokenReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RefreshToken  string                 `protobuf:"bytes,1,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshTokenReq) Reset() {
+	*x = RefreshTokenReq{}
+	mi := &file_core_protocol_message_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshTokenReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshTokenReq) ProtoMessage() {}
+
+func (x *RefreshTokenReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshTokenReq.ProtoReflect.Descriptor instead.
+func (*RefreshTokenReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RefreshTokenReq) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
 type ResetPwdReq struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Email         string                 `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
@@ -328,7 +591,7 @@ type ResetPwdReq struct {
 
 func (x *ResetPwdReq) Reset() {
 	*x = ResetPwdReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[4]
+	mi := &file_core_protocol_message_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -340,7 +603,7 @@ func (x *ResetPwdReq) String() string {
 func (*ResetPwdReq) ProtoMessage() {}
 
 func (x *ResetPwdReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[4]
+	mi := &file_core_protocol_message_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -353,7 +616,7 @@ func (x *ResetPwdReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResetPwdReq.ProtoReflect.Descriptor instead.
 func (*ResetPwdReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{4}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *ResetPwdReq) GetEmail() string {
@@ -387,7 +650,7 @@ type UpdateUsernameReq struct {
 
 func (x *UpdateUsernameReq) Reset() {
 	*x = UpdateUsernameReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[5]
+	mi := &file_core_protocol_message_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -399,7 +662,7 @@ func (x *UpdateUsernameReq) String() string {
 func (*UpdateUsernameReq) ProtoMessage() {}
 
 func (x *UpdateUsernameReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[5]
+	mi := &file_core_protocol_message_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -412,7 +675,7 @@ func (x *UpdateUsernameReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateUsernameReq.ProtoReflect.Descriptor instead.
 func (*UpdateUsernameReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{5}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *UpdateUsernameReq) GetUid() string {
@@ -440,7 +703,7 @@ type UpdatePwdReq struct {
 
 func (x *UpdatePwdReq) Reset() {
 	*x = UpdatePwdReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[6]
+	mi := &file_core_protocol_message_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -452,7 +715,7 @@ func (x *UpdatePwdReq) String() string {
 func (*UpdatePwdReq) ProtoMessage() {}
 
 func (x *UpdatePwdReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[6]
+	mi := &file_core_protocol_message_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -465,7 +728,7 @@ func (x *UpdatePwdReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdatePwdReq.ProtoReflect.Descriptor instead.
 func (*UpdatePwdReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{6}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *UpdatePwdReq) GetUid() string {
@@ -498,7 +761,7 @@ type TokenCheckReq struct {
 
 func (x *TokenCheckReq) Reset() {
 	*x = TokenCheckReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[7]
+	mi := &file_core_protocol_message_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -510,7 +773,7 @@ func (x *TokenCheckReq) String() string {
 func (*TokenCheckReq) ProtoMessage() {}
 
 func (x *TokenCheckReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[7]
+	mi := &file_core_protocol_message_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -523,7 +786,7 @@ func (x *TokenCheckReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TokenCheckReq.ProtoReflect.Descriptor instead.
 func (*TokenCheckReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{7}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *TokenCheckReq) GetToken() string {
@@ -542,7 +805,7 @@ type DeleteAccountReq struct {
 
 func (x *DeleteAccountReq) Reset() {
 	*x = DeleteAccountReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[8]
+	mi := &file_core_protocol_message_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -554,7 +817,7 @@ func (x *DeleteAccountReq) String() string {
 func (*DeleteAccountReq) ProtoMessage() {}
 
 func (x *DeleteAccountReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[8]
+	mi := &file_core_protocol_message_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -567,7 +830,7 @@ func (x *DeleteAccountReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteAccountReq.ProtoReflect.Descriptor instead.
 func (*DeleteAccountReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{8}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *DeleteAccountReq) GetUid() string {
@@ -586,7 +849,7 @@ type UserInfoReq struct {
 
 func (x *UserInfoReq) Reset() {
 	*x = UserInfoReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[9]
+	mi := &file_core_protocol_message_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -598,7 +861,7 @@ func (x *UserInfoReq) String() string {
 func (*UserInfoReq) ProtoMessage() {}
 
 func (x *UserInfoReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[9]
+	mi := &file_core_protocol_message_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -611,7 +874,7 @@ func (x *UserInfoReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UserInfoReq.ProtoReflect.Descriptor instead.
 func (*UserInfoReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{9}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *UserInfoReq) GetToken() string {
@@ -630,7 +893,7 @@ type LogoutReq struct {
 
 func (x *LogoutReq) Reset() {
 	*x = LogoutReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[10]
+	mi := &file_core_protocol_message_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -642,7 +905,7 @@ func (x *LogoutReq) String() string {
 func (*LogoutReq) ProtoMessage() {}
 
 func (x *LogoutReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[10]
+	mi := &file_core_protocol_message_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -655,7 +918,7 @@ func (x *LogoutReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LogoutReq.ProtoReflect.Descriptor instead.
 func (*LogoutReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{10}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *LogoutReq) GetToken() string {
@@ -668,13 +931,16 @@ func (x *LogoutReq) GetToken() string {
 type SendEmailCodeReq struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Email         string                 `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Purpose       string                 `protobuf:"bytes,2,opt,name=purpose,proto3" json:"purpose,omitempty"` // register、reset_pwd 或 change_email
+	Uid           string                 `protobuf:"bytes,3,opt,name=uid,proto3" json:"uid,omitempty"`         // change_email 时必填
+	Token         string                 `protobuf:"bytes,4,opt,name=token,proto3" json:"token,omitempty"`     // change_email 时必填
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *SendEmailCodeReq) Reset() {
 	*x = SendEmailCodeReq{}
-	mi := &file_core_protocol_message_proto_msgTypes[11]
+	mi := &file_core_protocol_message_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -686,7 +952,7 @@ func (x *SendEmailCodeReq) String() string {
 func (*SendEmailCodeReq) ProtoMessage() {}
 
 func (x *SendEmailCodeReq) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[11]
+	mi := &file_core_protocol_message_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -699,7 +965,7 @@ func (x *SendEmailCodeReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SendEmailCodeReq.ProtoReflect.Descriptor instead.
 func (*SendEmailCodeReq) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{11}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *SendEmailCodeReq) GetEmail() string {
@@ -709,22 +975,112 @@ func (x *SendEmailCodeReq) GetEmail() string {
 	return ""
 }
 
-// 通知消息
-type Notification struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`            // 通知类型，如 friend_request
-	From          string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`            // 触发方UID
-	To            string                 `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`                // 接收方UID
-	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`      // 通知内容（如验证消息）
-	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // 通知时间
-	Extra         string                 `protobuf:"bytes,6,opt,name=extra,proto3" json:"extra,omitempty"`          // 扩展字段
-	unknownFields protoimpl.UnknownFields
+func (x *SendEmailCodeReq) GetPurpose() string {
+	if x != nil {
+		return x.Purpose
+	}
+	return ""
+}
+
+func (x *SendEmailCodeReq) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *SendEmailCodeReq) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+// 修改绑定邮箱，验证码发送到新邮箱
+type UpdateEmailReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uid           string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	NewEmail      string                 `protobuf:"bytes,3,opt,name=new_email,json=newEmail,proto3" json:"new_email,omitempty"`
+	Code          string                 `protobuf:"bytes,4,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateEmailReq) Reset() {
+	*x = UpdateEmailReq{}
+	mi := &file_core_protocol_message_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateEmailReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateEmailReq) ProtoMessage() {}
+
+func (x *UpdateEmailReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateEmailReq.ProtoReflect.Descriptor instead.
+func (*UpdateEmailReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UpdateEmailReq) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *UpdateEmailReq) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *UpdateEmailReq) GetNewEmail() string {
+	if x != nil {
+		return x.NewEmail
+	}
+	return ""
+}
+
+func (x *UpdateEmailReq) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+// 通知消息
+type Notification struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`            // 通知类型，如 friend_request
+	From          string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`            // 触发方UID
+	To            string                 `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`                // 接收方UID
+	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`      // 通知内容（如验证消息）
+	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // 通知时间
+	Extra         string                 `protobuf:"bytes,6,opt,name=extra,proto3" json:"extra,omitempty"`          // 扩展字段
+	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Notification) Reset() {
 	*x = Notification{}
-	mi := &file_core_protocol_message_proto_msgTypes[12]
+	mi := &file_core_protocol_message_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -736,7 +1092,7 @@ func (x *Notification) String() string {
 func (*Notification) ProtoMessage() {}
 
 func (x *Notification) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[12]
+	mi := &file_core_protocol_message_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -749,7 +1105,7 @@ func (x *Notification) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Notification.ProtoReflect.Descriptor instead.
 func (*Notification) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{12}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *Notification) GetType() string {
@@ -808,7 +1164,7 @@ type FileInfo struct {
 
 func (x *FileInfo) Reset() {
 	*x = FileInfo{}
-	mi := &file_core_protocol_message_proto_msgTypes[13]
+	mi := &file_core_protocol_message_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -820,7 +1176,7 @@ func (x *FileInfo) String() string {
 func (*FileInfo) ProtoMessage() {}
 
 func (x *FileInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_core_protocol_message_proto_msgTypes[13]
+	mi := &file_core_protocol_message_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -833,7 +1189,7 @@ func (x *FileInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileInfo.ProtoReflect.Descriptor instead.
 func (*FileInfo) Descriptor() ([]byte, []int) {
-	return file_core_protocol_message_proto_rawDescGZIP(), []int{13}
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *FileInfo) GetFilename() string {
@@ -871,11 +1227,495 @@ func (x *FileInfo) GetUrl() string {
 	return ""
 }
 
+// 删除已上传的文件，需要 file.delete 权限
+type DeleteFileReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"` // 服务器文件名
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteFileReq) Reset() {
+	*x = DeleteFileReq{}
+	mi := &file_core_protocol_message_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteFileReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteFileReq) ProtoMessage() {}
+
+func (x *DeleteFileReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteFileReq.ProtoReflect.Descriptor instead.
+func (*DeleteFileReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DeleteFileReq) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+// 获取单聊或群聊记录（游标分页，默认返回最新一页）
+type ChatHistoryReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uid           string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	FriendUid     string                 `protobuf:"bytes,2,opt,name=friend_uid,json=friendUid,proto3" json:"friend_uid,omitempty"`
+	Token         string                 `protobuf:"bytes,3,opt,name=token,proto3" json:"token,omitempty"`
+	BeforeId      int64                  `protobuf:"varint,4,opt,name=before_id,json=beforeId,proto3" json:"before_id,omitempty"`       // 只返回消息ID小于该值的消息（向前翻页）
+	AfterId       int64                  `protobuf:"varint,5,opt,name=after_id,json=afterId,proto3" json:"after_id,omitempty"`          // 只返回消息ID大于该值的消息（向后翻页）
+	BeforeTime    int64                  `protobuf:"varint,6,opt,name=before_time,json=beforeTime,proto3" json:"before_time,omitempty"` // 只返回时间戳早于该值的消息
+	AfterTime     int64                  `protobuf:"varint,7,opt,name=after_time,json=afterTime,proto3" json:"after_time,omitempty"`    // 只返回时间戳晚于该值的消息
+	Limit         int32                  `protobuf:"varint,8,opt,name=limit,proto3" json:"limit,omitempty"`                             // 每页条数，默认20，最多100
+	GroupId       int64                  `protobuf:"varint,9,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`          // 不为0时查询群聊记录，忽略 friend_uid
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatHistoryReq) Reset() {
+	*x = ChatHistoryReq{}
+	mi := &file_core_protocol_message_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatHistoryReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatHistoryReq) ProtoMessage() {}
+
+func (x *ChatHistoryReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatHistoryReq.ProtoReflect.Descriptor instead.
+func (*ChatHistoryReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ChatHistoryReq) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *ChatHistoryReq) GetFriendUid() string {
+	if x != nil {
+		return x.FriendUid
+	}
+	return ""
+}
+
+func (x *ChatHistoryReq) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *ChatHistoryReq) GetBeforeId() int64 {
+	if x != nil {
+		return x.BeforeId
+	}
+	return 0
+}
+
+func (x *ChatHistoryReq) GetAfterId() int64 {
+	if x != nil {
+		return x.AfterId
+	}
+	return 0
+}
+
+func (x *ChatHistoryReq) GetBeforeTime() int64 {
+	if x != nil {
+		return x.BeforeTime
+	}
+	return 0
+}
+
+func (x *ChatHistoryReq) GetAfterTime() int64 {
+	if x != nil {
+		return x.AfterTime
+	}
+	return 0
+}
+
+func (x *ChatHistoryReq) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ChatHistoryReq) GetGroupId() int64 {
+	if x != nil {
+		return x.GroupId
+	}
+	return 0
+}
+
+type ChatHistoryResp struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*IMMessage           `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`               // 按消息ID升序
+	HasMore       bool                   `protobuf:"varint,2,opt,name=has_more,json=hasMore,proto3" json:"has_more,omitempty"` // 翻页方向上是否还有更多消息
+	Code          int32                  `protobuf:"varint,3,opt,name=code,proto3" json:"code,omitempty"`
+	Msg           string                 `protobuf:"bytes,4,opt,name=msg,proto3" json:"msg,omitempty"`
+	PeerReadId    int64                  `protobuf:"varint,5,opt,name=peer_read_id,json=peerReadId,proto3" json:"peer_read_id,omitempty"` // 单聊时对方已读到的消息ID
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatHistoryResp) Reset() {
+	*x = ChatHistoryResp{}
+	mi := &file_core_protocol_message_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatHistoryResp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatHistoryResp) ProtoMessage() {}
+
+func (x *ChatHistoryResp) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatHistoryResp.ProtoReflect.Descriptor instead.
+func (*ChatHistoryResp) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ChatHistoryResp) GetMessages() []*IMMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *ChatHistoryResp) GetHasMore() bool {
+	if x != nil {
+		return x.HasMore
+	}
+	return false
+}
+
+func (x *ChatHistoryResp) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *ChatHistoryResp) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *ChatHistoryResp) GetPeerReadId() int64 {
+	if x != nil {
+		return x.PeerReadId
+	}
+	return 0
+}
+
+// 搜索消息，只搜索调用者可见的会话
+type SearchMessagesReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uid           string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	Keyword       string                 `protobuf:"bytes,3,opt,name=keyword,proto3" json:"keyword,omitempty"`
+	SenderUid     string                 `protobuf:"bytes,4,opt,name=sender_uid,json=senderUid,proto3" json:"sender_uid,omitempty"`  // 按发送者过滤
+	FriendUid     string                 `protobuf:"bytes,5,opt,name=friend_uid,json=friendUid,proto3" json:"friend_uid,omitempty"`  // 限定与该好友的单聊
+	GroupId       int64                  `protobuf:"varint,6,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`       // 限定该群
+	StartTime     int64                  `protobuf:"varint,7,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"` // 时间范围下限（Unix秒，含）
+	EndTime       int64                  `protobuf:"varint,8,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`       // 时间范围上限（Unix秒，不含）
+	Types         []string               `protobuf:"bytes,9,rep,name=types,proto3" json:"types,omitempty"`                           // 消息类型: chat, image, file，为空时不限
+	Page          int32                  `protobuf:"varint,10,opt,name=page,proto3" json:"page,omitempty"`                           // 页码，从1开始
+	PageSize      int32                  `protobuf:"varint,11,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchMessagesReq) Reset() {
+	*x = SearchMessagesReq{}
+	mi := &file_core_protocol_message_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchMessagesReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchMessagesReq) ProtoMessage() {}
+
+func (x *SearchMessagesReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchMessagesReq.ProtoReflect.Descriptor instead.
+func (*SearchMessagesReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *SearchMessagesReq) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *SearchMessagesReq) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *SearchMessagesReq) GetKeyword() string {
+	if x != nil {
+		return x.Keyword
+	}
+	return ""
+}
+
+func (x *SearchMessagesReq) GetSenderUid() string {
+	if x != nil {
+		return x.SenderUid
+	}
+	return ""
+}
+
+func (x *SearchMessagesReq) GetFriendUid() string {
+	if x != nil {
+		return x.FriendUid
+	}
+	return ""
+}
+
+func (x *SearchMessagesReq) GetGroupId() int64 {
+	if x != nil {
+		return x.GroupId
+	}
+	return 0
+}
+
+func (x *SearchMessagesReq) GetStartTime() int64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *SearchMessagesReq) GetEndTime() int64 {
+	if x != nil {
+		return x.EndTime
+	}
+	return 0
+}
+
+func (x *SearchMessagesReq) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *SearchMessagesReq) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *SearchMessagesReq) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+// 搜索结果
+type SearchResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       *IMMessage             `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	Snippet       string                 `protobuf:"bytes,2,opt,name=snippet,proto3" json:"snippet,omitempty"` // 关键词所在片段，关键词用 <em></em> 标记
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResult) Reset() {
+	*x = SearchResult{}
+	mi := &file_core_protocol_message_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResult) ProtoMessage() {}
+
+func (x *SearchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResult.ProtoReflect.Descriptor instead.
+func (*SearchResult) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *SearchResult) GetMessage() *IMMessage {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *SearchResult) GetSnippet() string {
+	if x != nil {
+		return x.Snippet
+	}
+	return ""
+}
+
+type SearchMessagesResp struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*SearchResult        `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"` // 按消息ID倒序
+	Total         int32                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	HasMore       bool                   `protobuf:"varint,3,opt,name=has_more,json=hasMore,proto3" json:"has_more,omitempty"`
+	Code          int32                  `protobuf:"varint,4,opt,name=code,proto3" json:"code,omitempty"`
+	Msg           string                 `protobuf:"bytes,5,opt,name=msg,proto3" json:"msg,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchMessagesResp) Reset() {
+	*x = SearchMessagesResp{}
+	mi := &file_core_protocol_message_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchMessagesResp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchMessagesResp) ProtoMessage() {}
+
+func (x *SearchMessagesResp) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_message_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchMessagesResp.ProtoReflect.Descriptor instead.
+func (*SearchMessagesResp) Descriptor() ([]byte, []int) {
+	return file_core_protocol_message_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *SearchMessagesResp) GetResults() []*SearchResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *SearchMessagesResp) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *SearchMessagesResp) GetHasMore() bool {
+	if x != nil {
+		return x.HasMore
+	}
+	return false
+}
+
+func (x *SearchMessagesResp) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *SearchMessagesResp) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
 var File_core_protocol_message_proto protoreflect.FileDescriptor
 
 const file_core_protocol_message_proto_rawDesc = "" +
 	"\n" +
-	"\x1bcore/protocol/message.proto\x12\bprotocol\"\x90\x02\n" +
+	"\x1bcore/protocol/message.proto\x12\bprotocol\"\xe9\x04\n" +
 	"\tIMMessage\x12\x12\n" +
 	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
 	"\x04from\x18\x02 \x01(\tR\x04from\x12\x0e\n" +
@@ -888,18 +1728,41 @@ const file_core_protocol_message_proto_rawDesc = "" +
 	"\bfilename\x18\t \x01(\tR\bfilename\x12\x1a\n" +
 	"\bfilesize\x18\n" +
 	" \x01(\x03R\bfilesize\x12\x1b\n" +
-	"\tmime_type\x18\v \x01(\tR\bmimeType\"C\n" +
+	"\tmime_type\x18\v \x01(\tR\bmimeType\x12\x15\n" +
+	"\x06msg_id\x18\f \x01(\x03R\x05msgId\x12\x19\n" +
+	"\bgroup_id\x18\r \x01(\x03R\agroupId\x12\x1b\n" +
+	"\tdevice_id\x18\x0e \x01(\tR\bdeviceId\x12\x1a\n" +
+	"\brecalled\x18\x0f \x01(\bR\brecalled\x12\x1b\n" +
+	"\tedited_at\x18\x10 \x01(\x03R\beditedAt\x12\x1e\n" +
+	"\vreply_to_id\x18\x11 \x01(\x03R\treplyToId\x12&\n" +
+	"\x0fforward_from_id\x18\x12 \x01(\x03R\rforwardFromId\x12+\n" +
+	"\x06quoted\x18\x13 \x01(\v2\x13.protocol.IMMessageR\x06quoted\x12(\n" +
+	"\x10forward_from_uid\x18\x14 \x01(\tR\x0eforwardFromUid\x120\n" +
+	"\treactions\x18\x15 \x03(\v2\x12.protocol.ReactionR\treactions\";\n" +
+	"\bReaction\x12\x14\n" +
+	"\x05emoji\x18\x01 \x01(\tR\x05emoji\x12\x19\n" +
+	"\buser_ids\x18\x02 \x03(\tR\auserIds\"C\n" +
 	"\aAPIResp\x12\x12\n" +
 	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x10\n" +
 	"\x03msg\x18\x02 \x01(\tR\x03msg\x12\x12\n" +
-	"\x04data\x18\x03 \x01(\fR\x04data\"[\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"\x81\x01\n" +
 	"\vRegisterReq\x12\x1a\n" +
 	"\busername\x18\x01 \x01(\tR\busername\x12\x1a\n" +
 	"\bpassword\x18\x02 \x01(\tR\bpassword\x12\x14\n" +
-	"\x05email\x18\x03 \x01(\tR\x05email\"8\n" +
+	"\x05email\x18\x03 \x01(\tR\x05email\x12\x12\n" +
+	"\x04code\x18\x04 \x01(\tR\x04code\x12\x10\n" +
+	"\x03uid\x18\x05 \x01(\tR\x03uid\"8\n" +
 	"\bLoginReq\x12\x10\n" +
 	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x1a\n" +
-	"\bpassword\x18\x02 \x01(\tR\bpassword\"P\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"\x90\x01\n" +
+	"\tLoginResp\x12!\n" +
+	"\faccess_token\x18\x01 \x01(\tR\vaccessToken\x12#\n" +
+	"\rrefresh_token\x18\x02 \x01(\tR\frefreshToken\x12\x1d\n" +
+	"\n" +
+	"expires_in\x18\x03 \x01(\x03R\texpiresIn\x12\x1c\n" +
+	"\tchallenge\x18\x04 \x01(\tR\tchallenge\"6\n" +
+	"\x0fRefreshTokenReq\x12#\n" +
+	"\rrefresh_token\x18\x01 \x01(\tR\frefreshToken\"P\n" +
 	"\vResetPwdReq\x12\x14\n" +
 	"\x05email\x18\x01 \x01(\tR\x05email\x12\x17\n" +
 	"\anew_pwd\x18\x02 \x01(\tR\x06newPwd\x12\x12\n" +
@@ -918,9 +1781,17 @@ const file_core_protocol_message_proto_rawDesc = "" +
 	"\vUserInfoReq\x12\x14\n" +
 	"\x05token\x18\x01 \x01(\tR\x05token\"!\n" +
 	"\tLogoutReq\x12\x14\n" +
-	"\x05token\x18\x01 \x01(\tR\x05token\"(\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\"j\n" +
 	"\x10SendEmailCodeReq\x12\x14\n" +
-	"\x05email\x18\x01 \x01(\tR\x05email\"\x94\x01\n" +
+	"\x05email\x18\x01 \x01(\tR\x05email\x12\x18\n" +
+	"\apurpose\x18\x02 \x01(\tR\apurpose\x12\x10\n" +
+	"\x03uid\x18\x03 \x01(\tR\x03uid\x12\x14\n" +
+	"\x05token\x18\x04 \x01(\tR\x05token\"i\n" +
+	"\x0eUpdateEmailReq\x12\x10\n" +
+	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12\x1b\n" +
+	"\tnew_email\x18\x03 \x01(\tR\bnewEmail\x12\x12\n" +
+	"\x04code\x18\x04 \x01(\tR\x04code\"\x94\x01\n" +
 	"\fNotification\x12\x12\n" +
 	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
 	"\x04from\x18\x02 \x01(\tR\x04from\x12\x0e\n" +
@@ -933,7 +1804,54 @@ const file_core_protocol_message_proto_rawDesc = "" +
 	"\roriginal_name\x18\x02 \x01(\tR\foriginalName\x12\x12\n" +
 	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x12\n" +
 	"\x04type\x18\x04 \x01(\tR\x04type\x12\x10\n" +
-	"\x03url\x18\x05 \x01(\tR\x03urlB\x18Z\x16im/core/protocol/pb;pbb\x06proto3"
+	"\x03url\x18\x05 \x01(\tR\x03url\"+\n" +
+	"\rDeleteFileReq\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"\x80\x02\n" +
+	"\x0eChatHistoryReq\x12\x10\n" +
+	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x1d\n" +
+	"\n" +
+	"friend_uid\x18\x02 \x01(\tR\tfriendUid\x12\x14\n" +
+	"\x05token\x18\x03 \x01(\tR\x05token\x12\x1b\n" +
+	"\tbefore_id\x18\x04 \x01(\x03R\bbeforeId\x12\x19\n" +
+	"\bafter_id\x18\x05 \x01(\x03R\aafterId\x12\x1f\n" +
+	"\vbefore_time\x18\x06 \x01(\x03R\n" +
+	"beforeTime\x12\x1d\n" +
+	"\n" +
+	"after_time\x18\a \x01(\x03R\tafterTime\x12\x14\n" +
+	"\x05limit\x18\b \x01(\x05R\x05limit\x12\x19\n" +
+	"\bgroup_id\x18\t \x01(\x03R\agroupId\"\xa5\x01\n" +
+	"\x0fChatHistoryResp\x12/\n" +
+	"\bmessages\x18\x01 \x03(\v2\x13.protocol.IMMessageR\bmessages\x12\x19\n" +
+	"\bhas_more\x18\x02 \x01(\bR\ahasMore\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\x05R\x04code\x12\x10\n" +
+	"\x03msg\x18\x04 \x01(\tR\x03msg\x12 \n" +
+	"\fpeer_read_id\x18\x05 \x01(\x03R\n" +
+	"peerReadId\"\xaf\x02\n" +
+	"\x11SearchMessagesReq\x12\x10\n" +
+	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12\x18\n" +
+	"\akeyword\x18\x03 \x01(\tR\akeyword\x12\x1d\n" +
+	"\n" +
+	"sender_uid\x18\x04 \x01(\tR\tsenderUid\x12\x1d\n" +
+	"\n" +
+	"friend_uid\x18\x05 \x01(\tR\tfriendUid\x12\x19\n" +
+	"\bgroup_id\x18\x06 \x01(\x03R\agroupId\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\a \x01(\x03R\tstartTime\x12\x19\n" +
+	"\bend_time\x18\b \x01(\x03R\aendTime\x12\x14\n" +
+	"\x05types\x18\t \x03(\tR\x05types\x12\x12\n" +
+	"\x04page\x18\n" +
+	" \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\v \x01(\x05R\bpageSize\"W\n" +
+	"\fSearchResult\x12-\n" +
+	"\amessage\x18\x01 \x01(\v2\x13.protocol.IMMessageR\amessage\x12\x18\n" +
+	"\asnippet\x18\x02 \x01(\tR\asnippet\"\x9d\x01\n" +
+	"\x12SearchMessagesResp\x120\n" +
+	"\aresults\x18\x01 \x03(\v2\x16.protocol.SearchResultR\aresults\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x12\x19\n" +
+	"\bhas_more\x18\x03 \x01(\bR\ahasMore\x12\x12\n" +
+	"\x04code\x18\x04 \x01(\x05R\x04code\x12\x10\n" +
+	"\x03msg\x18\x05 \x01(\tR\x03msgB\x18Z\x16im/core/protocol/pb;pbb\x06proto3"
 
 var (
 	file_core_protocol_message_proto_rawDescOnce sync.Once
@@ -947,29 +1865,44 @@ func file_core_protocol_message_proto_rawDescGZIP() []byte {
 	return file_core_protocol_message_proto_rawDescData
 }
 
-var file_core_protocol_message_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_core_protocol_message_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
 var file_core_protocol_message_proto_goTypes = []any{
-	(*IMMessage)(nil),         // 0: protocol.IMMessage
-	(*APIResp)(nil),           // 1: protocol.APIResp
-	(*RegisterReq)(nil),       // 2: protocol.RegisterReq
-	(*LoginReq)(nil),          // 3: protocol.LoginReq
-	(*ResetPwdReq)(nil),       // 4: protocol.ResetPwdReq
-	(*UpdateUsernameReq)(nil), // 5: protocol.UpdateUsernameReq
-	(*UpdatePwdReq)(nil),      // 6: protocol.UpdatePwdReq
-	(*TokenCheckReq)(nil),     // 7: protocol.TokenCheckReq
-	(*DeleteAccountReq)(nil),  // 8: protocol.DeleteAccountReq
-	(*UserInfoReq)(nil),       // 9: protocol.UserInfoReq
-	(*LogoutReq)(nil),         // 10: protocol.LogoutReq
-	(*SendEmailCodeReq)(nil),  // 11: protocol.SendEmailCodeReq
-	(*Notification)(nil),      // 12: protocol.Notification
-	(*FileInfo)(nil),          // 13: protocol.FileInfo
+	(*IMMessage)(nil),          // 0: protocol.IMMessage
+	(*Reaction)(nil),           // 1: protocol.Reaction
+	(*APIResp)(nil),            // 2: protocol.APIResp
+	(*RegisterReq)(nil),        // 3: protocol.RegisterReq
+	(*LoginReq)(nil),           // 4: protocol.LoginReq
+	(*LoginResp)(nil),          // 5: protocol.LoginResp
+	(*RefreshTokenReq)(nil),    // 6: protocol.RefreshTokenReq
+	(*ResetPwdReq)(nil),        // 7: protocol.ResetPwdReq
+	(*UpdateUsernameReq)(nil),  // 8: protocol.UpdateUsernameReq
+	(*UpdatePwdReq)(nil),       // 9: protocol.UpdatePwdReq
+	(*TokenCheckReq)(nil),      // 10: protocol.TokenCheckReq
+	(*DeleteAccountReq)(nil),   // 11: protocol.DeleteAccountReq
+	(*UserInfoReq)(nil),        // 12: protocol.UserInfoReq
+	(*LogoutReq)(nil),          // 13: protocol.LogoutReq
+	(*SendEmailCodeReq)(nil),   // 14: protocol.SendEmailCodeReq
+	(*UpdateEmailReq)(nil),     // 15: protocol.UpdateEmailReq
+	(*Notification)(nil),       // 16: protocol.Notification
+	(*FileInfo)(nil),           // 17: protocol.FileInfo
+	(*DeleteFileReq)(nil),      // 18: protocol.DeleteFileReq
+	(*ChatHistoryReq)(nil),     // 19: protocol.ChatHistoryReq
+	(*ChatHistoryResp)(nil),    // 20: protocol.ChatHistoryResp
+	(*SearchMessagesReq)(nil),  // 21: protocol.SearchMessagesReq
+	(*SearchResult)(nil),       // 22: protocol.SearchResult
+	(*SearchMessagesResp)(nil), // 23: protocol.SearchMessagesResp
 }
 var file_core_protocol_message_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0,  // 0: protocol.IMMessage.quoted:type_name -> protocol.IMMessage
+	1,  // 1: protocol.IMMessage.reactions:type_name -> protocol.Reaction
+	0,  // 2: protocol.ChatHistoryResp.messages:type_name -> protocol.IMMessage
+	0,  // 3: protocol.SearchResult.message:type_name -> protocol.IMMessage
+	22, // 4: protocol.SearchMessagesResp.results:type_name -> protocol.SearchResult
+	5,  // [5:5] is the sub-list for method output_type
+	5,  // [5:5] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_core_protocol_message_proto_init() }
@@ -983,7 +1916,7 @@ func file_core_protocol_message_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_core_protocol_message_proto_rawDesc), len(file_core_protocol_message_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   14,
+			NumMessages:   24,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -98,6 +98,391 @@ func (x *UserInfoResp) GetMsg() string {
 	return ""
 }
 
+// 登录第二步，code 为验证器生成的验证码或恢复码
+type Login2FAReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Challenge     string                 `protobuf:"bytes,1,opt,name=challenge,proto3" json:"challenge,omitempty"`
+	Code          string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Login2FAReq) Reset() {
+	*x = Login2FAReq{}
+	mi := &file_core_protocol_user_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Login2FAReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Login2FAReq) ProtoMessage() {}
+
+func (x *Login2FAReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_user_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Login2FAReq.ProtoReflect.Descriptor instead.
+func (*Login2FAReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_user_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Login2FAReq) GetChallenge() string {
+	if x != nil {
+		return x.Challenge
+	}
+	return ""
+}
+
+func (x *Login2FAReq) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+// 生成两步验证密钥
+type TOTPSetupReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uid           string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TOTPSetupReq) Reset() {
+	*x = TOTPSetupReq{}
+	mi := &file_core_protocol_user_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TOTPSetupReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TOTPSetupReq) ProtoMessage() {}
+
+func (x *TOTPSetupReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_user_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TOTPSetupReq.ProtoReflect.Descriptor instead.
+func (*TOTPSetupReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_user_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TOTPSetupReq) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *TOTPSetupReq) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+type TOTPSetupResp struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Secret        string                 `protobuf:"bytes,1,opt,name=secret,proto3" json:"secret,omitempty"`                           // Base32密钥，用于手动输入
+	OtpauthUri    string                 `protobuf:"bytes,2,opt,name=otpauth_uri,json=otpauthUri,proto3" json:"otpauth_uri,omitempty"` // 供验证器应用扫码添加
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TOTPSetupResp) Reset() {
+	*x = TOTPSetupResp{}
+	mi := &file_core_protocol_user_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TOTPSetupResp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TOTPSetupResp) ProtoMessage() {}
+
+func (x *TOTPSetupResp) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_user_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TOTPSetupResp.ProtoReflect.Descriptor instead.
+func (*TOTPSetupResp) Descriptor() ([]byte, []int) {
+	return file_core_protocol_user_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TOTPSetupResp) GetSecret() string {
+	if x != nil {
+		return x.Secret
+	}
+	return ""
+}
+
+func (x *TOTPSetupResp) GetOtpauthUri() string {
+	if x != nil {
+		return x.OtpauthUri
+	}
+	return ""
+}
+
+// 使用验证器生成的第一个验证码确认开启
+type TOTPEnableReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uid           string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	Code          string                 `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TOTPEnableReq) Reset() {
+	*x = TOTPEnableReq{}
+	mi := &file_core_protocol_user_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TOTPEnableReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TOTPEnableReq) ProtoMessage() {}
+
+func (x *TOTPEnableReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_user_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TOTPEnableReq.ProtoReflect.Descriptor instead.
+func (*TOTPEnableReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_user_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *TOTPEnableReq) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *TOTPEnableReq) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *TOTPEnableReq) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type TOTPEnableResp struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RecoveryCodes []string               `protobuf:"bytes,1,rep,name=recovery_codes,json=recoveryCodes,proto3" json:"recovery_codes,omitempty"` // 一次性恢复码，只在开启时返回一次
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TOTPEnableResp) Reset() {
+	*x = TOTPEnableResp{}
+	mi := &file_core_protocol_user_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TOTPEnableResp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TOTPEnableResp) ProtoMessage() {}
+
+func (x *TOTPEnableResp) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_user_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TOTPEnableResp.ProtoReflect.Descriptor instead.
+func (*TOTPEnableResp) Descriptor() ([]byte, []int) {
+	return file_core_protocol_user_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TOTPEnableResp) GetRecoveryCodes() []string {
+	if x != nil {
+		return x.RecoveryCodes
+	}
+	return nil
+}
+
+// 关闭两步验证，code 为验证码或恢复码
+type TOTPDisableReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uid           string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	Code          string                 `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TOTPDisableReq) Reset() {
+	*x = TOTPDisableReq{}
+	mi := &file_core_protocol_user_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TOTPDisableReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TOTPDisableReq) ProtoMessage() {}
+
+func (x *TOTPDisableReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_user_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TOTPDisableReq.ProtoReflect.Descriptor instead.
+func (*TOTPDisableReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_user_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *TOTPDisableReq) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *TOTPDisableReq) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *TOTPDisableReq) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+// 授予或收回系统角色（moderator、admin），需要 role.assign 权限
+type SetRoleReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TargetUid     string                 `protobuf:"bytes,1,opt,name=target_uid,json=targetUid,proto3" json:"target_uid,omitempty"`
+	Role          string                 `protobuf:"bytes,2,opt,name=role,proto3" json:"role,omitempty"`
+	Grant         bool                   `protobuf:"varint,3,opt,name=grant,proto3" json:"grant,omitempty"` // true 授予，false 收回
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetRoleReq) Reset() {
+	*x = SetRoleReq{}
+	mi := &file_core_protocol_user_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetRoleReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetRoleReq) ProtoMessage() {}
+
+func (x *SetRoleReq) ProtoReflect() protoreflect.Message {
+	mi := &file_core_protocol_user_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetRoleReq.ProtoReflect.Descriptor instead.
+func (*SetRoleReq) Descriptor() ([]byte, []int) {
+	return file_core_protocol_user_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SetRoleReq) GetTargetUid() string {
+	if x != nil {
+		return x.TargetUid
+	}
+	return ""
+}
+
+func (x *SetRoleReq) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *SetRoleReq) GetGrant() bool {
+	if x != nil {
+		return x.Grant
+	}
+	return false
+}
+
 var File_core_protocol_user_proto protoreflect.FileDescriptor
 
 const file_core_protocol_user_proto_rawDesc = "" +
@@ -108,7 +493,33 @@ const file_core_protocol_user_proto_rawDesc = "" +
 	"\busername\x18\x02 \x01(\tR\busername\x12\x14\n" +
 	"\x05email\x18\x03 \x01(\tR\x05email\x12\x12\n" +
 	"\x04code\x18\x04 \x01(\x05R\x04code\x12\x10\n" +
-	"\x03msg\x18\x05 \x01(\tR\x03msgB\x18Z\x16im/core/protocol/pb;pbb\x06proto3"
+	"\x03msg\x18\x05 \x01(\tR\x03msg\"?\n" +
+	"\vLogin2FAReq\x12\x1c\n" +
+	"\tchallenge\x18\x01 \x01(\tR\tchallenge\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\"6\n" +
+	"\fTOTPSetupReq\x12\x10\n" +
+	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\"H\n" +
+	"\rTOTPSetupResp\x12\x16\n" +
+	"\x06secret\x18\x01 \x01(\tR\x06secret\x12\x1f\n" +
+	"\votpauth_uri\x18\x02 \x01(\tR\n" +
+	"otpauthUri\"K\n" +
+	"\rTOTPEnableReq\x12\x10\n" +
+	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\"7\n" +
+	"\x0eTOTPEnableResp\x12%\n" +
+	"\x0erecovery_codes\x18\x01 \x03(\tR\rrecoveryCodes\"L\n" +
+	"\x0eTOTPDisableReq\x12\x10\n" +
+	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\"U\n" +
+	"\n" +
+	"SetRoleReq\x12\x1d\n" +
+	"\n" +
+	"target_uid\x18\x01 \x01(\tR\ttargetUid\x12\x12\n" +
+	"\x04role\x18\x02 \x01(\tR\x04role\x12\x14\n" +
+	"\x05grant\x18\x03 \x01(\bR\x05grantB\x18Z\x16im/core/protocol/pb;pbb\x06proto3"
 
 var (
 	file_core_protocol_user_proto_rawDescOnce sync.Once
@@ -122,9 +533,16 @@ func file_core_protocol_user_proto_rawDescGZIP() []byte {
 	return file_core_protocol_user_proto_rawDescData
 }
 
-var file_core_protocol_user_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_core_protocol_user_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_core_protocol_user_proto_goTypes = []any{
-	(*UserInfoResp)(nil), // 0: protocol.UserInfoResp
+	(*UserInfoResp)(nil),   // 0: protocol.UserInfoResp
+	(*Login2FAReq)(nil),    // 1: protocol.Login2FAReq
+	(*TOTPSetupReq)(nil),   // 2: protocol.TOTPSetupReq
+	(*TOTPSetupResp)(nil),  // 3: protocol.TOTPSetupResp
+	(*TOTPEnableReq)(nil),  // 4: protocol.TOTPEnableReq
+	(*TOTPEnableResp)(nil), // 5: protocol.TOTPEnableResp
+	(*TOTPDisableReq)(nil), // 6: protocol.TOTPDisableReq
+	(*SetRoleReq)(nil),     // 7: protocol.SetRoleReq
 }
 var file_core_protocol_user_proto_depIdxs = []int32{
 	0, // [0:0] is the sub-list for method output_type
@@ -145,7 +563,7 @@ func file_core_protocol_user_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_core_protocol_user_proto_rawDesc), len(file_core_protocol_user_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   1,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
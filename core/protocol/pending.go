@@ -0,0 +1,123 @@
+package protocol
+
+import (
+	"im/core/storage"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	ackTimeout    = 10 * time.Second // 等待客户端确认的超时时间
+	maxRetries    = 5                // 最大重发次数，超过后等待用户重新上线时再推送
+	retryInterval = time.Second      // 扫描待确认队列的间隔
+)
+
+// 等待确认的消息
+type pendingMessage struct {
+	data    []byte
+	sentAt  time.Time
+	retries int
+}
+
+// 待确认消息队列: userID -> msgID -> 消息
+type pendingQueue struct {
+	mu    sync.Mutex
+	items map[string]map[int64]*pendingMessage
+}
+
+var pending = &pendingQueue{items: make(map[string]map[int64]*pendingMessage)}
+
+func (q *pendingQueue) add(userID string, msgID int64, data []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.items[userID] == nil {
+		q.items[userID] = make(map[int64]*pendingMessage)
+	}
+	q.items[userID][msgID] = &pendingMessage{data: data, sentAt: time.Now()}
+}
+
+func (q *pendingQueue) remove(userID string, msgID int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.items[userID], msgID)
+	if len(q.items[userID]) == 0 {
+		delete(q.items, userID)
+	}
+}
+
+// 替换所有用户待确认队列中该消息的内容
+func (q *pendingQueue) update(msgID int64, data []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, msgs := range q.items {
+		if p, ok := msgs[msgID]; ok {
+			p.data = data
+		}
+	}
+}
+
+// 取出已超时的消息，超过重发次数的直接丢弃（仍保留在离线消息表中）
+func (q *pendingQueue) expired(now time.Time) map[string]map[int64][]byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := make(map[string]map[int64][]byte)
+	for userID, msgs := range q.items {
+		for msgID, p := range msgs {
+			if now.Sub(p.sentAt) < ackTimeout {
+				continue
+			}
+			if p.retries >= maxRetries {
+				delete(msgs, msgID)
+				continue
+			}
+			p.retries++
+			p.sentAt = now
+			if result[userID] == nil {
+				result[userID] = make(map[int64][]byte)
+			}
+			result[userID][msgID] = p.data
+		}
+		if len(msgs) == 0 {
+			delete(q.items, userID)
+		}
+	}
+	return result
+}
+
+// 发送需要确认的消息，发送成功后加入待确认队列，超时未确认会重发
+func SendWithAck(userID string, msgID int64, data []byte) error {
+	if err := SendToUser(userID, data); err != nil {
+		return err
+	}
+	pending.add(userID, msgID, data)
+	return nil
+}
+
+// 处理客户端的送达确认
+func AckMessage(userID string, msgID int64) error {
+	pending.remove(userID, msgID)
+	return storage.GetStorageManager().RemoveOfflineMessage(userID, msgID)
+}
+
+// 定期重发超时未确认的消息
+func retryPendingLoop() {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for userID, msgs := range pending.expired(now) {
+			for msgID, data := range msgs {
+				if err := SendToUser(userID, data); err != nil {
+					// 用户已离线，等待重新登录时从离线消息表推送
+					pending.remove(userID, msgID)
+					log.Printf("重发消息 %d 给用户 %s 失败: %v", msgID, userID, err)
+				}
+			}
+		}
+	}
+}
+
+// 消息被撤回或编辑后更新待确认队列，重发时推送最新内容
+func UpdatePendingMessage(msgID int64, data []byte) {
+	pending.update(msgID, data)
+}
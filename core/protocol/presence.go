@@ -0,0 +1,108 @@
+package protocol
+
+import (
+	pb "im/core/protocol/pb"
+	"im/core/storage"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 在线状态
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// 用户在线状态，LastSeen 为最后在线时间（Unix秒），在线时为当前时间
+type Presence struct {
+	Status   string
+	LastSeen int64
+}
+
+// 在线状态表: 已广播给好友的状态，以及用户主动设置的离开状态
+var presences = struct {
+	sync.Mutex
+	status map[string]string
+	away   map[string]bool
+}{status: make(map[string]string), away: make(map[string]bool)}
+
+// 获取用户当前在线状态
+func GetPresence(userID string) *Presence {
+	if !IsOnline(userID) {
+		lastSeen, err := storage.GetStorageManager().GetLastSeen(userID)
+		if err != nil {
+			log.Printf("获取用户 %s 最后在线时间失败: %v", userID, err)
+		}
+		return &Presence{Status: PresenceOffline, LastSeen: lastSeen}
+	}
+	presences.Lock()
+	away := presences.away[userID]
+	presences.Unlock()
+	status := PresenceOnline
+	if away {
+		status = PresenceAway
+	}
+	return &Presence{Status: status, LastSeen: time.Now().Unix()}
+}
+
+// 设置用户离开/在线状态，由客户端的 presence 消息触发
+func setAway(userID string, away bool) {
+	presences.Lock()
+	if away {
+		presences.away[userID] = true
+	} else {
+		delete(presences.away, userID)
+	}
+	presences.Unlock()
+	updatePresence(userID)
+}
+
+// 用户全部连接断开，记录最后在线时间并广播离线
+func markOffline(userID string) {
+	if err := storage.GetStorageManager().SetLastSeen(userID, time.Now().Unix()); err != nil {
+		log.Printf("记录用户 %s 最后在线时间失败: %v", userID, err)
+	}
+	presences.Lock()
+	delete(presences.away, userID)
+	presences.Unlock()
+	updatePresence(userID)
+}
+
+// 重新计算用户状态，与上次广播的状态不同时通知在线好友
+func updatePresence(userID string) {
+	p := GetPresence(userID)
+	presences.Lock()
+	// 没有记录的用户视为离线，避免向好友重复广播离线状态
+	prev, ok := presences.status[userID]
+	if !ok {
+		prev = PresenceOffline
+	}
+	changed := prev != p.Status
+	if p.Status == PresenceOffline {
+		delete(presences.status, userID)
+	} else {
+		presences.status[userID] = p.Status
+	}
+	presences.Unlock()
+	if changed {
+		broadcastPresence(userID, p)
+	}
+}
+
+// 广播在线状态给好友，不在线的好友忽略
+func broadcastPresence(userID string, p *Presence) {
+	friends, err := storage.GetStorageManager().GetFriends(userID)
+	if err != nil {
+		log.Printf("获取用户 %s 好友列表失败: %v", userID, err)
+		return
+	}
+	msg := &pb.IMMessage{Type: "presence", From: userID, Content: p.Status, Timestamp: p.LastSeen}
+	b, _ := proto.Marshal(msg)
+	for _, f := range friends {
+		_ = SendToUser(f, b)
+	}
+}
@@ -0,0 +1,181 @@
+package protocol
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"im/core/auth"
+	pb "im/core/protocol/pb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 一个已登录的WebSocket连接
+type Session struct {
+	UserID        string
+	DeviceID      string
+	AuthSessionID string // 登录所用令牌的登录会话ID，令牌吊销时据此断开
+	Conn          *WSConn
+	ConnectedAt   time.Time
+}
+
+// 会话注册表: userID -> deviceID -> 会话，同一用户可在多个设备同时在线
+type sessionRegistry struct {
+	mu       sync.RWMutex
+	sessions map[string]map[string]*Session
+}
+
+var sessions = &sessionRegistry{sessions: make(map[string]map[string]*Session)}
+
+// 未携带设备ID的连接按顺序分配匿名设备ID
+var anonDeviceSeq int64
+
+func nextAnonDeviceID() string {
+	return fmt.Sprintf("anon-%d", atomic.AddInt64(&anonDeviceSeq, 1))
+}
+
+// 注册会话，返回同一设备上被替换的旧会话
+func (r *sessionRegistry) add(s *Session) *Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	devices := r.sessions[s.UserID]
+	if devices == nil {
+		devices = make(map[string]*Session)
+		r.sessions[s.UserID] = devices
+	}
+	old := devices[s.DeviceID]
+	devices[s.DeviceID] = s
+	return old
+}
+
+// 注销会话，仅当该设备当前仍是此会话时才删除，避免误删重新登录的新会话
+// 返回用户是否已没有任何在线会话
+func (r *sessionRegistry) remove(s *Session) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	devices := r.sessions[s.UserID]
+	if devices[s.DeviceID] == s {
+		delete(devices, s.DeviceID)
+	}
+	if len(devices) == 0 {
+		delete(r.sessions, s.UserID)
+		return true
+	}
+	return false
+}
+
+// 获取用户全部在线会话
+func (r *sessionRegistry) get(userID string) []*Session {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var list []*Session
+	for _, s := range r.sessions[userID] {
+		list = append(list, s)
+	}
+	return list
+}
+
+// 令牌被吊销后断开对应的连接，authSessionID 为空时断开该用户全部连接
+func disconnectRevoked(userID, authSessionID string) {
+	kickMsg := &pb.IMMessage{Type: "error", Content: "登录已失效，请重新登录"}
+	b, _ := proto.Marshal(kickMsg)
+	for _, s := range sessions.get(userID) {
+		if authSessionID != "" && s.AuthSessionID != authSessionID {
+			continue
+		}
+		s.Conn.Send(b)
+		s.Conn.Close()
+	}
+}
+
+func init() {
+	auth.OnTokenRevoked(disconnectRevoked)
+}
+
+// 用户是否有在线会话
+func IsOnline(userID string) bool {
+	return len(sessions.get(userID)) > 0
+}
+
+// 获取用户的在线设备ID
+func OnlineDevices(userID string) []string {
+	var devices []string
+	for _, s := range sessions.get(userID) {
+		devices = append(devices, s.DeviceID)
+	}
+	return devices
+}
+
+// 发送给用户的全部在线会话，except 不为空时跳过该连接
+// 只要有一个会话发送成功即视为成功
+func sendToSessions(userID string, except *WSConn, data []byte) error {
+	list := sessions.get(userID)
+	if len(list) == 0 {
+		return fmt.Errorf("用户不在线")
+	}
+	var lastErr error
+	delivered := false
+	for _, s := range list {
+		if s.Conn == except {
+			continue
+		}
+		if err := s.Conn.Send(data); err != nil {
+			lastErr = err
+			continue
+		}
+		delivered = true
+	}
+	if !delivered {
+		if lastErr == nil {
+			return fmt.Errorf("用户没有其他在线设备")
+		}
+		return lastErr
+	}
+	return nil
+}
+
+// 单个连接的发送队列状态，不包含用户和设备信息，避免泄露在线用户
+type ConnStats struct {
+	QueueDepth    int   `json:"queue_depth"`
+	QueueCapacity int   `json:"queue_capacity"`
+	Dropped       int64 `json:"dropped"`
+}
+
+// 全部在线连接的发送队列状态，供监控使用
+type QueueStats struct {
+	Users         int         `json:"users"`
+	Sessions      int         `json:"sessions"`
+	QueuedTotal   int         `json:"queued_total"`
+	MaxQueueDepth int         `json:"max_queue_depth"`
+	DroppedTotal  int64       `json:"dropped_total"`
+	Connections   []ConnStats `json:"connections"`
+}
+
+// 统计全部在线连接的发送队列深度
+func GetQueueStats() *QueueStats {
+	sessions.mu.RLock()
+	defer sessions.mu.RUnlock()
+	stats := &QueueStats{}
+	for _, devices := range sessions.sessions {
+		if len(devices) > 0 {
+			stats.Users++
+		}
+		for _, s := range devices {
+			cs := ConnStats{
+				QueueDepth:    s.Conn.QueueLen(),
+				QueueCapacity: s.Conn.QueueCap(),
+				Dropped:       s.Conn.Dropped(),
+			}
+			stats.Sessions++
+			stats.QueuedTotal += cs.QueueDepth
+			stats.DroppedTotal += cs.Dropped
+			if cs.QueueDepth > stats.MaxQueueDepth {
+				stats.MaxQueueDepth = cs.QueueDepth
+			}
+			stats.Connections = append(stats.Connections, cs)
+		}
+	}
+	return stats
+}
@@ -2,10 +2,12 @@ package protocol
 
 import (
 	"fmt"
+	"im/config"
 	"im/core/auth"
 	pb "im/core/protocol/pb"
+	"log"
 	"net/http"
-	"sync"
+	"time"
 
 	"im/core/storage"
 
@@ -13,78 +15,142 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// 用户与连接映射
-var wsUserConn sync.Map // userID -> *websocket.Conn
-
 type WSProtocol struct {
 	upgrader websocket.Upgrader
-	handler  func(conn *websocket.Conn, data []byte)
+	handler  func(conn *WSConn, data []byte)
+
+	SendQueueSize      int                // 每个连接的发送队列长度
+	WriteTimeout       time.Duration      // 单次写超时
+	PingInterval       time.Duration      // 心跳间隔，为0时不发送心跳
+	PongTimeout        time.Duration      // 空闲超时，超过该时间未收到任何数据则断开，为0时不检测
+	SlowConsumerPolicy SlowConsumerPolicy // 发送队列已满时的处理策略
 }
 
 func NewWSProtocol() *WSProtocol {
 	return &WSProtocol{
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
+			CheckOrigin: func(r *http.Request) bool {
+				return config.GetServerConfig().AllowOrigin(r.Header.Get("Origin"))
+			},
 		},
+		SendQueueSize:      defaultSendQueueSize,
+		WriteTimeout:       defaultWriteTimeout,
+		PingInterval:       defaultPingInterval,
+		PongTimeout:        defaultPongTimeout,
+		SlowConsumerPolicy: PolicyDisconnect,
 	}
 }
 
 func (w *WSProtocol) Start(addr string) error {
 	http.HandleFunc("/ws", func(rw http.ResponseWriter, r *http.Request) {
-		conn, err := w.upgrader.Upgrade(rw, r, nil)
+		ws, err := w.upgrader.Upgrade(rw, r, nil)
 		if err != nil {
 			return
 		}
+		conn := newWSConn(ws, w)
 		go w.handleConn(conn)
 	})
+	go retryPendingLoop()
 	fmt.Println("WebSocket 协议监听于", addr+"/ws")
 	return http.ListenAndServe(addr, nil)
 }
 
-func (w *WSProtocol) handleConn(conn *websocket.Conn) {
+func (w *WSProtocol) handleConn(conn *WSConn) {
 	defer conn.Close()
-	var userID string
+	var session *Session
 	for {
-		_, data, err := conn.ReadMessage()
+		_, data, err := conn.conn.ReadMessage()
 		if err != nil {
-			if userID != "" {
-				wsUserConn.Delete(userID)
+			if session != nil && sessions.remove(session) {
+				markOffline(session.UserID)
 			}
 			return
 		}
+		conn.extendReadDeadline()
 		var msg pb.IMMessage
 		if err := proto.Unmarshal(data, &msg); err != nil {
 			// 替换所有 JSON 字符串消息为 IMMessage 结构体 proto.Marshal 后发送
 			errMsg := &pb.IMMessage{Type: "error", Content: "消息格式错误"}
 			b, _ := proto.Marshal(errMsg)
-			conn.WriteMessage(websocket.BinaryMessage, b)
+			conn.Send(b)
 			continue
 		}
-		if userID == "" {
+		if session == nil {
 			if msg.Type != "login" || msg.Token == "" {
 				// 替换所有 JSON 字符串消息为 IMMessage 结构体 proto.Marshal 后发送
 				errMsg := &pb.IMMessage{Type: "error", Content: "请先登录"}
 				b, _ := proto.Marshal(errMsg)
-				conn.WriteMessage(websocket.BinaryMessage, b)
+				conn.Send(b)
 				return
 			}
-			uid, err := auth.ParseToken(msg.Token)
+			claims, err := auth.ParseClaims(msg.Token)
 			if err != nil {
 				// 替换所有 JSON 字符串消息为 IMMessage 结构体 proto.Marshal 后发送
 				errMsg := &pb.IMMessage{Type: "error", Content: "token无效"}
 				b, _ := proto.Marshal(errMsg)
-				conn.WriteMessage(websocket.BinaryMessage, b)
+				conn.Send(b)
 				return
 			}
-			userID = uid
-			wsUserConn.Store(userID, conn)
+			deviceID := msg.DeviceId
+			if deviceID == "" {
+				deviceID = nextAnonDeviceID()
+			}
+			uid := claims.UserID
+			session = &Session{UserID: uid, DeviceID: deviceID, AuthSessionID: claims.SessionID, Conn: conn, ConnectedAt: time.Now()}
+			// 同一设备重复登录时关闭旧连接
+			if old := sessions.add(session); old != nil && old.Conn != conn {
+				kickMsg := &pb.IMMessage{Type: "error", Content: "该设备已在别处重新登录"}
+				b, _ := proto.Marshal(kickMsg)
+				old.Conn.Send(b)
+				old.Conn.Close()
+			}
+			// 登录响应携带当前最新消息ID，客户端断线重连时据此请求补发
+			latestID, err := storage.GetStorageManager().GetLatestMessageID(uid)
+			if err != nil {
+				log.Printf("用户 %s 获取最新消息ID失败: %v", uid, err)
+			}
 			// 替换所有 JSON 字符串消息为 IMMessage 结构体 proto.Marshal 后发送
-			loginMsg := &pb.IMMessage{Type: "login", Content: "登录成功"}
+			loginMsg := &pb.IMMessage{Type: "login", Content: "登录成功", DeviceId: deviceID, MsgId: latestID}
 			b, _ := proto.Marshal(loginMsg)
-			conn.WriteMessage(websocket.BinaryMessage, b)
+			conn.Send(b)
+			updatePresence(uid)
+			// 推送离线期间收到的消息
+			delivered, err := deliverOfflineMessages(uid, conn)
+			if err != nil {
+				log.Printf("用户 %s %v", uid, err)
+				continue
+			}
+			// 断线重连：登录消息的 msg_id 为客户端收到的最后一条消息ID
+			if msg.MsgId > 0 {
+				if err := resumeMessages(uid, conn, msg.MsgId, delivered); err != nil {
+					log.Printf("用户 %s %v", uid, err)
+				}
+			}
+			continue
+		}
+		// 送达确认
+		if msg.Type == "ack" {
+			if err := AckMessage(session.UserID, msg.MsgId); err != nil {
+				log.Printf("用户 %s 确认消息 %d 失败: %v", session.UserID, msg.MsgId, err)
+			}
+			continue
+		}
+		// 客户端上报离开/在线状态
+		if msg.Type == "presence" {
+			switch msg.Content {
+			case PresenceAway:
+				setAway(session.UserID, true)
+			case PresenceOnline:
+				setAway(session.UserID, false)
+			default:
+				errMsg := &pb.IMMessage{Type: "error", Content: "无效的在线状态"}
+				b, _ := proto.Marshal(errMsg)
+				conn.Send(b)
+			}
 			continue
 		}
-		msg.From = userID // 账号
+		msg.From = session.UserID // 账号
+		msg.DeviceId = session.DeviceID
 		b, _ := proto.Marshal(&msg)
 		if w.handler != nil {
 			w.handler(conn, b)
@@ -92,23 +158,18 @@ func (w *WSProtocol) handleConn(conn *websocket.Conn) {
 	}
 }
 
-// 发送消息给指定用户
+// 发送消息给指定用户的全部在线设备
 func SendToUser(userID string, data []byte) error {
-	v, ok := wsUserConn.Load(userID)
-	if !ok {
-		return fmt.Errorf("用户不在线")
-	}
-	conn := v.(*websocket.Conn)
-	return conn.WriteMessage(websocket.BinaryMessage, data)
+	return sendToSessions(userID, nil, data)
+}
+
+// 同步消息给用户的其他在线设备（不包括发送消息的连接）
+func SyncToOtherDevices(userID string, conn *WSConn, data []byte) error {
+	return sendToSessions(userID, conn, data)
 }
 
 // 发送通知给指定用户
 func SendNotificationToUser(userID string, notif *pb.Notification) error {
-	v, ok := wsUserConn.Load(userID)
-	if !ok {
-		return fmt.Errorf("用户不在线")
-	}
-	conn := v.(*websocket.Conn)
 	msg := &pb.IMMessage{
 		Type:      "notification",
 		From:      notif.From,
@@ -118,7 +179,7 @@ func SendNotificationToUser(userID string, notif *pb.Notification) error {
 		Extra:     notif.Extra,
 	}
 	b, _ := proto.Marshal(msg)
-	return conn.WriteMessage(websocket.BinaryMessage, b)
+	return SendToUser(userID, b)
 }
 
 func (w *WSProtocol) Stop() error {
@@ -126,11 +187,11 @@ func (w *WSProtocol) Stop() error {
 	return nil
 }
 
-func (w *WSProtocol) Send(conn *websocket.Conn, data []byte) error {
-	return conn.WriteMessage(websocket.BinaryMessage, data)
+func (w *WSProtocol) Send(conn *WSConn, data []byte) error {
+	return conn.Send(data)
 }
 
-func (w *WSProtocol) OnMessage(handler func(conn *websocket.Conn, data []byte)) {
+func (w *WSProtocol) OnMessage(handler func(conn *WSConn, data []byte)) {
 	w.handler = handler
 }
 
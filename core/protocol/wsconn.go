@@ -0,0 +1,174 @@
+package protocol
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 发送队列已满时对慢消费者的处理策略
+// 需要确认的聊天消息在客户端 ack 前一直保留在离线消息表中，
+// 无论哪种策略都不会丢失，用户重新登录后会再次推送
+type SlowConsumerPolicy int
+
+const (
+	PolicyDisconnect SlowConsumerPolicy = iota // 断开连接，客户端重连后从离线消息表补发
+	PolicyDrop                                 // 丢弃当前消息，保持连接
+)
+
+const (
+	defaultSendQueueSize = 256              // 默认每个连接的发送队列长度
+	defaultWriteTimeout  = 10 * time.Second // 默认单次写超时
+	defaultPingInterval  = 30 * time.Second // 默认心跳间隔
+	defaultPongTimeout   = 60 * time.Second // 默认空闲超时，超时未收到任何数据则断开
+)
+
+var (
+	errConnClosed   = errors.New("连接已关闭")
+	errSlowConsumer = errors.New("发送队列已满")
+)
+
+// WebSocket连接，所有写操作经由独立的写协程串行完成
+// gorilla/websocket 不允许并发写，业务代码不应直接调用底层连接的 WriteMessage
+type WSConn struct {
+	conn         *websocket.Conn
+	send         chan []byte
+	done         chan struct{}
+	closeOnce    sync.Once
+	writeTimeout time.Duration
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	policy       SlowConsumerPolicy
+	dropped      int64
+}
+
+func newWSConn(conn *websocket.Conn, w *WSProtocol) *WSConn {
+	c := &WSConn{
+		conn:         conn,
+		send:         make(chan []byte, w.SendQueueSize),
+		done:         make(chan struct{}),
+		writeTimeout: w.WriteTimeout,
+		pingInterval: w.PingInterval,
+		pongTimeout:  w.PongTimeout,
+		policy:       w.SlowConsumerPolicy,
+	}
+	// 收到 pong 视为连接存活，顺延读超时
+	c.extendReadDeadline()
+	conn.SetPongHandler(func(string) error {
+		c.extendReadDeadline()
+		return nil
+	})
+	go c.writeLoop()
+	return c
+}
+
+// 顺延读超时，超过 pongTimeout 未收到任何数据时读操作返回错误，连接随之关闭
+func (c *WSConn) extendReadDeadline() {
+	if c.pongTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	}
+}
+
+// 写协程，定时发送心跳，连接关闭前尽量发送完队列中剩余的消息
+func (c *WSConn) writeLoop() {
+	defer c.conn.Close()
+	var pingC <-chan time.Time
+	if c.pingInterval > 0 {
+		ticker := time.NewTicker(c.pingInterval)
+		defer ticker.Stop()
+		pingC = ticker.C
+	}
+	for {
+		select {
+		case data := <-c.send:
+			if err := c.write(data); err != nil {
+				c.Close()
+				return
+			}
+		case <-pingC:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.writeTimeout)); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			for {
+				select {
+				case data := <-c.send:
+					if err := c.write(data); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *WSConn) write(data []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	return c.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// 非阻塞发送，队列已满时按慢消费者策略处理
+func (c *WSConn) Send(data []byte) error {
+	return c.enqueue(data, 0)
+}
+
+// 发送并在队列已满时最多等待 wait，用于登录后补发离线消息等批量场景
+func (c *WSConn) enqueue(data []byte, wait time.Duration) error {
+	select {
+	case <-c.done:
+		return errConnClosed
+	default:
+	}
+	select {
+	case c.send <- data:
+		return nil
+	default:
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case c.send <- data:
+			return nil
+		case <-c.done:
+			return errConnClosed
+		case <-timer.C:
+		}
+	}
+
+	atomic.AddInt64(&c.dropped, 1)
+	if c.policy == PolicyDisconnect {
+		log.Printf("连接 %s 发送队列已满，断开慢消费者", c.conn.RemoteAddr())
+		c.Close()
+	}
+	return errSlowConsumer
+}
+
+// 关闭连接，已入队的消息会在关闭前发送
+func (c *WSConn) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// 当前发送队列中等待写出的消息数
+func (c *WSConn) QueueLen() int {
+	return len(c.send)
+}
+
+// 发送队列容量
+func (c *WSConn) QueueCap() int {
+	return cap(c.send)
+}
+
+// 因队列已满被丢弃的消息数
+func (c *WSConn) Dropped() int64 {
+	return atomic.LoadInt64(&c.dropped)
+}
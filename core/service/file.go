@@ -8,31 +8,36 @@ import (
 	"strings"
 	"time"
 
+	"im/config"
 	pb "im/core/protocol/pb"
 )
 
-const (
-	UploadDir   = "./uploads"
-	MaxFileSize = 50 * 1024 * 1024 // 50MB
-)
-
 // 文件服务
-type FileService struct{}
+type FileService struct {
+	uploadDir   string // 上传文件保存目录
+	maxFileSize int64  // 单个文件最大字节数
+}
 
-// 获取文件服务实例
+// 获取文件服务实例，上传目录和大小限制来自配置
 func NewFileService() *FileService {
+	cfg := config.GetUploadConfig()
 	// 确保上传目录存在
-	if err := os.MkdirAll(UploadDir, 0755); err != nil {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
 		panic(fmt.Sprintf("创建上传目录失败: %v", err))
 	}
-	return &FileService{}
+	return &FileService{uploadDir: cfg.Dir, maxFileSize: cfg.MaxSize}
+}
+
+// 单个文件最大字节数
+func (fs *FileService) MaxFileSize() int64 {
+	return fs.maxFileSize
 }
 
 // 上传文件
 func (fs *FileService) UploadFile(file io.Reader, filename string, size int64) (*pb.FileInfo, error) {
 	// 检查文件大小
-	if size > MaxFileSize {
-		return nil, fmt.Errorf("文件太大，最大支持50MB")
+	if size > fs.maxFileSize {
+		return nil, fmt.Errorf("文件太大，最大支持%s", formatSize(fs.maxFileSize))
 	}
 
 	// 检查文件类型
@@ -44,7 +49,7 @@ func (fs *FileService) UploadFile(file io.Reader, filename string, size int64) (
 	// 生成唯一文件名
 	ext := filepath.Ext(filename)
 	uniqueFilename := fmt.Sprintf("%d_%s%s", time.Now().UnixNano(), fs.generateRandomString(8), ext)
-	filePath := filepath.Join(UploadDir, uniqueFilename)
+	filePath := filepath.Join(fs.uploadDir, uniqueFilename)
 
 	// 创建文件
 	dst, err := os.Create(filePath)
@@ -72,7 +77,7 @@ func (fs *FileService) UploadFile(file io.Reader, filename string, size int64) (
 
 // 获取文件路径
 func (fs *FileService) GetFilePath(filename string) (string, error) {
-	filePath := filepath.Join(UploadDir, filename)
+	filePath := filepath.Join(fs.uploadDir, filename)
 
 	// 检查文件是否存在
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -82,6 +87,18 @@ func (fs *FileService) GetFilePath(filename string) (string, error) {
 	return filePath, nil
 }
 
+// 删除已上传的文件，只接受服务器生成的文件名，不允许包含路径
+func (fs *FileService) DeleteFile(filename string) error {
+	if filename == "" || filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") {
+		return fmt.Errorf("文件名不合法")
+	}
+	filePath, err := fs.GetFilePath(filename)
+	if err != nil {
+		return err
+	}
+	return os.Remove(filePath)
+}
+
 // 获取文件类型
 func (fs *FileService) getFileType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -124,6 +141,18 @@ func (fs *FileService) GetMimeType(filename string) string {
 	}
 }
 
+// 格式化文件大小
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024 && size%(1024*1024) == 0:
+		return fmt.Sprintf("%dMB", size/(1024*1024))
+	case size >= 1024 && size%1024 == 0:
+		return fmt.Sprintf("%dKB", size/1024)
+	default:
+		return fmt.Sprintf("%d字节", size)
+	}
+}
+
 // 生成随机字符串
 func (fs *FileService) generateRandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
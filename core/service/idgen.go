@@ -0,0 +1,143 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"im/config"
+	"im/core/storage"
+)
+
+// 雪花算法ID: 41位毫秒时间戳 | 10位节点ID | 12位毫秒内序号
+const (
+	snowflakeEpoch    = 1704067200000 // 2024-01-01 00:00:00 UTC，毫秒
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// 分配UID时遇到已被占用的UID最多重试的次数
+const maxUIDAttempts = 10
+
+// 自选UID必须以字母开头，分配的UID都是数字，两者不会冲突
+var vanityUIDPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// ID生成器，为用户、消息和群分配唯一ID，重启后也不会重复
+// 按配置使用数据库序列或雪花算法，同一进程内需共用一个实例
+type IDGenerator struct {
+	cfg *config.UIDConfig
+	sm  *storage.StorageManager
+	now func() time.Time
+
+	mu       sync.Mutex
+	lastTime int64 // 上次生成雪花ID的时间，相对 snowflakeEpoch 的毫秒数
+	sequence int64 // lastTime 内已使用的序号
+}
+
+var (
+	globalIDGenerator *IDGenerator
+	idGeneratorOnce   sync.Once
+)
+
+// 获取全局ID生成器
+func GetIDGenerator() *IDGenerator {
+	idGeneratorOnce.Do(func() {
+		globalIDGenerator = &IDGenerator{
+			cfg: config.GetUIDConfig(),
+			sm:  storage.GetStorageManager(),
+			now: time.Now,
+		}
+	})
+	return globalIDGenerator
+}
+
+// 分配下一个ID，name 为 storage.Sequence* 之一，雪花算法下各类ID共用同一个序列
+func (g *IDGenerator) Next(name string) (int64, error) {
+	if g.cfg.Strategy == config.UIDSnowflake {
+		return g.snowflake(), nil
+	}
+	return g.sm.NextSequence(name)
+}
+
+// 分配消息ID
+func (g *IDGenerator) NextMessageID() (int64, error) {
+	return g.Next(storage.SequenceMessage)
+}
+
+// 分配群ID
+func (g *IDGenerator) NextGroupID() (int64, error) {
+	return g.Next(storage.SequenceGroup)
+}
+
+// 分配新用户的UID，跳过已被占用的UID（如旧版本分配或手动创建的用户）
+func (g *IDGenerator) NextUID() (string, error) {
+	for i := 0; i < maxUIDAttempts; i++ {
+		id, err := g.Next(storage.SequenceUser)
+		if err != nil {
+			return "", err
+		}
+		uid := strconv.FormatInt(id, 10)
+		taken, err := g.uidTaken(uid)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return uid, nil
+		}
+	}
+	return "", fmt.Errorf("分配UID失败，请稍后重试")
+}
+
+// 检查自选UID是否可以使用
+func (g *IDGenerator) CheckVanityUID(uid string) error {
+	if !g.cfg.AllowVanity {
+		return fmt.Errorf("不支持自选UID")
+	}
+	if !vanityUIDPattern.MatchString(uid) {
+		return fmt.Errorf("自选UID需以字母开头，由4-32位字母、数字或下划线组成")
+	}
+	taken, err := g.uidTaken(uid)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("UID %s 已被占用", uid)
+	}
+	return nil
+}
+
+// UID是否已被注册
+func (g *IDGenerator) uidTaken(uid string) (bool, error) {
+	_, err := g.sm.GetUserByUID(uid)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// 生成雪花ID，同一节点生成的ID单调递增
+func (g *IDGenerator) snowflake() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now().UnixMilli() - snowflakeEpoch
+	// 时钟回拨时沿用上次的时间，避免生成重复ID
+	if now < g.lastTime {
+		now = g.lastTime
+	}
+	if now == g.lastTime {
+		g.sequence = (g.sequence + 1) & snowflakeMaxSeq
+		if g.sequence == 0 {
+			// 同一毫秒内的序号已用完，借用下一毫秒
+			now++
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastTime = now
+	return now<<(snowflakeNodeBits+snowflakeSeqBits) | g.cfg.NodeID<<snowflakeSeqBits | g.sequence
+}
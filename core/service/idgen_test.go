@@ -0,0 +1,162 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"im/config"
+	"im/core/storage"
+)
+
+// 使用内存存储和指定时钟的ID生成器，clock 为相对 snowflakeEpoch 的毫秒数
+func newTestIDGenerator(cfg *config.UIDConfig, clock *int64) (*IDGenerator, *storage.MemoryStorage) {
+	mem := storage.NewMemoryStorage()
+	g := &IDGenerator{cfg: cfg, sm: &storage.StorageManager{Store: mem}, now: time.Now}
+	if clock != nil {
+		g.now = func() time.Time { return time.UnixMilli(snowflakeEpoch + *clock) }
+	}
+	return g, mem
+}
+
+// 拆分雪花ID: 时间、节点ID、序号
+func splitSnowflake(id int64) (int64, int64, int64) {
+	return id >> (snowflakeNodeBits + snowflakeSeqBits),
+		(id >> snowflakeSeqBits) & (1<<snowflakeNodeBits - 1),
+		id & snowflakeMaxSeq
+}
+
+func TestSnowflakeClock(t *testing.T) {
+	type want struct{ time, seq int64 }
+	tests := []struct {
+		name  string
+		clock []int64 // 每次生成ID时的时钟
+		want  []want
+	}{
+		{"同一毫秒内序号递增", []int64{1000, 1000, 1001}, []want{{1000, 0}, {1000, 1}, {1001, 0}}},
+		{"时钟回拨时沿用上次的时间", []int64{1000, 990, 995, 1001}, []want{{1000, 0}, {1000, 1}, {1000, 2}, {1001, 0}}},
+		{"时钟回拨超过一毫秒后恢复", []int64{2000, 1000, 2000, 2001}, []want{{2000, 0}, {2000, 1}, {2000, 2}, {2001, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var clock int64
+			g, _ := newTestIDGenerator(&config.UIDConfig{Strategy: config.UIDSnowflake, NodeID: 7}, &clock)
+			var last int64
+			for i, c := range tt.clock {
+				clock = c
+				id := g.snowflake()
+				ts, node, seq := splitSnowflake(id)
+				if ts != tt.want[i].time || seq != tt.want[i].seq || node != 7 {
+					t.Errorf("第 %d 个ID = (时间 %d, 节点 %d, 序号 %d)，期望 (时间 %d, 节点 7, 序号 %d)",
+						i, ts, node, seq, tt.want[i].time, tt.want[i].seq)
+				}
+				if id <= last {
+					t.Errorf("第 %d 个ID %d 不大于上一个 %d", i, id, last)
+				}
+				last = id
+			}
+		})
+	}
+}
+
+func TestSnowflakeSequenceOverflow(t *testing.T) {
+	clock := int64(1000)
+	g, _ := newTestIDGenerator(&config.UIDConfig{Strategy: config.UIDSnowflake}, &clock)
+
+	var last int64
+	for i := 0; i <= snowflakeMaxSeq+1; i++ {
+		id := g.snowflake()
+		if id <= last {
+			t.Fatalf("第 %d 个ID %d 不大于上一个 %d", i, id, last)
+		}
+		last = id
+	}
+	// 同一毫秒内的 4096 个序号用完后借用下一毫秒
+	if ts, _, seq := splitSnowflake(last); ts != 1001 || seq != 0 {
+		t.Errorf("序号用完后 = (时间 %d, 序号 %d)，期望 (1001, 0)", ts, seq)
+	}
+	// 时钟仍停留在借用前的毫秒时继续使用借用的时间
+	if ts, _, seq := splitSnowflake(g.snowflake()); ts != 1001 || seq != 1 {
+		t.Errorf("借用后下一个ID = (时间 %d, 序号 %d)，期望 (1001, 1)", ts, seq)
+	}
+}
+
+func TestSnowflakeConcurrentUnique(t *testing.T) {
+	g, _ := newTestIDGenerator(&config.UIDConfig{Strategy: config.UIDSnowflake, NodeID: config.MaxUIDNodeID}, nil)
+	const workers, perWorker = 8, 2000
+
+	ids := make(chan int64, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id, err := g.NextMessageID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int64]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("生成了重复的ID %d", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestNextUIDSkipsTakenUIDs(t *testing.T) {
+	g, mem := newTestIDGenerator(&config.UIDConfig{Strategy: config.UIDSequence}, nil)
+	// 序列上线前已存在的用户
+	for _, uid := range []string{"1", "2"} {
+		if err := mem.CreateUser(uid, "u", "x", uid+"@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if uid, err := g.NextUID(); err != nil || uid != "3" {
+		t.Errorf("NextUID = %q, %v，期望 \"3\"", uid, err)
+	}
+	if id, err := g.NextGroupID(); err != nil || id != 1 {
+		t.Errorf("NextGroupID = %d, %v，期望 1（各序列独立）", id, err)
+	}
+}
+
+func TestCheckVanityUID(t *testing.T) {
+	g, mem := newTestIDGenerator(&config.UIDConfig{Strategy: config.UIDSequence, AllowVanity: true}, nil)
+	if err := mem.CreateUser("alice", "alice", "x", "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		uid     string
+		wantErr bool
+	}{
+		{"合法且未被占用", "bob_2024", false},
+		{"已被占用", "alice", true},
+		{"以数字开头", "1bob", true},
+		{"过短", "bob", true},
+		{"过长", strings.Repeat("b", 33), true},
+		{"包含非法字符", "bob-2024", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := g.CheckVanityUID(tt.uid); (err != nil) != tt.wantErr {
+				t.Errorf("CheckVanityUID(%q) = %v", tt.uid, err)
+			}
+		})
+	}
+
+	g.cfg.AllowVanity = false
+	if err := g.CheckVanityUID("bob_2024"); err == nil {
+		t.Error("未开启自选UID时应拒绝")
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"im/config"
+	"im/core/storage"
+)
+
+// 登录被拒绝，Wait 为需要等待的时间
+type LoginBlockedError struct {
+	Reason string
+	Wait   time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("%s，请%s后再试", e.Reason, formatWait(e.Wait))
+}
+
+// 将等待时间格式化为便于阅读的形式，不足一秒按一秒计
+func formatWait(d time.Duration) string {
+	if d >= time.Minute {
+		return fmt.Sprintf("%d分钟", int((d+time.Minute-1)/time.Minute))
+	}
+	if d < time.Second {
+		d = time.Second
+	}
+	return fmt.Sprintf("%d秒", int((d+time.Second-1)/time.Second))
+}
+
+// 登录防暴力破解，按账号和IP统计失败次数
+// 账号连续失败后每次尝试需等待的时间逐次翻倍，达到阈值后锁定并通知账号所有者
+type LoginGuard struct {
+	cfg    *config.LoginGuardConfig
+	mailer Mailer
+	sm     *storage.StorageManager
+}
+
+// 获取登录防护实例，阈值来自配置
+func NewLoginGuard() *LoginGuard {
+	return &LoginGuard{
+		cfg:    config.GetLoginGuardConfig(),
+		mailer: NewMailer(config.GetMailConfig()),
+		sm:     storage.GetStorageManager(),
+	}
+}
+
+// 第 n 次失败后需要等待的时间
+func (g *LoginGuard) delay(failures int) time.Duration {
+	d := g.cfg.BaseDelay
+	for i := 1; i < failures && d < g.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > g.cfg.MaxDelay {
+		d = g.cfg.MaxDelay
+	}
+	return d
+}
+
+// 检查是否允许本次登录尝试，被拒绝时返回 *LoginBlockedError 并记录审计
+func (g *LoginGuard) Check(uid, ip string) error {
+	now := time.Now()
+	since := now.Add(-g.cfg.FailureWindow).Unix()
+	blocked := func(reason string, until time.Time) error {
+		g.record(uid, ip, storage.LoginBlocked, reason, now)
+		return &LoginBlockedError{Reason: reason, Wait: until.Sub(now)}
+	}
+
+	if ip != "" {
+		count, lastAt, err := g.sm.GetIPLoginFailures(ip, since)
+		if err != nil {
+			return err
+		}
+		if until := time.Unix(lastAt, 0).Add(g.cfg.LockoutDuration); count >= g.cfg.MaxIPFailures && now.Before(until) {
+			return blocked("该IP登录失败次数过多", until)
+		}
+	}
+
+	count, lastAt, err := g.sm.GetAccountLoginFailures(uid, since)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	last := time.Unix(lastAt, 0)
+	if until := last.Add(g.cfg.LockoutDuration); count >= g.cfg.MaxAccountFailures && now.Before(until) {
+		return blocked("登录失败次数过多，账号已临时锁定", until)
+	}
+	if until := last.Add(g.delay(count)); now.Before(until) {
+		return blocked("登录尝试过于频繁", until)
+	}
+	return nil
+}
+
+// 记录登录失败，失败次数刚达到阈值时邮件通知账号所有者
+func (g *LoginGuard) Fail(uid, ip, reason string) {
+	now := time.Now()
+	g.record(uid, ip, storage.LoginFailure, reason, now)
+
+	count, _, err := g.sm.GetAccountLoginFailures(uid, now.Add(-g.cfg.FailureWindow).Unix())
+	if err != nil || count != g.cfg.MaxAccountFailures {
+		return
+	}
+	user, err := g.sm.GetUserByUID(uid)
+	if err != nil || user.Email == "" {
+		return
+	}
+	body := fmt.Sprintf("您的账号 %s 在短时间内连续 %d 次登录失败，已被临时锁定%s。\n最近一次尝试来自IP: %s，时间: %s\n如非本人操作，建议尽快修改密码并开启两步验证。",
+		uid, count, formatWait(g.cfg.LockoutDuration), ip, now.Format("2006-01-02 15:04:05"))
+	go func() {
+		if err := g.mailer.Send(user.Email, "IM 账号登录异常提醒", body); err != nil {
+			log.Printf("向 %s 发送登录锁定通知失败: %v", user.Email, err)
+		}
+	}()
+}
+
+// 记录密码验证通过、等待两步验证
+func (g *LoginGuard) Challenge(uid, ip string) {
+	g.record(uid, ip, storage.LoginChallenge, "", time.Now())
+}
+
+// 记录登录成功，账号失败次数随之清零
+func (g *LoginGuard) Succeed(uid, ip string) {
+	g.record(uid, ip, storage.LoginSuccess, "", time.Now())
+}
+
+func (g *LoginGuard) record(uid, ip, result, reason string, now time.Time) {
+	err := g.sm.AddLoginAudit(&storage.LoginAudit{
+		UserID:    uid,
+		IP:        ip,
+		Result:    result,
+		Reason:    reason,
+		CreatedAt: now.Unix(),
+	})
+	if err != nil {
+		log.Printf("记录登录审计失败: %v", err)
+	}
+}
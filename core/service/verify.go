@@ -0,0 +1,175 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"im/config"
+	"im/core/storage"
+)
+
+// 验证码用途
+const (
+	PurposeRegister    = "register"     // 注册时确认邮箱
+	PurposeResetPwd    = "reset_pwd"    // 通过邮箱重置密码
+	PurposeChangeEmail = "change_email" // 修改绑定邮箱，发送到新邮箱
+)
+
+var purposeSubjects = map[string]string{
+	PurposeRegister:    "注册验证码",
+	PurposeResetPwd:    "重置密码验证码",
+	PurposeChangeEmail: "修改邮箱验证码",
+}
+
+var (
+	ErrCodeInvalid     = errors.New("验证码无效或已过期")
+	ErrCodeMismatch    = errors.New("验证码错误")
+	ErrCodeTooManyTry  = errors.New("验证码错误次数过多，请重新获取")
+	ErrSendTooFrequent = errors.New("发送过于频繁，请稍后再试")
+)
+
+// 邮箱验证码服务
+type VerifyService struct {
+	cfg    *config.VerifyConfig
+	mailer Mailer
+	sm     *storage.StorageManager
+}
+
+// 获取验证码服务实例，邮件发送方式和频率限制来自配置
+func NewVerifyService() *VerifyService {
+	return &VerifyService{
+		cfg:    config.GetVerifyConfig(),
+		mailer: NewMailer(config.GetMailConfig()),
+		sm:     storage.GetStorageManager(),
+	}
+}
+
+// 是否为支持的验证码用途
+func IsValidPurpose(purpose string) bool {
+	_, ok := purposeSubjects[purpose]
+	return ok
+}
+
+// 验证码哈希，与邮箱和用途绑定
+func hashCode(email, purpose, code string) string {
+	sum := sha256.Sum256([]byte(email + "\x00" + purpose + "\x00" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// 生成指定位数的随机数字验证码
+func (vs *VerifyService) generateCode() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(vs.cfg.CodeLength)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", vs.cfg.CodeLength, n), nil
+}
+
+// 检查发送频率，按邮箱限制间隔和每小时次数，按IP限制每小时次数
+func (vs *VerifyService) checkRateLimit(email, ip string, now time.Time) error {
+	if vs.cfg.SendInterval > 0 {
+		recent, err := vs.sm.CountEmailCodesByEmail(email, now.Add(-vs.cfg.SendInterval).Unix())
+		if err != nil {
+			return err
+		}
+		if recent > 0 {
+			return ErrSendTooFrequent
+		}
+	}
+	hourAgo := now.Add(-time.Hour).Unix()
+	byEmail, err := vs.sm.CountEmailCodesByEmail(email, hourAgo)
+	if err != nil {
+		return err
+	}
+	if byEmail >= vs.cfg.MaxPerEmailHour {
+		return ErrSendTooFrequent
+	}
+	if ip != "" {
+		byIP, err := vs.sm.CountEmailCodesByIP(ip, hourAgo)
+		if err != nil {
+			return err
+		}
+		if byIP >= vs.cfg.MaxPerIPHour {
+			return ErrSendTooFrequent
+		}
+	}
+	return nil
+}
+
+// 生成并发送验证码，同一邮箱同一用途只有最近一次发送的验证码有效
+func (vs *VerifyService) SendCode(email, purpose, ip string) error {
+	if !IsValidPurpose(purpose) {
+		return fmt.Errorf("不支持的验证码用途")
+	}
+	now := time.Now()
+	if err := vs.checkRateLimit(email, ip, now); err != nil {
+		return err
+	}
+	code, err := vs.generateCode()
+	if err != nil {
+		return fmt.Errorf("生成验证码失败")
+	}
+	err = vs.sm.SaveEmailCode(&storage.EmailCode{
+		Email:     email,
+		Purpose:   purpose,
+		CodeHash:  hashCode(email, purpose, code),
+		IP:        ip,
+		ExpiresAt: now.Add(vs.cfg.CodeTTL).Unix(),
+		CreatedAt: now.Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("保存验证码失败: %v", err)
+	}
+	body := fmt.Sprintf("您的%s为: %s\n%d分钟内有效，请勿泄露给他人。如非本人操作请忽略此邮件。",
+		purposeSubjects[purpose], code, int(vs.cfg.CodeTTL/time.Minute))
+	if err := vs.mailer.Send(email, "IM "+purposeSubjects[purpose], body); err != nil {
+		log.Printf("向 %s 发送验证码失败: %v", email, err)
+		return fmt.Errorf("邮件发送失败")
+	}
+	return nil
+}
+
+// 发送通知邮件，不记录验证码
+func (vs *VerifyService) Notify(email, subject, body string) error {
+	return vs.mailer.Send(email, subject, body)
+}
+
+// 校验验证码，成功后验证码失效
+func (vs *VerifyService) VerifyCode(email, purpose, code string) error {
+	c, err := vs.sm.GetLatestEmailCode(email, purpose)
+	if err == sql.ErrNoRows {
+		return ErrCodeInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if c.Used || time.Now().Unix() >= c.ExpiresAt {
+		return ErrCodeInvalid
+	}
+	if c.Attempts >= vs.cfg.MaxAttempts {
+		return ErrCodeTooManyTry
+	}
+	if subtle.ConstantTimeCompare([]byte(c.CodeHash), []byte(hashCode(email, purpose, code))) != 1 {
+		if err := vs.sm.IncrEmailCodeAttempts(c.ID); err != nil {
+			return err
+		}
+		return ErrCodeMismatch
+	}
+	ok, err := vs.sm.MarkEmailCodeUsed(c.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCodeInvalid
+	}
+	return nil
+}
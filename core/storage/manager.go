@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -8,11 +9,13 @@ import (
 	"im/config"
 )
 
-// 存储管理器
+// 调用 Init 之前访问存储时返回的错误
+var ErrNotInitialized = errors.New("存储未初始化")
+
+// 存储管理器，使用前需调用 Init 按配置选择存储后端，初始化前的操作都返回 ErrNotInitialized
 type StorageManager struct {
-	mysqlStorage *MySQLStorage
-	useMySQL     bool
-	mu           sync.RWMutex
+	Store
+	mu sync.Mutex
 }
 
 var (
@@ -23,232 +26,53 @@ var (
 // 获取全局存储管理器实例
 func GetStorageManager() *StorageManager {
 	once.Do(func() {
-		globalStorageManager = &StorageManager{
-			useMySQL: false, // 默认未初始化
-		}
+		globalStorageManager = &StorageManager{Store: uninitializedStore{}}
 	})
 	return globalStorageManager
 }
 
-// 初始化MySQL存储
-func (sm *StorageManager) InitMySQL() error {
+// 按配置初始化存储后端
+func (sm *StorageManager) Init() error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	dbConfig := config.GetDatabaseConfig()
-	mysqlStorage, err := NewMySQLStorage(dbConfig.GetDSN())
+	store, err := NewStore(config.GetDatabaseConfig())
 	if err != nil {
 		return err
 	}
-
-	sm.mysqlStorage = mysqlStorage
-	sm.useMySQL = true
-	log.Println("MySQL存储初始化成功")
+	sm.Store = store
 	return nil
 }
 
+// 根据数据库配置创建存储后端
+func NewStore(c *config.DatabaseConfig) (Store, error) {
+	switch c.Driver {
+	case config.DriverMySQL:
+		store, err := NewMySQLStorage(c.GetDSN())
+		if err != nil {
+			return nil, err
+		}
+		log.Println("使用MySQL存储")
+		return store, nil
+	case config.DriverSQLite:
+		store, err := NewSQLiteStorage(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("使用SQLite存储: %s", c.Path)
+		return store, nil
+	case config.DriverMemory:
+		log.Println("使用内存存储，重启后数据将丢失")
+		return NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", c.Driver)
+	}
+}
+
 // 关闭存储
 func (sm *StorageManager) Close() error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	if sm.mysqlStorage != nil {
-		return sm.mysqlStorage.Close()
-	}
-	return nil
-}
-
-// ==================== 用户相关操作 ====================
-
-// 创建用户
-func (sm *StorageManager) CreateUser(uid, username, password, email string) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.CreateUser(uid, username, password, email)
-}
-
-// 根据UID获取用户
-func (sm *StorageManager) GetUserByUID(uid string) (*User, error) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return nil, fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.GetUserByUID(uid)
-}
-
-// 根据邮箱获取用户
-func (sm *StorageManager) GetUserByEmail(email string) (*User, error) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return nil, fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.GetUserByEmail(email)
-}
-
-// 更新用户昵称
-func (sm *StorageManager) UpdateUsername(uid, newUsername string) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.UpdateUsername(uid, newUsername)
-}
-
-// 更新用户密码
-func (sm *StorageManager) UpdatePassword(uid, newPassword string) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.UpdatePassword(uid, newPassword)
-}
-
-// 删除用户
-func (sm *StorageManager) DeleteUser(uid string) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.DeleteUser(uid)
-}
-
-// ==================== 好友关系相关操作 ====================
-
-// 添加好友关系
-func (sm *StorageManager) AddFriendship(userID, friendID string) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.AddFriendship(userID, friendID)
-}
-
-// 删除好友关系
-func (sm *StorageManager) DeleteFriendship(userID, friendID string) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.DeleteFriendship(userID, friendID)
-}
-
-// 获取好友列表
-func (sm *StorageManager) GetFriends(userID string) ([]string, error) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return nil, fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.GetFriends(userID)
-}
-
-// 检查是否为好友
-func (sm *StorageManager) IsFriend(userID, friendID string) (bool, error) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return false, fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.IsFriend(userID, friendID)
-}
-
-// ==================== 好友请求相关操作 ====================
-
-// 添加好友请求
-func (sm *StorageManager) AddFriendRequest(fromUserID, toUserID, verifyMsg string) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.AddFriendRequest(fromUserID, toUserID, verifyMsg)
-}
-
-// 获取收到的好友请求
-func (sm *StorageManager) GetFriendRequests(toUserID string) (map[string]string, error) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return nil, fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.GetFriendRequests(toUserID)
-}
-
-// 处理好友请求
-func (sm *StorageManager) HandleFriendRequest(fromUserID, toUserID string, accept bool) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.HandleFriendRequest(fromUserID, toUserID, accept)
-}
-
-// ==================== 好友备注和免打扰相关操作 ====================
-
-// 设置好友备注
-func (sm *StorageManager) SetFriendRemark(userID, friendID, remark string) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.SetFriendRemark(userID, friendID, remark)
-}
-
-// 获取好友备注
-func (sm *StorageManager) GetFriendRemark(userID, friendID string) (string, error) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return "", fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.GetFriendRemark(userID, friendID)
-}
-
-// 设置免打扰
-func (sm *StorageManager) SetFriendDND(userID, friendID string, dnd bool) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.SetFriendDND(userID, friendID, dnd)
-}
-
-// 获取免打扰状态
-func (sm *StorageManager) GetFriendDND(userID, friendID string) (bool, error) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if !sm.useMySQL || sm.mysqlStorage == nil {
-		return false, fmt.Errorf("MySQL存储未初始化")
-	}
-	return sm.mysqlStorage.GetFriendDND(userID, friendID)
+	return sm.Store.Close()
 }
@@ -0,0 +1,1363 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内存存储实现，数据只保存在进程内，重启后丢失
+// 不依赖任何数据库，用于本地开发和测试；查不到数据时与SQL实现一样返回 sql.ErrNoRows
+type MemoryStorage struct {
+	mu sync.RWMutex
+
+	users       map[string]*User // UID -> 用户
+	friendships map[[2]string]*Friendship
+	requests    map[[2]string]*FriendRequest
+
+	messages    []*Message                // 按消息ID升序
+	messageByID map[int64]*Message        // 消息ID -> 消息
+	offline     map[string]map[int64]bool // 用户 -> 未送达的消息ID
+	reactions   []*memoryReaction         // 按回应时间排序
+	readCursors map[memoryCursorKey]int64 // 会话 -> 已读消息ID
+	lastSeen    map[string]int64          // 用户 -> 最后在线时间
+	groups      map[int64]*Group          // 群ID -> 群
+	members     map[int64]map[string]*GroupMember
+
+	refreshTokens map[string]*RefreshToken // 令牌哈希 -> 刷新令牌
+	revokedTokens map[string]*RevokedToken
+	emailCodes    map[int64]*EmailCode
+	totp          map[string]*TOTPInfo
+	recoveryCodes map[string]map[string]bool // 用户 -> 恢复码哈希 -> 是否已使用
+	loginAudits   []*LoginAudit
+	userRoles     map[string]map[string]int64 // 用户 -> 角色 -> 授予时间
+	permissions   map[string][]string
+
+	nextID    map[string]int64 // 各自增ID的当前值
+	sequences map[string]int64 // 命名序列的当前值
+}
+
+var _ Store = (*MemoryStorage)(nil)
+
+type memoryReaction struct {
+	messageID int64
+	userID    string
+	emoji     string
+}
+
+type memoryCursorKey struct {
+	userID  string
+	peerID  string
+	groupID int64
+}
+
+// 创建内存存储实例
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		users:         make(map[string]*User),
+		friendships:   make(map[[2]string]*Friendship),
+		requests:      make(map[[2]string]*FriendRequest),
+		messageByID:   make(map[int64]*Message),
+		offline:       make(map[string]map[int64]bool),
+		readCursors:   make(map[memoryCursorKey]int64),
+		lastSeen:      make(map[string]int64),
+		groups:        make(map[int64]*Group),
+		members:       make(map[int64]map[string]*GroupMember),
+		refreshTokens: make(map[string]*RefreshToken),
+		revokedTokens: make(map[string]*RevokedToken),
+		emailCodes:    make(map[int64]*EmailCode),
+		totp:          make(map[string]*TOTPInfo),
+		recoveryCodes: make(map[string]map[string]bool),
+		userRoles:     make(map[string]map[string]int64),
+		permissions:   make(map[string][]string),
+		nextID:        make(map[string]int64),
+		sequences:     make(map[string]int64),
+	}
+}
+
+// 生成表的下一个自增ID，调用方需持有写锁
+func (s *MemoryStorage) newID(table string) int64 {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// 关闭存储，内存存储无需释放资源
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+// ==================== ID序列相关操作 ====================
+
+// 获取命名序列的下一个值，从1开始递增
+func (s *MemoryStorage) NextSequence(name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sequences[name]++
+	return s.sequences[name], nil
+}
+
+// ==================== 用户相关操作 ====================
+
+// 创建用户
+func (s *MemoryStorage) CreateUser(uid, username, password, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[uid]; ok {
+		return fmt.Errorf("UID %s 已存在", uid)
+	}
+	if s.userByEmail(email) != nil {
+		return fmt.Errorf("邮箱 %s 已存在", email)
+	}
+	now := time.Now()
+	s.users[uid] = &User{ID: s.newID("users"), UID: uid, Username: username, Password: password, Email: email,
+		CreatedAt: now, UpdatedAt: now}
+	return nil
+}
+
+// 按邮箱查找用户，邮箱不区分大小写，调用方需持有锁
+func (s *MemoryStorage) userByEmail(email string) *User {
+	for _, u := range s.users {
+		if strings.EqualFold(u.Email, email) {
+			return u
+		}
+	}
+	return nil
+}
+
+// 根据UID获取用户
+func (s *MemoryStorage) GetUserByUID(uid string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[uid]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	user := *u
+	return &user, nil
+}
+
+// 根据邮箱获取用户
+func (s *MemoryStorage) GetUserByEmail(email string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u := s.userByEmail(email)
+	if u == nil {
+		return nil, sql.ErrNoRows
+	}
+	user := *u
+	return &user, nil
+}
+
+// 修改用户信息，用户不存在时忽略
+func (s *MemoryStorage) updateUser(uid string, update func(u *User)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[uid]; ok {
+		update(u)
+		u.UpdatedAt = time.Now()
+	}
+}
+
+// 更新用户昵称
+func (s *MemoryStorage) UpdateUsername(uid, newUsername string) error {
+	s.updateUser(uid, func(u *User) { u.Username = newUsername })
+	return nil
+}
+
+// 更新用户密码
+func (s *MemoryStorage) UpdatePassword(uid, newPassword string) error {
+	s.updateUser(uid, func(u *User) { u.Password = newPassword })
+	return nil
+}
+
+// 更新用户邮箱
+func (s *MemoryStorage) UpdateEmail(uid, newEmail string) error {
+	s.mu.RLock()
+	other := s.userByEmail(newEmail)
+	s.mu.RUnlock()
+	if other != nil && other.UID != uid {
+		return fmt.Errorf("邮箱 %s 已存在", newEmail)
+	}
+	s.updateUser(uid, func(u *User) { u.Email = newEmail })
+	return nil
+}
+
+// 删除用户
+func (s *MemoryStorage) DeleteUser(uid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, uid)
+	return nil
+}
+
+// ==================== 好友关系相关操作 ====================
+
+// 添加好友关系
+func (s *MemoryStorage) AddFriendship(userID, friendID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addFriendship(userID, friendID)
+}
+
+// 添加双向好友关系，调用方需持有写锁
+func (s *MemoryStorage) addFriendship(userID, friendID string) error {
+	if s.friendships[[2]string{userID, friendID}] != nil || s.friendships[[2]string{friendID, userID}] != nil {
+		return fmt.Errorf("%s 和 %s 已是好友", userID, friendID)
+	}
+	now := time.Now()
+	for _, pair := range [][2]string{{userID, friendID}, {friendID, userID}} {
+		s.friendships[pair] = &Friendship{ID: s.newID("friendships"), UserID: pair[0], FriendID: pair[1],
+			CreatedAt: now, UpdatedAt: now}
+	}
+	return nil
+}
+
+// 删除好友关系
+func (s *MemoryStorage) DeleteFriendship(userID, friendID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.friendships, [2]string{userID, friendID})
+	delete(s.friendships, [2]string{friendID, userID})
+	return nil
+}
+
+// 获取好友列表
+func (s *MemoryStorage) GetFriends(userID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*Friendship
+	for _, f := range s.friendships {
+		if f.UserID == userID {
+			list = append(list, f)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	var friends []string
+	for _, f := range list {
+		friends = append(friends, f.FriendID)
+	}
+	return friends, nil
+}
+
+// 检查是否为好友
+func (s *MemoryStorage) IsFriend(userID, friendID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.friendships[[2]string{userID, friendID}] != nil, nil
+}
+
+// ==================== 好友请求相关操作 ====================
+
+// 添加好友请求
+func (s *MemoryStorage) AddFriendRequest(fromUserID, toUserID, verifyMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]string{fromUserID, toUserID}
+	if s.requests[key] != nil {
+		return fmt.Errorf("%s 已向 %s 发送过好友请求", fromUserID, toUserID)
+	}
+	now := time.Now()
+	s.requests[key] = &FriendRequest{ID: s.newID("friend_requests"), FromUserID: fromUserID, ToUserID: toUserID,
+		VerifyMsg: verifyMsg, Status: "pending", CreatedAt: now, UpdatedAt: now}
+	return nil
+}
+
+// 获取收到的好友请求
+func (s *MemoryStorage) GetFriendRequests(toUserID string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	requests := make(map[string]string)
+	for _, r := range s.requests {
+		if r.ToUserID == toUserID && r.Status == "pending" {
+			requests[r.FromUserID] = r.VerifyMsg
+		}
+	}
+	return requests, nil
+}
+
+// 处理待处理的好友请求，请求不存在或已处理时返回 ErrFriendRequestNotFound
+func (s *MemoryStorage) HandleFriendRequest(fromUserID, toUserID string, accept bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.requests[[2]string{fromUserID, toUserID}]
+	if r == nil || r.Status != "pending" {
+		return ErrFriendRequestNotFound
+	}
+	status := "rejected"
+	// 如果接受，添加好友关系
+	if accept {
+		if err := s.addFriendship(fromUserID, toUserID); err != nil {
+			return err
+		}
+		status = "accepted"
+	}
+	r.Status = status
+	r.UpdatedAt = time.Now()
+	return nil
+}
+
+// ==================== 好友备注和免打扰相关操作 ====================
+
+// 设置好友备注
+func (s *MemoryStorage) SetFriendRemark(userID, friendID, remark string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f := s.friendships[[2]string{userID, friendID}]; f != nil {
+		f.Remark = remark
+		f.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// 获取好友备注
+func (s *MemoryStorage) GetFriendRemark(userID, friendID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f := s.friendships[[2]string{userID, friendID}]
+	if f == nil {
+		return "", sql.ErrNoRows
+	}
+	return f.Remark, nil
+}
+
+// 设置免打扰
+func (s *MemoryStorage) SetFriendDND(userID, friendID string, dnd bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f := s.friendships[[2]string{userID, friendID}]; f != nil {
+		f.DND = dnd
+		f.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// 获取免打扰状态
+func (s *MemoryStorage) GetFriendDND(userID, friendID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f := s.friendships[[2]string{userID, friendID}]
+	if f == nil {
+		return false, sql.ErrNoRows
+	}
+	return f.DND, nil
+}
+
+// ==================== 聊天消息相关操作 ====================
+
+// 复制消息，避免调用方修改存储中的数据
+func copyMessages(list []*Message) []*Message {
+	messages := make([]*Message, 0, len(list))
+	for _, m := range list {
+		msg := *m
+		messages = append(messages, &msg)
+	}
+	return messages
+}
+
+// 根据ID查找消息，调用方需持有锁
+func (s *MemoryStorage) message(id int64) *Message {
+	return s.messageByID[id]
+}
+
+// 消息是否对用户可见：与自己相关的单聊消息及所在群的群消息，调用方需持有锁
+func (s *MemoryStorage) visible(m *Message, userID string) bool {
+	if m.GroupID == 0 {
+		return m.ToUserID == userID || m.FromUserID == userID
+	}
+	return s.members[m.GroupID][userID] != nil
+}
+
+// 消息是否属于两人之间的单聊
+func isConversation(m *Message, userID, friendID string) bool {
+	return m.GroupID == 0 && ((m.FromUserID == userID && m.ToUserID == friendID) ||
+		(m.FromUserID == friendID && m.ToUserID == userID))
+}
+
+// 保存消息，并为每个接收者记录一条离线消息，返回消息ID；msg.ID 不为0时使用调用方分配的ID
+func (s *MemoryStorage) SaveMessage(msg *Message, recipients []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *msg
+	if stored.ID == 0 {
+		// 与数据库自增一致，取当前最大ID加一
+		stored.ID = 1
+		if n := len(s.messages); n > 0 {
+			stored.ID = s.messages[n-1].ID + 1
+		}
+	} else if s.messageByID[stored.ID] != nil {
+		return 0, fmt.Errorf("消息ID %d 已存在", stored.ID)
+	}
+	stored.Recalled = false
+	stored.EditedAt = 0
+	stored.CreatedAt = time.Now()
+
+	i := sort.Search(len(s.messages), func(i int) bool { return s.messages[i].ID > stored.ID })
+	s.messages = append(s.messages, nil)
+	copy(s.messages[i+1:], s.messages[i:])
+	s.messages[i] = &stored
+	s.messageByID[stored.ID] = &stored
+
+	for _, userID := range recipients {
+		if s.offline[userID] == nil {
+			s.offline[userID] = make(map[int64]bool)
+		}
+		s.offline[userID][stored.ID] = true
+	}
+	msg.ID = stored.ID
+	return stored.ID, nil
+}
+
+// 获取用户的离线消息，按消息ID升序
+func (s *MemoryStorage) GetOfflineMessages(userID string) ([]*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*Message
+	for id := range s.offline[userID] {
+		if m := s.message(id); m != nil {
+			list = append(list, m)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return copyMessages(list), nil
+}
+
+// 删除离线消息（消息已送达）
+func (s *MemoryStorage) RemoveOfflineMessage(userID string, messageID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.offline[userID], messageID)
+	return nil
+}
+
+// 分页查询两人之间或群内的聊天记录，结果按消息ID升序
+// 指定 AfterID/AfterTime 且未指定 Before 条件时向后翻页，否则从最新消息向前翻页
+// 多取一条用于判断翻页方向上是否还有更多消息
+func (s *MemoryStorage) GetChatHistory(q *HistoryQuery) ([]*Message, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	match := func(m *Message) bool {
+		if q.GroupID != 0 {
+			if m.GroupID != q.GroupID {
+				return false
+			}
+		} else if !isConversation(m, q.UserID, q.FriendID) {
+			return false
+		}
+		return (q.BeforeID <= 0 || m.ID < q.BeforeID) && (q.AfterID <= 0 || m.ID > q.AfterID) &&
+			(q.BeforeTime <= 0 || m.Timestamp < q.BeforeTime) && (q.AfterTime <= 0 || m.Timestamp > q.AfterTime)
+	}
+
+	forward := (q.AfterID > 0 || q.AfterTime > 0) && q.BeforeID == 0 && q.BeforeTime == 0
+	var list []*Message
+	if forward {
+		for i := 0; i < len(s.messages) && len(list) <= q.Limit; i++ {
+			if match(s.messages[i]) {
+				list = append(list, s.messages[i])
+			}
+		}
+	} else {
+		for i := len(s.messages) - 1; i >= 0 && len(list) <= q.Limit; i-- {
+			if match(s.messages[i]) {
+				list = append(list, s.messages[i])
+			}
+		}
+	}
+
+	hasMore := len(list) > q.Limit
+	if hasMore {
+		list = list[:q.Limit]
+	}
+	if !forward {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+	return copyMessages(list), hasMore, nil
+}
+
+// 获取用户可见的、ID大于 afterID 的消息，按消息ID升序，用于断线重连后补发
+func (s *MemoryStorage) GetMessagesAfter(userID string, afterID int64, limit int) ([]*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*Message
+	for _, m := range s.messages {
+		if len(list) >= limit {
+			break
+		}
+		if m.ID > afterID && s.visible(m, userID) {
+			list = append(list, m)
+		}
+	}
+	return copyMessages(list), nil
+}
+
+// 按关键词搜索用户可见的消息，按消息ID倒序，返回本页结果与总数
+// 关键词在消息内容和文件名中做不区分大小写的子串匹配
+func (s *MemoryStorage) SearchMessages(q *SearchQuery) ([]*Message, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keyword := strings.ToLower(q.Keyword)
+	types := make(map[string]bool)
+	for _, t := range q.Types {
+		types[t] = true
+	}
+
+	var matched []*Message
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		m := s.messages[i]
+		if m.Recalled || !s.visible(m, q.UserID) {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(m.Content), keyword) && !strings.Contains(strings.ToLower(m.Extra), keyword) {
+			continue
+		}
+		if q.SenderID != "" && m.FromUserID != q.SenderID {
+			continue
+		}
+		if q.GroupID != 0 {
+			if m.GroupID != q.GroupID {
+				continue
+			}
+		} else if q.FriendID != "" && !isConversation(m, q.UserID, q.FriendID) {
+			continue
+		}
+		if (q.StartTime > 0 && m.Timestamp < q.StartTime) || (q.EndTime > 0 && m.Timestamp >= q.EndTime) {
+			continue
+		}
+		if len(types) > 0 && !types[m.Type] {
+			continue
+		}
+		matched = append(matched, m)
+	}
+
+	total := len(matched)
+	if q.Offset >= total {
+		return nil, total, nil
+	}
+	matched = matched[q.Offset:]
+	if len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	return copyMessages(matched), total, nil
+}
+
+// 获取用户可见的最新消息ID，没有消息时返回0
+func (s *MemoryStorage) GetLatestMessageID(userID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.visible(s.messages[i], userID) {
+			return s.messages[i].ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// 根据ID获取消息
+func (s *MemoryStorage) GetMessage(id int64) (*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m := s.message(id)
+	if m == nil {
+		return nil, sql.ErrNoRows
+	}
+	msg := *m
+	return &msg, nil
+}
+
+// 根据ID批量获取消息，不存在的ID忽略
+func (s *MemoryStorage) GetMessagesByIDs(ids []int64) ([]*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[int64]bool)
+	var list []*Message
+	for _, id := range ids {
+		if m := s.message(id); m != nil && !seen[id] {
+			seen[id] = true
+			list = append(list, m)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return copyMessages(list), nil
+}
+
+// 撤回消息，清空消息内容并标记为已撤回
+func (s *MemoryStorage) RecallMessage(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m := s.message(id); m != nil {
+		m.Recalled = true
+		m.Content, m.Extra, m.Filename, m.Filesize, m.MimeType = "", "", "", 0, ""
+	}
+	return nil
+}
+
+// 编辑消息内容
+func (s *MemoryStorage) EditMessage(id int64, content string, editedAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m := s.message(id); m != nil && !m.Recalled {
+		m.Content = content
+		m.EditedAt = editedAt
+	}
+	return nil
+}
+
+// ==================== 消息回应相关操作 ====================
+
+// 添加回应，返回是否新增（已回应过同一表情时返回 false）
+func (s *MemoryStorage) AddReaction(messageID int64, userID, emoji string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.reactions {
+		if r.messageID == messageID && r.userID == userID && r.emoji == emoji {
+			return false, nil
+		}
+	}
+	s.reactions = append(s.reactions, &memoryReaction{messageID: messageID, userID: userID, emoji: emoji})
+	return true, nil
+}
+
+// 取消回应，返回是否确有删除
+func (s *MemoryStorage) RemoveReaction(messageID int64, userID, emoji string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, r := range s.reactions {
+		if r.messageID == messageID && r.userID == userID && r.emoji == emoji {
+			s.reactions = append(s.reactions[:i], s.reactions[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// 批量获取消息的回应汇总: 消息ID -> 按首次回应时间排序的表情列表
+func (s *MemoryStorage) GetReactions(messageIDs []int64) (map[int64][]*Reaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[int64]bool)
+	for _, id := range messageIDs {
+		wanted[id] = true
+	}
+	result := make(map[int64][]*Reaction)
+	for _, r := range s.reactions {
+		if !wanted[r.messageID] {
+			continue
+		}
+		var reaction *Reaction
+		for _, existing := range result[r.messageID] {
+			if existing.Emoji == r.emoji {
+				reaction = existing
+				break
+			}
+		}
+		if reaction == nil {
+			reaction = &Reaction{Emoji: r.emoji}
+			result[r.messageID] = append(result[r.messageID], reaction)
+		}
+		reaction.UserIDs = append(reaction.UserIDs, r.userID)
+	}
+	return result, nil
+}
+
+// ==================== 已读相关操作 ====================
+
+// 将会话已读位置推进到 msgID，只前进不后退，返回已读位置是否有变化
+// 单聊传 peerID，群聊传 groupID
+func (s *MemoryStorage) UpdateReadCursor(userID, peerID string, groupID, msgID int64) (bool, error) {
+	if groupID != 0 {
+		peerID = ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memoryCursorKey{userID, peerID, groupID}
+	last, ok := s.readCursors[key]
+	if ok && msgID <= last {
+		return false, nil
+	}
+	s.readCursors[key] = msgID
+	return true, nil
+}
+
+// 获取会话已读位置，未读过时返回0
+func (s *MemoryStorage) GetReadCursor(userID, peerID string, groupID int64) (int64, error) {
+	if groupID != 0 {
+		peerID = ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.readCursors[memoryCursorKey{userID, peerID, groupID}], nil
+}
+
+// 统计用户每个单聊会话的未读消息数: 好友UID -> 未读数
+func (s *MemoryStorage) GetUnreadCounts(userID string) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, m := range s.messages {
+		if m.GroupID == 0 && m.ToUserID == userID && m.ID > s.readCursors[memoryCursorKey{userID, m.FromUserID, 0}] {
+			counts[m.FromUserID]++
+		}
+	}
+	return counts, nil
+}
+
+// 统计用户每个群的未读消息数（不含自己发送的消息）: 群ID -> 未读数
+func (s *MemoryStorage) GetGroupUnreadCounts(userID string) (map[int64]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[int64]int64)
+	for _, m := range s.messages {
+		if m.GroupID == 0 || m.FromUserID == userID || s.members[m.GroupID][userID] == nil {
+			continue
+		}
+		if m.ID > s.readCursors[memoryCursorKey{userID, "", m.GroupID}] {
+			counts[m.GroupID]++
+		}
+	}
+	return counts, nil
+}
+
+// ==================== 在线状态相关操作 ====================
+
+// 记录用户最后在线时间（Unix秒）
+func (s *MemoryStorage) SetLastSeen(userID string, lastSeen int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSeen[userID] = lastSeen
+	return nil
+}
+
+// 获取用户最后在线时间，从未上线时返回0
+func (s *MemoryStorage) GetLastSeen(userID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastSeen[userID], nil
+}
+
+// ==================== 令牌相关操作 ====================
+
+// 保存刷新令牌
+func (s *MemoryStorage) SaveRefreshToken(t *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refreshTokens[t.TokenHash] != nil {
+		return fmt.Errorf("刷新令牌已存在")
+	}
+	token := *t
+	token.Revoked = false
+	s.refreshTokens[t.TokenHash] = &token
+	return nil
+}
+
+// 根据哈希获取刷新令牌，不存在时返回 sql.ErrNoRows
+func (s *MemoryStorage) GetRefreshToken(tokenHash string) (*RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t := s.refreshTokens[tokenHash]
+	if t == nil {
+		return nil, sql.ErrNoRows
+	}
+	token := *t
+	return &token, nil
+}
+
+// 将刷新令牌标记为已使用，返回是否由本次调用标记（并发刷新时只有一个成功）
+func (s *MemoryStorage) RevokeRefreshToken(tokenHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.refreshTokens[tokenHash]
+	if t == nil || t.Revoked {
+		return false, nil
+	}
+	t.Revoked = true
+	return true, nil
+}
+
+// 吊销同一次登录轮换出的全部刷新令牌
+func (s *MemoryStorage) RevokeRefreshTokenFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.refreshTokens {
+		if t.FamilyID == familyID {
+			t.Revoked = true
+		}
+	}
+	return nil
+}
+
+// 吊销用户的全部刷新令牌
+func (s *MemoryStorage) RevokeUserRefreshTokens(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.refreshTokens {
+		if t.UserID == userID {
+			t.Revoked = true
+		}
+	}
+	return nil
+}
+
+// 添加吊销记录，重复吊销时更新时间
+func (s *MemoryStorage) AddRevokedToken(t *RevokedToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing := s.revokedTokens[t.TokenID]; existing != nil {
+		existing.RevokedAt, existing.ExpiresAt = t.RevokedAt, t.ExpiresAt
+		return nil
+	}
+	token := *t
+	s.revokedTokens[t.TokenID] = &token
+	return nil
+}
+
+// 获取仍在有效期内的吊销记录
+func (s *MemoryStorage) GetRevokedTokens(now int64) ([]*RevokedToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*RevokedToken
+	for _, t := range s.revokedTokens {
+		if t.ExpiresAt > now {
+			token := *t
+			list = append(list, &token)
+		}
+	}
+	return list, nil
+}
+
+// 清理已过期的刷新令牌和吊销记录
+func (s *MemoryStorage) DeleteExpiredTokens(now int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.refreshTokens {
+		if t.ExpiresAt <= now {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for id, t := range s.revokedTokens {
+		if t.ExpiresAt <= now {
+			delete(s.revokedTokens, id)
+		}
+	}
+	return nil
+}
+
+// ==================== 邮箱验证码相关操作 ====================
+
+// 保存验证码
+func (s *MemoryStorage) SaveEmailCode(c *EmailCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.ID = s.newID("email_codes")
+	code := *c
+	code.Attempts, code.Used = 0, false
+	s.emailCodes[c.ID] = &code
+	return nil
+}
+
+// 获取邮箱某用途最近发送的验证码，不存在时返回 sql.ErrNoRows
+func (s *MemoryStorage) GetLatestEmailCode(email, purpose string) (*EmailCode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latest *EmailCode
+	for _, c := range s.emailCodes {
+		if strings.EqualFold(c.Email, email) && c.Purpose == purpose && (latest == nil || c.ID > latest.ID) {
+			latest = c
+		}
+	}
+	if latest == nil {
+		return nil, sql.ErrNoRows
+	}
+	code := *latest
+	return &code, nil
+}
+
+// 验证码校验失败次数加一
+func (s *MemoryStorage) IncrEmailCodeAttempts(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c := s.emailCodes[id]; c != nil {
+		c.Attempts++
+	}
+	return nil
+}
+
+// 将验证码标记为已使用，返回是否由本次调用标记（验证码只能使用一次）
+func (s *MemoryStorage) MarkEmailCodeUsed(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.emailCodes[id]
+	if c == nil || c.Used {
+		return false, nil
+	}
+	c.Used = true
+	return true, nil
+}
+
+// 统计某时间之后向该邮箱发送的验证码数量
+func (s *MemoryStorage) CountEmailCodesByEmail(email string, since int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, c := range s.emailCodes {
+		if strings.EqualFold(c.Email, email) && c.CreatedAt >= since {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// 统计某时间之后该IP请求发送的验证码数量
+func (s *MemoryStorage) CountEmailCodesByIP(ip string, since int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, c := range s.emailCodes {
+		if c.IP == ip && c.CreatedAt >= since {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// 清理某时间之前的验证码记录
+func (s *MemoryStorage) DeleteEmailCodesBefore(before int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, c := range s.emailCodes {
+		if c.CreatedAt < before {
+			delete(s.emailCodes, id)
+		}
+	}
+	return nil
+}
+
+// ==================== 两步验证相关操作 ====================
+
+// 保存待确认的两步验证密钥，覆盖之前未确认的密钥
+func (s *MemoryStorage) SaveTOTPSecret(userID, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totp[userID] = &TOTPInfo{UserID: userID, Secret: secret}
+	return nil
+}
+
+// 获取用户两步验证信息，未设置时返回 sql.ErrNoRows
+func (s *MemoryStorage) GetTOTP(userID string) (*TOTPInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t := s.totp[userID]
+	if t == nil {
+		return nil, sql.ErrNoRows
+	}
+	info := *t
+	return &info, nil
+}
+
+// 记录验证通过的时间步，返回是否更新成功（时间步不大于已记录的值时视为重复使用）
+func (s *MemoryStorage) UpdateTOTPLastStep(userID string, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.totp[userID]
+	if t == nil || t.LastStep >= step {
+		return false, nil
+	}
+	t.LastStep = step
+	return true, nil
+}
+
+// 启用两步验证并替换恢复码
+func (s *MemoryStorage) EnableTOTP(userID string, recoveryHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t := s.totp[userID]; t != nil {
+		t.Enabled = true
+	}
+	codes := make(map[string]bool)
+	for _, h := range recoveryHashes {
+		codes[h] = false
+	}
+	s.recoveryCodes[userID] = codes
+	return nil
+}
+
+// 关闭两步验证，同时删除恢复码
+func (s *MemoryStorage) DeleteTOTP(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.totp, userID)
+	delete(s.recoveryCodes, userID)
+	return nil
+}
+
+// 使用恢复码，返回恢复码是否有效且未使用
+func (s *MemoryStorage) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used, ok := s.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	s.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
+
+// 统计用户剩余可用的恢复码数量
+func (s *MemoryStorage) CountRecoveryCodes(userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, used := range s.recoveryCodes[userID] {
+		if !used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ==================== 登录审计相关操作 ====================
+
+// 记录一次登录尝试
+func (s *MemoryStorage) AddLoginAudit(a *LoginAudit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	audit := *a
+	audit.ID = s.newID("login_audit")
+	s.loginAudits = append(s.loginAudits, &audit)
+	return nil
+}
+
+// 统计某时间之后账号的登录失败次数和最后一次失败时间，登录成功后重新计数
+func (s *MemoryStorage) GetAccountLoginFailures(userID string, since int64) (int, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count, lastAt := 0, int64(0)
+	for _, a := range s.loginAudits {
+		if a.UserID != userID {
+			continue
+		}
+		switch {
+		case a.Result == LoginSuccess:
+			count, lastAt = 0, 0
+		case a.Result == LoginFailure && a.CreatedAt >= since:
+			count++
+			if a.CreatedAt > lastAt {
+				lastAt = a.CreatedAt
+			}
+		}
+	}
+	return count, lastAt, nil
+}
+
+// 统计某时间之后该IP的登录失败次数和最后一次失败时间
+func (s *MemoryStorage) GetIPLoginFailures(ip string, since int64) (int, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count, lastAt := 0, int64(0)
+	for _, a := range s.loginAudits {
+		if a.IP == ip && a.Result == LoginFailure && a.CreatedAt >= since {
+			count++
+			if a.CreatedAt > lastAt {
+				lastAt = a.CreatedAt
+			}
+		}
+	}
+	return count, lastAt, nil
+}
+
+// 获取账号最近的登录记录
+func (s *MemoryStorage) GetLoginAudits(userID string, limit int) ([]*LoginAudit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var audits []*LoginAudit
+	for i := len(s.loginAudits) - 1; i >= 0 && len(audits) < limit; i-- {
+		if a := s.loginAudits[i]; a.UserID == userID {
+			audit := *a
+			audits = append(audits, &audit)
+		}
+	}
+	return audits, nil
+}
+
+// ==================== 角色权限相关操作 ====================
+
+// 获取用户的系统角色
+func (s *MemoryStorage) GetUserRoles(userID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var roles []string
+	for role := range s.userRoles[userID] {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+// 授予用户系统角色，已有该角色时忽略
+func (s *MemoryStorage) AddUserRole(userID, role string, createdAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userRoles[userID] == nil {
+		s.userRoles[userID] = make(map[string]int64)
+	}
+	if _, ok := s.userRoles[userID][role]; !ok {
+		s.userRoles[userID][role] = createdAt
+	}
+	return nil
+}
+
+// 收回用户系统角色
+func (s *MemoryStorage) RemoveUserRole(userID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.userRoles[userID], role)
+	return nil
+}
+
+// 获取全部角色权限，返回 角色 -> 操作列表
+func (s *MemoryStorage) GetRolePermissions() (map[string][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	perms := make(map[string][]string, len(s.permissions))
+	for role, actions := range s.permissions {
+		perms[role] = append([]string(nil), actions...)
+	}
+	return perms, nil
+}
+
+// 权限表为空时写入默认权限，已有数据时不做修改
+func (s *MemoryStorage) InitRolePermissions(perms map[string][]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.permissions) > 0 {
+		return nil
+	}
+	for role, actions := range perms {
+		s.permissions[role] = append([]string(nil), actions...)
+	}
+	return nil
+}
+
+// ==================== 群组相关操作 ====================
+
+// 添加群成员，已是成员时忽略，调用方需持有写锁
+func (s *MemoryStorage) addGroupMember(groupID int64, userID, role string) {
+	if s.members[groupID] == nil {
+		s.members[groupID] = make(map[string]*GroupMember)
+	}
+	if s.members[groupID][userID] != nil {
+		return
+	}
+	s.members[groupID][userID] = &GroupMember{ID: s.newID("group_members"), GroupID: groupID, UserID: userID,
+		Role: role, CreatedAt: time.Now()}
+}
+
+// 创建群，创建者为群主，返回群ID；groupID 为0时自增分配
+func (s *MemoryStorage) CreateGroup(groupID int64, name, ownerID string, memberIDs []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if groupID == 0 {
+		groupID = s.newID("chat_groups")
+		for s.groups[groupID] != nil {
+			groupID = s.newID("chat_groups")
+		}
+	} else if s.groups[groupID] != nil {
+		return 0, fmt.Errorf("群ID %d 已存在", groupID)
+	}
+	now := time.Now()
+	s.groups[groupID] = &Group{ID: groupID, Name: name, OwnerID: ownerID, CreatedAt: now, UpdatedAt: now}
+	s.addGroupMember(groupID, ownerID, GroupRoleOwner)
+	for _, userID := range memberIDs {
+		s.addGroupMember(groupID, userID, GroupRoleMember)
+	}
+	return groupID, nil
+}
+
+// 解散群，删除群及全部成员
+func (s *MemoryStorage) DeleteGroup(groupID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.members, groupID)
+	delete(s.groups, groupID)
+	return nil
+}
+
+// 获取群信息
+func (s *MemoryStorage) GetGroup(groupID int64) (*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g := s.groups[groupID]
+	if g == nil {
+		return nil, sql.ErrNoRows
+	}
+	group := *g
+	return &group, nil
+}
+
+// 修改群名称
+func (s *MemoryStorage) RenameGroup(groupID int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if g := s.groups[groupID]; g != nil {
+		g.Name = name
+		g.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// 获取用户加入的群
+func (s *MemoryStorage) GetUserGroups(userID string) ([]*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var groups []*Group
+	for groupID, members := range s.members {
+		if g := s.groups[groupID]; g != nil && members[userID] != nil {
+			group := *g
+			groups = append(groups, &group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups, nil
+}
+
+// 添加群成员，已是成员时忽略
+func (s *MemoryStorage) AddGroupMember(groupID int64, userID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addGroupMember(groupID, userID, role)
+	return nil
+}
+
+// 移除群成员
+func (s *MemoryStorage) RemoveGroupMember(groupID int64, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.members[groupID], userID)
+	return nil
+}
+
+// 获取群成员，不是成员时返回 sql.ErrNoRows
+func (s *MemoryStorage) GetGroupMember(groupID int64, userID string) (*GroupMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m := s.members[groupID][userID]
+	if m == nil {
+		return nil, sql.ErrNoRows
+	}
+	member := *m
+	return &member, nil
+}
+
+// 群成员排序时各角色的先后
+var groupRoleOrder = map[string]int{GroupRoleOwner: 0, GroupRoleAdmin: 1, GroupRoleMember: 2}
+
+// 获取全部群成员，群主、管理员在前
+func (s *MemoryStorage) GetGroupMembers(groupID int64) ([]*GroupMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var members []*GroupMember
+	for _, m := range s.members[groupID] {
+		member := *m
+		members = append(members, &member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		ri, rj := groupRoleOrder[members[i].Role], groupRoleOrder[members[j].Role]
+		if ri != rj {
+			return ri < rj
+		}
+		return members[i].ID < members[j].ID
+	})
+	return members, nil
+}
+
+// 设置群成员角色
+func (s *MemoryStorage) SetGroupMemberRole(groupID int64, userID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m := s.members[groupID][userID]; m != nil {
+		m.Role = role
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"im/config"
+)
+
+// 数据库迁移，按版本号从小到大依次执行
+// 每个迁移在一个事务中执行并记录到 schema_migrations 表；MySQL 的 DDL 会隐式提交，无法随事务回滚
+type Migration struct {
+	Version     int64
+	Description string
+	Up          func(tx *sql.Tx) error
+	Down        func(tx *sql.Tx) error // 为 nil 时不支持回滚
+	Destructive bool                   // 回滚会删除已有数据（如删除表），必须强制执行
+}
+
+// 迁移状态，AppliedAt 为0表示尚未执行
+type MigrationStatus struct {
+	Version     int64
+	Description string
+	AppliedAt   int64 // Unix秒
+	Unknown     bool  // 数据库中已执行，但当前程序中没有该迁移（通常是数据库被新版本程序迁移过）
+}
+
+// 依次执行SQL语句，用于编写迁移
+func execAll(stmts ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// 依次删除表，用于编写回滚
+func dropTables(tables ...string) func(tx *sql.Tx) error {
+	stmts := make([]string, len(tables))
+	for i, table := range tables {
+		stmts[i] = "DROP TABLE IF EXISTS " + table
+	}
+	return execAll(stmts...)
+}
+
+const createMigrationTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	description VARCHAR(255) NOT NULL,
+	applied_at BIGINT NOT NULL
+)`
+
+// 数据库迁移执行器
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	// 跨实例的迁移锁，查询返回1表示加锁成功；为空时依赖数据库自身的写锁
+	lockQuery   string
+	unlockQuery string
+}
+
+// 创建迁移执行器，migrations 需按版本号升序排列
+func newMigrator(db *sql.DB, migrations []Migration, lockQuery, unlockQuery string) *Migrator {
+	return &Migrator{db: db, migrations: migrations, lockQuery: lockQuery, unlockQuery: unlockQuery}
+}
+
+// 按数据库配置打开数据库并创建迁移执行器，不会自动执行迁移，用完后需调用 Close
+func OpenMigrator(c *config.DatabaseConfig) (*Migrator, error) {
+	switch c.Driver {
+	case config.DriverMySQL:
+		s, err := openMySQL(c.GetDSN())
+		if err != nil {
+			return nil, err
+		}
+		return s.migrator(), nil
+	case config.DriverSQLite:
+		s, err := openSQLite(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		return s.migrator(), nil
+	case config.DriverMemory:
+		return nil, fmt.Errorf("内存存储不需要迁移")
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", c.Driver)
+	}
+}
+
+// 关闭数据库连接
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// 获取迁移锁并确保 schema_migrations 表存在后执行 fn
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.lockQuery != "" {
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, m.lockQuery).Scan(&locked); err != nil {
+			return fmt.Errorf("获取迁移锁失败: %v", err)
+		}
+		if locked.Int64 != 1 {
+			return fmt.Errorf("等待迁移锁超时，可能有其他实例正在迁移")
+		}
+		defer conn.ExecContext(ctx, m.unlockQuery)
+	}
+
+	if _, err := conn.ExecContext(ctx, createMigrationTable); err != nil {
+		return fmt.Errorf("创建迁移记录表失败: %v", err)
+	}
+	return fn(conn)
+}
+
+// 读取已执行的迁移: 版本号 -> 迁移状态
+func appliedMigrations(conn *sql.Conn) (map[int64]*MigrationStatus, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version, description, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]*MigrationStatus)
+	for rows.Next() {
+		s := &MigrationStatus{}
+		if err := rows.Scan(&s.Version, &s.Description, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[s.Version] = s
+	}
+	return applied, rows.Err()
+}
+
+// 版本是否已执行，在迁移事务中检查，避免重复执行其他实例刚完成的迁移
+func isApplied(tx *sql.Tx, version int64) (bool, error) {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, version).Scan(&count)
+	return count > 0, err
+}
+
+// Up 执行全部未执行的迁移，返回本次执行的迁移
+func (m *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if applied[mig.Version] != nil {
+				continue
+			}
+			ok, err := m.apply(conn, mig)
+			if err != nil {
+				return fmt.Errorf("执行迁移 %d (%s) 失败: %v", mig.Version, mig.Description, err)
+			}
+			if ok {
+				log.Printf("已执行数据库迁移 %d: %s", mig.Version, mig.Description)
+				done = append(done, mig)
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// 在事务中执行一个迁移并记录版本，已被其他实例执行时返回 false
+func (m *Migrator) apply(conn *sql.Conn, mig Migration) (bool, error) {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := isApplied(tx, mig.Version); err != nil || ok {
+		return false, err
+	}
+	if err := mig.Up(tx); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+		mig.Version, mig.Description, time.Now().Unix()); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Down 按版本号从大到小回滚最近执行的 steps 个迁移，返回本次回滚的迁移
+// 要回滚的迁移中有会删除数据的迁移时，force 为 false 则一个都不回滚
+func (m *Migrator) Down(steps int, force bool) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		var targets []Migration
+		for i := len(m.migrations) - 1; i >= 0 && len(targets) < steps; i-- {
+			if mig := m.migrations[i]; applied[mig.Version] != nil {
+				if mig.Destructive && !force {
+					return fmt.Errorf("回滚迁移 %d (%s) 会删除全部数据，确认后需强制执行", mig.Version, mig.Description)
+				}
+				targets = append(targets, mig)
+			}
+		}
+		for _, mig := range targets {
+			if err := m.revert(conn, mig); err != nil {
+				return fmt.Errorf("回滚迁移 %d (%s) 失败: %v", mig.Version, mig.Description, err)
+			}
+			log.Printf("已回滚数据库迁移 %d: %s", mig.Version, mig.Description)
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// 在事务中回滚一个迁移并删除版本记录
+func (m *Migrator) revert(conn *sql.Conn, mig Migration) error {
+	if mig.Down == nil {
+		return fmt.Errorf("该迁移不支持回滚")
+	}
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if ok, err := isApplied(tx, mig.Version); err != nil || !ok {
+		return err
+	}
+	if err := mig.Down(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, mig.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Status 返回全部迁移的执行状态，按版本号升序
+func (m *Migrator) Status() ([]*MigrationStatus, error) {
+	var list []*MigrationStatus
+	err := m.withLock(func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		known := make(map[int64]bool)
+		for _, mig := range m.migrations {
+			known[mig.Version] = true
+			s := &MigrationStatus{Version: mig.Version, Description: mig.Description}
+			if a := applied[mig.Version]; a != nil {
+				s.AppliedAt = a.AppliedAt
+			}
+			list = append(list, s)
+		}
+		for version, a := range applied {
+			if !known[version] {
+				a.Unknown = true
+				list = append(list, a)
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, err
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openTestSQLite(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	s, err := openSQLite(filepath.Join(t.TempDir(), "im.db"))
+	if err != nil {
+		t.Fatalf("打开SQLite数据库失败: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// 已执行的迁移版本，按版本号升序
+func appliedVersions(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	list, err := m.Status()
+	if err != nil {
+		t.Fatalf("查询迁移状态失败: %v", err)
+	}
+	var versions []int64
+	for _, s := range list {
+		if s.AppliedAt > 0 {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+func equalVersions(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	s := openTestSQLite(t)
+	m := s.migrator()
+
+	done, err := m.Up()
+	if err != nil || len(done) != len(sqliteMigrations) {
+		t.Fatalf("首次迁移执行了 %d 个, %v，期望 %d 个", len(done), err, len(sqliteMigrations))
+	}
+	if done, err := m.Up(); err != nil || len(done) != 0 {
+		t.Fatalf("重复迁移执行了 %d 个, %v，期望 0 个", len(done), err)
+	}
+	if got := appliedVersions(t, m); !equalVersions(got, []int64{1, 2}) {
+		t.Fatalf("已执行的迁移 %v，期望 [1 2]", got)
+	}
+
+	steps := []struct {
+		name    string
+		steps   int
+		force   bool
+		wantErr bool
+		want    []int64
+	}{
+		{"回滚非破坏性迁移", 1, false, false, []int64{1}},
+		{"不加force回滚初始迁移", 1, false, true, []int64{1}},
+		{"force回滚初始迁移", 1, true, false, nil},
+		{"没有可回滚的迁移", 1, false, false, nil},
+	}
+	for _, st := range steps {
+		_, err := m.Down(st.steps, st.force)
+		if (err != nil) != st.wantErr {
+			t.Fatalf("%s: Down 返回 %v", st.name, err)
+		}
+		if got := appliedVersions(t, m); !equalVersions(got, st.want) {
+			t.Fatalf("%s: 已执行的迁移 %v，期望 %v", st.name, got, st.want)
+		}
+	}
+
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'users'`).Scan(&count); err != nil || count != 0 {
+		t.Errorf("回滚初始迁移后 users 表仍存在: %d, %v", count, err)
+	}
+	if done, err := m.Up(); err != nil || len(done) != len(sqliteMigrations) {
+		t.Fatalf("回滚后重新迁移执行了 %d 个, %v", len(done), err)
+	}
+}
+
+func TestMigratorDownRefusesDestructiveBatch(t *testing.T) {
+	s := openTestSQLite(t)
+	m := s.migrator()
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	// 一次回滚多个迁移时，只要其中有破坏性迁移就全部不回滚
+	if _, err := m.Down(2, false); err == nil {
+		t.Fatal("回滚包含初始迁移时应要求 force")
+	}
+	if got := appliedVersions(t, m); !equalVersions(got, []int64{1, 2}) {
+		t.Errorf("被拒绝的回滚不应执行任何迁移，已执行 %v", got)
+	}
+}
+
+func TestMigratorStatusUnknownAndIrreversible(t *testing.T) {
+	s := openTestSQLite(t)
+	migrations := []Migration{
+		{Version: 1, Description: "create t", Up: execAll(`CREATE TABLE t (id INTEGER)`)},
+	}
+	m := newMigrator(s.db, migrations, "", "")
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(1, true); err == nil {
+		t.Error("没有 Down 的迁移应不支持回滚")
+	}
+
+	// 数据库中存在程序不认识的迁移（被新版本程序迁移过）
+	if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (99, 'future', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	list, err := m.Status()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("Status = %+v, %v", list, err)
+	}
+	if list[0].Version != 1 || list[0].AppliedAt == 0 || list[0].Unknown {
+		t.Errorf("迁移1状态 %+v", list[0])
+	}
+	if list[1].Version != 99 || !list[1].Unknown {
+		t.Errorf("迁移99状态 %+v，期望标记为 Unknown", list[1])
+	}
+}
+
+func TestSequenceMigrationSeedsFromExistingData(t *testing.T) {
+	s := openTestSQLite(t)
+	m := s.migrator()
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(1, false); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟序列表上线前已有的数据，非数字和过长的UID不参与计算
+	for _, stmt := range []string{
+		`INSERT INTO users (uid, username, password, email) VALUES
+			('7', 'a', 'x', 'a@x'), ('alice', 'b', 'x', 'b@x'), ('1234567890123456789012', 'c', 'x', 'c@x')`,
+		`INSERT INTO messages (id, from_user_id, to_user_id, type, content, timestamp) VALUES (30, '7', 'alice', 'chat', 'hi', 1)`,
+		`INSERT INTO chat_groups (id, name, owner_id) VALUES (5, 'g', '7')`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		want int64
+	}{{SequenceUser, 8}, {SequenceMessage, 31}, {SequenceGroup, 6}}
+	for _, tt := range tests {
+		got, err := s.NextSequence(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("NextSequence(%s) = %d, %v，期望 %d", tt.name, got, err, tt.want)
+		}
+	}
+	if got, err := s.NextSequence("unseeded"); err != nil || got != 1 {
+		t.Errorf("未初始化的序列 = %d, %v，期望从1开始", got, err)
+	}
+}
@@ -3,54 +3,35 @@ package storage
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
-	"time"
+	"unicode/utf8"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 // MySQL存储实现
 type MySQLStorage struct {
-	db *sql.DB
+	sqlStorage
 }
 
-// 用户信息表结构
-type User struct {
-	ID        int64     `db:"id"`
-	UID       string    `db:"uid"`
-	Username  string    `db:"username"`
-	Password  string    `db:"password"`
-	Email     string    `db:"email"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
-}
-
-// 好友关系表结构
-type Friendship struct {
-	ID        int64     `db:"id"`
-	UserID    string    `db:"user_id"`
-	FriendID  string    `db:"friend_id"`
-	Remark    string    `db:"remark"`
-	DND       bool      `db:"dnd"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
-}
+var _ Store = (*MySQLStorage)(nil)
 
-// 好友请求表结构
-type FriendRequest struct {
-	ID         int64     `db:"id"`
-	FromUserID string    `db:"from_user_id"`
-	ToUserID   string    `db:"to_user_id"`
-	VerifyMsg  string    `db:"verify_msg"`
-	Status     string    `db:"status"` // pending, accepted, rejected
-	CreatedAt  time.Time `db:"created_at"`
-	UpdatedAt  time.Time `db:"updated_at"`
+// 创建MySQL存储实例，并执行未执行的数据库迁移
+func NewMySQLStorage(dsn string) (*MySQLStorage, error) {
+	storage, err := openMySQL(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := storage.migrator().Up(); err != nil {
+		storage.Close()
+		return nil, fmt.Errorf("数据库迁移失败: %v", err)
+	}
+	return storage, nil
 }
 
-// 创建MySQL存储实例
-func NewMySQLStorage(dsn string) (*MySQLStorage, error) {
+// 连接MySQL数据库，数据库不存在时自动创建
+func openMySQL(dsn string) (*MySQLStorage, error) {
 	// 首先尝试连接MySQL服务器（不指定数据库）
 	// 从DSN中提取数据库名
 	dbConfig := extractDBConfigFromDSN(dsn)
@@ -87,14 +68,7 @@ func NewMySQLStorage(dsn string) (*MySQLStorage, error) {
 		return nil, fmt.Errorf("MySQL数据库连接测试失败: %v", err)
 	}
 
-	storage := &MySQLStorage{db: db}
-
-	// 初始化数据库表
-	if err := storage.initTables(); err != nil {
-		return nil, fmt.Errorf("初始化数据库表失败: %v", err)
-	}
-
-	return storage, nil
+	return &MySQLStorage{sqlStorage{db: db, insertIgnore: "INSERT IGNORE", forUpdate: " FOR UPDATE"}}, nil
 }
 
 // 从DSN中提取数据库配置
@@ -161,11 +135,10 @@ func extractDBConfigFromDSN(dsn string) dbConfig {
 	return config
 }
 
-// 初始化数据库表
-func (m *MySQLStorage) initTables() error {
+// 初始表结构，使用 IF NOT EXISTS 以便接管迁移功能上线前创建的数据库
+var mysqlSchema = []string{
 	// 用户表
-	userTable := `
-	CREATE TABLE IF NOT EXISTS users (
+	`CREATE TABLE IF NOT EXISTS users (
 		id BIGINT AUTO_INCREMENT PRIMARY KEY,
 		uid VARCHAR(64) UNIQUE NOT NULL,
 		username VARCHAR(64) NOT NULL,
@@ -175,12 +148,9 @@ func (m *MySQLStorage) initTables() error {
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 		INDEX idx_uid (uid),
 		INDEX idx_email (email)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
-	`
-
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
 	// 好友关系表
-	friendshipTable := `
-	CREATE TABLE IF NOT EXISTS friendships (
+	`CREATE TABLE IF NOT EXISTS friendships (
 		id BIGINT AUTO_INCREMENT PRIMARY KEY,
 		user_id VARCHAR(64) NOT NULL,
 		friend_id VARCHAR(64) NOT NULL,
@@ -191,12 +161,9 @@ func (m *MySQLStorage) initTables() error {
 		UNIQUE KEY unique_friendship (user_id, friend_id),
 		INDEX idx_user_id (user_id),
 		INDEX idx_friend_id (friend_id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
-	`
-
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
 	// 好友请求表
-	friendRequestTable := `
-	CREATE TABLE IF NOT EXISTS friend_requests (
+	`CREATE TABLE IF NOT EXISTS friend_requests (
 		id BIGINT AUTO_INCREMENT PRIMARY KEY,
 		from_user_id VARCHAR(64) NOT NULL,
 		to_user_id VARCHAR(64) NOT NULL,
@@ -208,211 +175,335 @@ func (m *MySQLStorage) initTables() error {
 		INDEX idx_from_user_id (from_user_id),
 		INDEX idx_to_user_id (to_user_id),
 		INDEX idx_status (status)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
-	`
-
-	tables := []string{userTable, friendshipTable, friendRequestTable}
-
-	for _, table := range tables {
-		if _, err := m.db.Exec(table); err != nil {
-			return fmt.Errorf("创建表失败: %v", err)
-		}
-	}
-
-	log.Println("MySQL数据库表初始化完成")
-	return nil
-}
-
-// 关闭数据库连接
-func (m *MySQLStorage) Close() error {
-	return m.db.Close()
-}
-
-// ==================== 用户相关操作 ====================
-
-// 创建用户
-func (m *MySQLStorage) CreateUser(uid, username, password, email string) error {
-	query := `INSERT INTO users (uid, username, password, email) VALUES (?, ?, ?, ?)`
-	_, err := m.db.Exec(query, uid, username, password, email)
-	return err
-}
-
-// 根据UID获取用户
-func (m *MySQLStorage) GetUserByUID(uid string) (*User, error) {
-	query := `SELECT id, uid, username, password, email, created_at, updated_at FROM users WHERE uid = ?`
-	user := &User{}
-	err := m.db.QueryRow(query, uid).Scan(&user.ID, &user.UID, &user.Username, &user.Password, &user.Email, &user.CreatedAt, &user.UpdatedAt)
-	if err != nil {
-		return nil, err
-	}
-	return user, nil
-}
-
-// 根据邮箱获取用户
-func (m *MySQLStorage) GetUserByEmail(email string) (*User, error) {
-	query := `SELECT id, uid, username, password, email, created_at, updated_at FROM users WHERE email = ?`
-	user := &User{}
-	err := m.db.QueryRow(query, email).Scan(&user.ID, &user.UID, &user.Username, &user.Password, &user.Email, &user.CreatedAt, &user.UpdatedAt)
-	if err != nil {
-		return nil, err
-	}
-	return user, nil
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 聊天消息表
+	`CREATE TABLE IF NOT EXISTS messages (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		from_user_id VARCHAR(64) NOT NULL,
+		to_user_id VARCHAR(64) NOT NULL DEFAULT '',
+		group_id BIGINT NOT NULL DEFAULT 0,
+		type VARCHAR(32) NOT NULL,
+		content TEXT NOT NULL,
+		extra VARCHAR(512) DEFAULT '',
+		filename VARCHAR(255) DEFAULT '',
+		filesize BIGINT DEFAULT 0,
+		mime_type VARCHAR(128) DEFAULT '',
+		timestamp BIGINT NOT NULL,
+		recalled BOOLEAN NOT NULL DEFAULT FALSE,
+		edited_at BIGINT NOT NULL DEFAULT 0,
+		reply_to_id BIGINT NOT NULL DEFAULT 0,
+		forward_from_id BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_from_to (from_user_id, to_user_id),
+		INDEX idx_to_from (to_user_id, from_user_id),
+		INDEX idx_group_id (group_id),
+		FULLTEXT INDEX ft_content (content, extra) WITH PARSER ngram
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 离线消息表（记录尚未送达的消息）
+	`CREATE TABLE IF NOT EXISTS offline_messages (
+		user_id VARCHAR(64) NOT NULL,
+		message_id BIGINT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, message_id),
+		INDEX idx_message_id (message_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 群组表（groups 是 MySQL 8 保留字，使用 chat_groups）
+	`CREATE TABLE IF NOT EXISTS chat_groups (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		name VARCHAR(64) NOT NULL,
+		owner_id VARCHAR(64) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_owner_id (owner_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 群成员表
+	`CREATE TABLE IF NOT EXISTS group_members (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		group_id BIGINT NOT NULL,
+		user_id VARCHAR(64) NOT NULL,
+		role ENUM('owner', 'admin', 'member') DEFAULT 'member',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE KEY unique_member (group_id, user_id),
+		INDEX idx_user_id (user_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 已读游标表，单聊 peer_id 为好友UID、group_id 为0，群聊 peer_id 为空
+	`CREATE TABLE IF NOT EXISTS read_cursors (
+		user_id VARCHAR(64) NOT NULL,
+		peer_id VARCHAR(64) NOT NULL DEFAULT '',
+		group_id BIGINT NOT NULL DEFAULT 0,
+		last_read_id BIGINT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, peer_id, group_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 用户最后在线时间表
+	`CREATE TABLE IF NOT EXISTS user_presence (
+		user_id VARCHAR(64) PRIMARY KEY,
+		last_seen BIGINT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 消息回应表，每个用户对同一消息的同一表情只记录一次
+	`CREATE TABLE IF NOT EXISTS message_reactions (
+		message_id BIGINT NOT NULL,
+		user_id VARCHAR(64) NOT NULL,
+		emoji VARCHAR(32) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (message_id, user_id, emoji)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin`,
+	// 刷新令牌表，只保存令牌的SHA-256哈希，同一次登录轮换出的令牌属于同一 family
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+		token_hash CHAR(64) PRIMARY KEY,
+		user_id VARCHAR(64) NOT NULL,
+		family_id VARCHAR(64) NOT NULL,
+		expires_at BIGINT NOT NULL,
+		revoked BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_user_id (user_id),
+		INDEX idx_family_id (family_id),
+		INDEX idx_expires_at (expires_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 访问令牌吊销表，记录在过期前需要拒绝的令牌、登录会话或用户
+	`CREATE TABLE IF NOT EXISTS revoked_tokens (
+		token_id VARCHAR(128) PRIMARY KEY,
+		user_id VARCHAR(64) NOT NULL,
+		revoked_at BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL,
+		INDEX idx_expires_at (expires_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 邮箱验证码表，每次发送记录一行，同时用于按邮箱和IP限制发送频率
+	`CREATE TABLE IF NOT EXISTS email_codes (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		email VARCHAR(128) NOT NULL,
+		purpose VARCHAR(32) NOT NULL,
+		code_hash CHAR(64) NOT NULL,
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		attempts INT NOT NULL DEFAULT 0,
+		used BOOLEAN NOT NULL DEFAULT FALSE,
+		expires_at BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		INDEX idx_email_purpose (email, purpose),
+		INDEX idx_email_created (email, created_at),
+		INDEX idx_ip_created (ip, created_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 两步验证表，enabled 为 FALSE 时表示已生成密钥但尚未确认
+	`CREATE TABLE IF NOT EXISTS user_totp (
+		user_id VARCHAR(64) PRIMARY KEY,
+		secret VARCHAR(64) NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		last_step BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 两步验证恢复码表，只保存哈希，每个恢复码只能使用一次
+	`CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+		user_id VARCHAR(64) NOT NULL,
+		code_hash CHAR(64) NOT NULL,
+		used BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, code_hash)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 登录审计表，每次登录尝试记录一行，同时用于统计失败次数
+	`CREATE TABLE IF NOT EXISTS login_audit (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id VARCHAR(64) NOT NULL,
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		result VARCHAR(16) NOT NULL,
+		reason VARCHAR(64) NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL,
+		INDEX idx_user_created (user_id, created_at),
+		INDEX idx_ip_created (ip, created_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 用户系统角色表，普通用户不需要记录
+	`CREATE TABLE IF NOT EXISTS user_roles (
+		user_id VARCHAR(64) NOT NULL,
+		role VARCHAR(32) NOT NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (user_id, role)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	// 角色权限表，action 支持 group.* 和 * 通配
+	`CREATE TABLE IF NOT EXISTS role_permissions (
+		role VARCHAR(32) NOT NULL,
+		action VARCHAR(64) NOT NULL,
+		PRIMARY KEY (role, action)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
 }
 
-// 更新用户昵称
-func (m *MySQLStorage) UpdateUsername(uid, newUsername string) error {
-	query := `UPDATE users SET username = ? WHERE uid = ?`
-	_, err := m.db.Exec(query, newUsername, uid)
-	return err
+// MySQL 数据库迁移，新增的表结构变更追加到末尾，已发布的迁移不能再修改
+var mysqlMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "创建初始表结构",
+		Up:          execAll(mysqlSchema...),
+		Down: dropTables("role_permissions", "user_roles", "login_audit", "totp_recovery_codes", "user_totp",
+			"email_codes", "revoked_tokens", "refresh_tokens", "message_reactions", "user_presence", "read_cursors",
+			"group_members", "chat_groups", "offline_messages", "messages", "friend_requests", "friendships", "users"),
+		Destructive: true,
+	},
+	{
+		Version:     2,
+		Description: "为旧版本创建的表补齐新增的列和索引",
+		Up: func(tx *sql.Tx) error {
+			if err := addMissingColumns(tx); err != nil {
+				return err
+			}
+			return addMissingIndexes(tx)
+		},
+		// 这些列和索引已包含在初始表结构中，回滚初始迁移时一并删除
+		Down: func(tx *sql.Tx) error { return nil },
+	},
+	{
+		Version:     3,
+		Description: "创建ID序列表，并从已有数据的最大ID开始",
+		Up: execAll(
+			`CREATE TABLE IF NOT EXISTS id_sequences (
+				name VARCHAR(32) PRIMARY KEY,
+				value BIGINT NOT NULL
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			// 只有纯数字且不超过18位的UID才可能与序列分配的UID冲突
+			`INSERT IGNORE INTO id_sequences (name, value)
+				SELECT '`+SequenceUser+`', COALESCE(MAX(CAST(uid AS UNSIGNED)), 0) FROM users
+				WHERE uid REGEXP '^[0-9]{1,18}$'`,
+			`INSERT IGNORE INTO id_sequences (name, value)
+				SELECT '`+SequenceMessage+`', COALESCE(MAX(id), 0) FROM messages`,
+			`INSERT IGNORE INTO id_sequences (name, value)
+				SELECT '`+SequenceGroup+`', COALESCE(MAX(id), 0) FROM chat_groups`,
+		),
+		Down: dropTables("id_sequences"),
+	},
 }
 
-// 更新用户密码
-func (m *MySQLStorage) UpdatePassword(uid, newPassword string) error {
-	query := `UPDATE users SET password = ? WHERE uid = ?`
-	_, err := m.db.Exec(query, newPassword, uid)
-	return err
-}
+// 迁移锁的名称，MySQL 的命名锁在整个服务器范围内有效，需要带上数据库名
+const mysqlMigrationLock = "CONCAT('im_migrate.', DATABASE())"
 
-// 删除用户
-func (m *MySQLStorage) DeleteUser(uid string) error {
-	query := `DELETE FROM users WHERE uid = ?`
-	_, err := m.db.Exec(query, uid)
-	return err
+// 创建迁移执行器，使用 GET_LOCK 保证多个实例不会同时迁移
+func (m *MySQLStorage) migrator() *Migrator {
+	return newMigrator(m.db, mysqlMigrations,
+		"SELECT GET_LOCK("+mysqlMigrationLock+", 60)", "SELECT RELEASE_LOCK("+mysqlMigrationLock+")")
 }
 
-// ==================== 好友关系相关操作 ====================
-
-// 添加好友关系
-func (m *MySQLStorage) AddFriendship(userID, friendID string) error {
-	// 添加双向好友关系
-	query := `INSERT INTO friendships (user_id, friend_id) VALUES (?, ?), (?, ?)`
-	_, err := m.db.Exec(query, userID, friendID, friendID, userID)
-	return err
+// 迁移功能上线前新增的列，表已存在时 CREATE TABLE IF NOT EXISTS 不会补齐
+var addedColumns = []struct {
+	table, column, definition string
+}{
+	{"messages", "group_id", "BIGINT NOT NULL DEFAULT 0"},
+	{"messages", "recalled", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"messages", "edited_at", "BIGINT NOT NULL DEFAULT 0"},
+	{"messages", "reply_to_id", "BIGINT NOT NULL DEFAULT 0"},
+	{"messages", "forward_from_id", "BIGINT NOT NULL DEFAULT 0"},
 }
 
-// 删除好友关系
-func (m *MySQLStorage) DeleteFriendship(userID, friendID string) error {
-	query := `DELETE FROM friendships WHERE (user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)`
-	_, err := m.db.Exec(query, userID, friendID, friendID, userID)
-	return err
+// 迁移功能上线前新增的索引
+var addedIndexes = []struct {
+	table, index, definition string
+}{
+	{"messages", "idx_group_id", "INDEX idx_group_id (group_id)"},
+	{"messages", "ft_content", "FULLTEXT INDEX ft_content (content, extra) WITH PARSER ngram"},
 }
 
-// 获取好友列表
-func (m *MySQLStorage) GetFriends(userID string) ([]string, error) {
-	query := `SELECT friend_id FROM friendships WHERE user_id = ?`
-	rows, err := m.db.Query(query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var friends []string
-	for rows.Next() {
-		var friendID string
-		if err := rows.Scan(&friendID); err != nil {
-			return nil, err
+// 为旧版本创建的表补齐新增的索引
+func addMissingIndexes(tx *sql.Tx) error {
+	for _, idx := range addedIndexes {
+		var count int
+		query := `SELECT COUNT(*) FROM information_schema.STATISTICS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`
+		if err := tx.QueryRow(query, idx.table, idx.index).Scan(&count); err != nil {
+			return fmt.Errorf("检查表 %s 的索引 %s 失败: %v", idx.table, idx.index, err)
+		}
+		if count > 0 {
+			continue
+		}
+		alter := fmt.Sprintf("ALTER TABLE `%s` ADD %s", idx.table, idx.definition)
+		if _, err := tx.Exec(alter); err != nil {
+			return fmt.Errorf("为表 %s 添加索引 %s 失败: %v", idx.table, idx.index, err)
 		}
-		friends = append(friends, friendID)
 	}
-	return friends, nil
+	return nil
 }
 
-// 检查是否为好友
-func (m *MySQLStorage) IsFriend(userID, friendID string) (bool, error) {
-	query := `SELECT COUNT(*) FROM friendships WHERE user_id = ? AND friend_id = ?`
-	var count int
-	err := m.db.QueryRow(query, userID, friendID).Scan(&count)
-	if err != nil {
-		return false, err
+// 为旧版本创建的表补齐新增的列
+func addMissingColumns(tx *sql.Tx) error {
+	for _, c := range addedColumns {
+		var count int
+		query := `SELECT COUNT(*) FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+		if err := tx.QueryRow(query, c.table, c.column).Scan(&count); err != nil {
+			return fmt.Errorf("检查表 %s 的列 %s 失败: %v", c.table, c.column, err)
+		}
+		if count > 0 {
+			continue
+		}
+		alter := fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", c.table, c.column, c.definition)
+		if _, err := tx.Exec(alter); err != nil {
+			return fmt.Errorf("为表 %s 添加列 %s 失败: %v", c.table, c.column, err)
+		}
 	}
-	return count > 0, nil
+	return nil
 }
 
-// ==================== 好友请求相关操作 ====================
-
-// 添加好友请求
-func (m *MySQLStorage) AddFriendRequest(fromUserID, toUserID, verifyMsg string) error {
-	query := `INSERT INTO friend_requests (from_user_id, to_user_id, verify_msg) VALUES (?, ?, ?)`
-	_, err := m.db.Exec(query, fromUserID, toUserID, verifyMsg)
-	return err
-}
+// ==================== 聊天消息相关操作 ====================
 
-// 获取收到的好友请求
-func (m *MySQLStorage) GetFriendRequests(toUserID string) (map[string]string, error) {
-	query := `SELECT from_user_id, verify_msg FROM friend_requests WHERE to_user_id = ? AND status = 'pending'`
-	rows, err := m.db.Query(query, toUserID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// ngram 分词的最小长度，更短的关键词无法使用全文索引
+const ngramTokenSize = 2
 
-	requests := make(map[string]string)
-	for rows.Next() {
-		var fromUserID, verifyMsg string
-		if err := rows.Scan(&fromUserID, &verifyMsg); err != nil {
-			return nil, err
-		}
-		requests[fromUserID] = verifyMsg
+// 按关键词搜索用户可见的消息，按消息ID倒序，返回本页结果与总数
+// 关键词使用 ngram 全文索引做短语匹配，短于分词长度时退化为 LIKE
+func (m *MySQLStorage) SearchMessages(q *SearchQuery) ([]*Message, int, error) {
+	if utf8.RuneCountInString(q.Keyword) >= ngramTokenSize {
+		// 布尔模式下用双引号做短语匹配，去掉关键词中的双引号避免破坏语法
+		phrase := `"` + strings.ReplaceAll(q.Keyword, `"`, " ") + `"`
+		return m.searchMessages(q, "MATCH(content, extra) AGAINST (? IN BOOLEAN MODE)", phrase)
 	}
-	return requests, nil
+	pattern := "%" + escapeLike(q.Keyword) + "%"
+	return m.searchMessages(q, "(content LIKE ? OR extra LIKE ?)", pattern, pattern)
 }
 
-// 处理好友请求
-func (m *MySQLStorage) HandleFriendRequest(fromUserID, toUserID string, accept bool) error {
-	status := "rejected"
-	if accept {
-		status = "accepted"
-	}
+// ==================== 已读相关操作 ====================
 
-	// 更新请求状态
-	query := `UPDATE friend_requests SET status = ? WHERE from_user_id = ? AND to_user_id = ?`
-	_, err := m.db.Exec(query, status, fromUserID, toUserID)
+// 将会话已读位置推进到 msgID，只前进不后退，返回已读位置是否有变化
+// 单聊传 peerID，群聊传 groupID
+func (m *MySQLStorage) UpdateReadCursor(userID, peerID string, groupID, msgID int64) (bool, error) {
+	if groupID != 0 {
+		peerID = ""
+	}
+	query := `INSERT INTO read_cursors (user_id, peer_id, group_id, last_read_id) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE last_read_id = GREATEST(last_read_id, VALUES(last_read_id))`
+	result, err := m.db.Exec(query, userID, peerID, groupID, msgID)
 	if err != nil {
-		return err
+		return false, err
 	}
-
-	// 如果接受，添加好友关系
-	if accept {
-		return m.AddFriendship(fromUserID, toUserID)
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
 	}
-
-	return nil
+	// 插入返回1，更新返回2，值未变化返回0
+	return rows > 0, nil
 }
 
-// ==================== 好友备注和免打扰相关操作 ====================
+// ==================== 在线状态相关操作 ====================
 
-// 设置好友备注
-func (m *MySQLStorage) SetFriendRemark(userID, friendID, remark string) error {
-	query := `UPDATE friendships SET remark = ? WHERE user_id = ? AND friend_id = ?`
-	_, err := m.db.Exec(query, remark, userID, friendID)
+// 记录用户最后在线时间（Unix秒）
+func (m *MySQLStorage) SetLastSeen(userID string, lastSeen int64) error {
+	query := `INSERT INTO user_presence (user_id, last_seen) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE last_seen = VALUES(last_seen)`
+	_, err := m.db.Exec(query, userID, lastSeen)
 	return err
 }
 
-// 获取好友备注
-func (m *MySQLStorage) GetFriendRemark(userID, friendID string) (string, error) {
-	query := `SELECT remark FROM friendships WHERE user_id = ? AND friend_id = ?`
-	var remark string
-	err := m.db.QueryRow(query, userID, friendID).Scan(&remark)
-	if err != nil {
-		return "", err
-	}
-	return remark, nil
-}
+// ==================== 令牌相关操作 ====================
 
-// 设置免打扰
-func (m *MySQLStorage) SetFriendDND(userID, friendID string, dnd bool) error {
-	query := `UPDATE friendships SET dnd = ? WHERE user_id = ? AND friend_id = ?`
-	_, err := m.db.Exec(query, dnd, userID, friendID)
+// 添加吊销记录，重复吊销时更新时间
+func (m *MySQLStorage) AddRevokedToken(t *RevokedToken) error {
+	query := `INSERT INTO revoked_tokens (token_id, user_id, revoked_at, expires_at) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE revoked_at = VALUES(revoked_at), expires_at = VALUES(expires_at)`
+	_, err := m.db.Exec(query, t.TokenID, t.UserID, t.RevokedAt, t.ExpiresAt)
 	return err
 }
 
-// 获取免打扰状态
-func (m *MySQLStorage) GetFriendDND(userID, friendID string) (bool, error) {
-	query := `SELECT dnd FROM friendships WHERE user_id = ? AND friend_id = ?`
-	var dnd bool
-	err := m.db.QueryRow(query, userID, friendID).Scan(&dnd)
-	if err != nil {
-		return false, err
-	}
-	return dnd, nil
+// ==================== 两步验证相关操作 ====================
+
+// 保存待确认的两步验证密钥，覆盖之前未确认的密钥
+func (m *MySQLStorage) SaveTOTPSecret(userID, secret string) error {
+	query := `INSERT INTO user_totp (user_id, secret, enabled, last_step) VALUES (?, ?, FALSE, 0)
+		ON DUPLICATE KEY UPDATE secret = VALUES(secret), enabled = FALSE, last_step = 0`
+	_, err := m.db.Exec(query, userID, secret)
+	return err
 }
@@ -0,0 +1,1170 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// 基于 database/sql 的通用存储实现，MySQL 和 SQLite 存储共用
+// 各数据库语法不同的操作由具体实现提供
+type sqlStorage struct {
+	db *sql.DB
+
+	insertIgnore string // 忽略唯一键冲突的插入语句，MySQL 为 INSERT IGNORE，SQLite 为 INSERT OR IGNORE
+	forUpdate    string // 事务内锁定读取的后缀，SQLite 的写事务本身是串行的，不需要
+}
+
+// 关闭数据库连接
+func (s *sqlStorage) Close() error {
+	return s.db.Close()
+}
+
+// ==================== ID序列相关操作 ====================
+
+// 获取命名序列的下一个值，从1开始递增
+// 自增在事务中完成，并发调用时由行锁保证各实例拿到的值不重复
+func (s *sqlStorage) NextSequence(name string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(s.insertIgnore+` INTO id_sequences (name, value) VALUES (?, 0)`, name); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE id_sequences SET value = value + 1 WHERE name = ?`, name); err != nil {
+		return 0, err
+	}
+	var value int64
+	if err := tx.QueryRow(`SELECT value FROM id_sequences WHERE name = ?`, name).Scan(&value); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// 调用方指定的ID，为0时写入 NULL 由数据库自增分配
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// ==================== 用户相关操作 ====================
+
+// 创建用户
+func (s *sqlStorage) CreateUser(uid, username, password, email string) error {
+	query := `INSERT INTO users (uid, username, password, email) VALUES (?, ?, ?, ?)`
+	_, err := s.db.Exec(query, uid, username, password, email)
+	return err
+}
+
+// 根据UID获取用户
+func (s *sqlStorage) GetUserByUID(uid string) (*User, error) {
+	query := `SELECT id, uid, username, password, email, created_at, updated_at FROM users WHERE uid = ?`
+	user := &User{}
+	err := s.db.QueryRow(query, uid).Scan(&user.ID, &user.UID, &user.Username, &user.Password, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// 根据邮箱获取用户
+func (s *sqlStorage) GetUserByEmail(email string) (*User, error) {
+	query := `SELECT id, uid, username, password, email, created_at, updated_at FROM users WHERE email = ?`
+	user := &User{}
+	err := s.db.QueryRow(query, email).Scan(&user.ID, &user.UID, &user.Username, &user.Password, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// 更新用户昵称
+func (s *sqlStorage) UpdateUsername(uid, newUsername string) error {
+	query := `UPDATE users SET username = ? WHERE uid = ?`
+	_, err := s.db.Exec(query, newUsername, uid)
+	return err
+}
+
+// 更新用户密码
+func (s *sqlStorage) UpdatePassword(uid, newPassword string) error {
+	query := `UPDATE users SET password = ? WHERE uid = ?`
+	_, err := s.db.Exec(query, newPassword, uid)
+	return err
+}
+
+// 更新用户邮箱
+func (s *sqlStorage) UpdateEmail(uid, newEmail string) error {
+	query := `UPDATE users SET email = ? WHERE uid = ?`
+	_, err := s.db.Exec(query, newEmail, uid)
+	return err
+}
+
+// 删除用户
+func (s *sqlStorage) DeleteUser(uid string) error {
+	query := `DELETE FROM users WHERE uid = ?`
+	_, err := s.db.Exec(query, uid)
+	return err
+}
+
+// ==================== 好友关系相关操作 ====================
+
+// 添加好友关系
+func (s *sqlStorage) AddFriendship(userID, friendID string) error {
+	// 添加双向好友关系
+	query := `INSERT INTO friendships (user_id, friend_id) VALUES (?, ?), (?, ?)`
+	_, err := s.db.Exec(query, userID, friendID, friendID, userID)
+	return err
+}
+
+// 删除好友关系
+func (s *sqlStorage) DeleteFriendship(userID, friendID string) error {
+	query := `DELETE FROM friendships WHERE (user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)`
+	_, err := s.db.Exec(query, userID, friendID, friendID, userID)
+	return err
+}
+
+// 获取好友列表
+func (s *sqlStorage) GetFriends(userID string) ([]string, error) {
+	query := `SELECT friend_id FROM friendships WHERE user_id = ?`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var friends []string
+	for rows.Next() {
+		var friendID string
+		if err := rows.Scan(&friendID); err != nil {
+			return nil, err
+		}
+		friends = append(friends, friendID)
+	}
+	return friends, nil
+}
+
+// 检查是否为好友
+func (s *sqlStorage) IsFriend(userID, friendID string) (bool, error) {
+	query := `SELECT COUNT(*) FROM friendships WHERE user_id = ? AND friend_id = ?`
+	var count int
+	err := s.db.QueryRow(query, userID, friendID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ==================== 好友请求相关操作 ====================
+
+// 添加好友请求
+func (s *sqlStorage) AddFriendRequest(fromUserID, toUserID, verifyMsg string) error {
+	query := `INSERT INTO friend_requests (from_user_id, to_user_id, verify_msg) VALUES (?, ?, ?)`
+	_, err := s.db.Exec(query, fromUserID, toUserID, verifyMsg)
+	return err
+}
+
+// 获取收到的好友请求
+func (s *sqlStorage) GetFriendRequests(toUserID string) (map[string]string, error) {
+	query := `SELECT from_user_id, verify_msg FROM friend_requests WHERE to_user_id = ? AND status = 'pending'`
+	rows, err := s.db.Query(query, toUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := make(map[string]string)
+	for rows.Next() {
+		var fromUserID, verifyMsg string
+		if err := rows.Scan(&fromUserID, &verifyMsg); err != nil {
+			return nil, err
+		}
+		requests[fromUserID] = verifyMsg
+	}
+	return requests, nil
+}
+
+// 处理待处理的好友请求，请求不存在或已处理时返回 ErrFriendRequestNotFound
+func (s *sqlStorage) HandleFriendRequest(fromUserID, toUserID string, accept bool) error {
+	status := "rejected"
+	if accept {
+		status = "accepted"
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 只更新待处理的请求，没有收到请求时不能直接添加好友
+	query := `UPDATE friend_requests SET status = ? WHERE from_user_id = ? AND to_user_id = ? AND status = 'pending'`
+	result, err := tx.Exec(query, status, fromUserID, toUserID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrFriendRequestNotFound
+	}
+
+	// 如果接受，添加双向好友关系
+	if accept {
+		query := `INSERT INTO friendships (user_id, friend_id) VALUES (?, ?), (?, ?)`
+		if _, err := tx.Exec(query, fromUserID, toUserID, toUserID, fromUserID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ==================== 好友备注和免打扰相关操作 ====================
+
+// 设置好友备注
+func (s *sqlStorage) SetFriendRemark(userID, friendID, remark string) error {
+	query := `UPDATE friendships SET remark = ? WHERE user_id = ? AND friend_id = ?`
+	_, err := s.db.Exec(query, remark, userID, friendID)
+	return err
+}
+
+// 获取好友备注
+func (s *sqlStorage) GetFriendRemark(userID, friendID string) (string, error) {
+	query := `SELECT remark FROM friendships WHERE user_id = ? AND friend_id = ?`
+	var remark string
+	err := s.db.QueryRow(query, userID, friendID).Scan(&remark)
+	if err != nil {
+		return "", err
+	}
+	return remark, nil
+}
+
+// 设置免打扰
+func (s *sqlStorage) SetFriendDND(userID, friendID string, dnd bool) error {
+	query := `UPDATE friendships SET dnd = ? WHERE user_id = ? AND friend_id = ?`
+	_, err := s.db.Exec(query, dnd, userID, friendID)
+	return err
+}
+
+// 获取免打扰状态
+func (s *sqlStorage) GetFriendDND(userID, friendID string) (bool, error) {
+	query := `SELECT dnd FROM friendships WHERE user_id = ? AND friend_id = ?`
+	var dnd bool
+	err := s.db.QueryRow(query, userID, friendID).Scan(&dnd)
+	if err != nil {
+		return false, err
+	}
+	return dnd, nil
+}
+
+// ==================== 聊天消息相关操作 ====================
+
+// messages 表查询列，prefix 为表别名
+func messageColumns(prefix string) string {
+	cols := []string{"id", "from_user_id", "to_user_id", "group_id", "type", "content", "extra",
+		"filename", "filesize", "mime_type", "timestamp", "recalled", "edited_at", "reply_to_id", "forward_from_id",
+		"created_at"}
+	if prefix != "" {
+		for i, c := range cols {
+			cols[i] = prefix + "." + c
+		}
+	}
+	return strings.Join(cols, ", ")
+}
+
+// 按 messageColumns 的列顺序扫描消息
+func scanMessages(rows *sql.Rows) ([]*Message, error) {
+	var messages []*Message
+	for rows.Next() {
+		msg := &Message{}
+		if err := rows.Scan(&msg.ID, &msg.FromUserID, &msg.ToUserID, &msg.GroupID, &msg.Type, &msg.Content,
+			&msg.Extra, &msg.Filename, &msg.Filesize, &msg.MimeType, &msg.Timestamp, &msg.Recalled, &msg.EditedAt,
+			&msg.ReplyToID, &msg.ForwardFromID, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// 保存消息，并为每个接收者记录一条离线消息，返回消息ID；msg.ID 不为0时使用调用方分配的ID
+func (s *sqlStorage) SaveMessage(msg *Message, recipients []string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO messages (id, from_user_id, to_user_id, group_id, type, content, extra, filename, filesize,
+		mime_type, timestamp, reply_to_id, forward_from_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.Exec(query, nullID(msg.ID), msg.FromUserID, msg.ToUserID, msg.GroupID, msg.Type, msg.Content,
+		msg.Extra, msg.Filename, msg.Filesize, msg.MimeType, msg.Timestamp, msg.ReplyToID, msg.ForwardFromID)
+	if err != nil {
+		return 0, err
+	}
+	id := msg.ID
+	if id == 0 {
+		if id, err = result.LastInsertId(); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, userID := range recipients {
+		if _, err := tx.Exec(s.insertIgnore+` INTO offline_messages (user_id, message_id) VALUES (?, ?)`, userID, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	msg.ID = id
+	return id, nil
+}
+
+// 获取用户的离线消息，按消息ID升序
+func (s *sqlStorage) GetOfflineMessages(userID string) ([]*Message, error) {
+	query := `SELECT ` + messageColumns("m") + `
+		FROM offline_messages o JOIN messages m ON o.message_id = m.id
+		WHERE o.user_id = ? ORDER BY m.id ASC`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMessages(rows)
+}
+
+// 删除离线消息（消息已送达）
+func (s *sqlStorage) RemoveOfflineMessage(userID string, messageID int64) error {
+	query := `DELETE FROM offline_messages WHERE user_id = ? AND message_id = ?`
+	_, err := s.db.Exec(query, userID, messageID)
+	return err
+}
+
+// 分页查询两人之间或群内的聊天记录，结果按消息ID升序
+// 指定 AfterID/AfterTime 且未指定 Before 条件时向后翻页，否则从最新消息向前翻页
+// 多取一条用于判断翻页方向上是否还有更多消息
+func (s *sqlStorage) GetChatHistory(q *HistoryQuery) ([]*Message, bool, error) {
+	var conds []string
+	var args []interface{}
+	if q.GroupID != 0 {
+		conds = append(conds, "group_id = ?")
+		args = append(args, q.GroupID)
+	} else {
+		conds = append(conds, "group_id = 0 AND ((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?))")
+		args = append(args, q.UserID, q.FriendID, q.FriendID, q.UserID)
+	}
+	if q.BeforeID > 0 {
+		conds = append(conds, "id < ?")
+		args = append(args, q.BeforeID)
+	}
+	if q.AfterID > 0 {
+		conds = append(conds, "id > ?")
+		args = append(args, q.AfterID)
+	}
+	if q.BeforeTime > 0 {
+		conds = append(conds, "timestamp < ?")
+		args = append(args, q.BeforeTime)
+	}
+	if q.AfterTime > 0 {
+		conds = append(conds, "timestamp > ?")
+		args = append(args, q.AfterTime)
+	}
+	forward := (q.AfterID > 0 || q.AfterTime > 0) && q.BeforeID == 0 && q.BeforeTime == 0
+	order := "DESC"
+	if forward {
+		order = "ASC"
+	}
+	query := fmt.Sprintf(`SELECT %s FROM messages WHERE %s ORDER BY id %s LIMIT ?`,
+		messageColumns(""), strings.Join(conds, " AND "), order)
+	args = append(args, q.Limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > q.Limit
+	if hasMore {
+		messages = messages[:q.Limit]
+	}
+	if !forward {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, hasMore, nil
+}
+
+// 用户可见的消息条件：与自己相关的单聊消息及所在群的群消息
+const userMessagesCond = `((group_id = 0 AND (to_user_id = ? OR from_user_id = ?))
+		OR group_id IN (SELECT group_id FROM group_members WHERE user_id = ?))`
+
+// 获取用户可见的、ID大于 afterID 的消息，按消息ID升序，用于断线重连后补发
+func (s *sqlStorage) GetMessagesAfter(userID string, afterID int64, limit int) ([]*Message, error) {
+	query := `SELECT ` + messageColumns("") + ` FROM messages
+		WHERE id > ? AND ` + userMessagesCond + ` ORDER BY id ASC LIMIT ?`
+	rows, err := s.db.Query(query, afterID, userID, userID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMessages(rows)
+}
+
+// 转义 LIKE 通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// 按搜索条件查询用户可见的消息，按消息ID倒序，返回本页结果与总数
+// 关键词的匹配方式因数据库而异，由调用方通过 keywordCond 和 keywordArgs 传入
+func (s *sqlStorage) searchMessages(q *SearchQuery, keywordCond string, keywordArgs ...interface{}) ([]*Message, int, error) {
+	conds := []string{userMessagesCond, "recalled = FALSE", keywordCond}
+	args := append([]interface{}{q.UserID, q.UserID, q.UserID}, keywordArgs...)
+	if q.SenderID != "" {
+		conds = append(conds, "from_user_id = ?")
+		args = append(args, q.SenderID)
+	}
+	if q.GroupID != 0 {
+		conds = append(conds, "group_id = ?")
+		args = append(args, q.GroupID)
+	} else if q.FriendID != "" {
+		conds = append(conds, "group_id = 0 AND ((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?))")
+		args = append(args, q.UserID, q.FriendID, q.FriendID, q.UserID)
+	}
+	if q.StartTime > 0 {
+		conds = append(conds, "timestamp >= ?")
+		args = append(args, q.StartTime)
+	}
+	if q.EndTime > 0 {
+		conds = append(conds, "timestamp < ?")
+		args = append(args, q.EndTime)
+	}
+	if len(q.Types) > 0 {
+		placeholders := make([]string, len(q.Types))
+		for i, t := range q.Types {
+			placeholders[i] = "?"
+			args = append(args, t)
+		}
+		conds = append(conds, "type IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	where := strings.Join(conds, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	query := `SELECT ` + messageColumns("") + ` FROM messages WHERE ` + where + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	rows, err := s.db.Query(query, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+// 获取用户可见的最新消息ID，没有消息时返回0
+func (s *sqlStorage) GetLatestMessageID(userID string) (int64, error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM messages WHERE ` + userMessagesCond
+	var id int64
+	err := s.db.QueryRow(query, userID, userID, userID).Scan(&id)
+	return id, err
+}
+
+// 根据ID获取消息
+func (s *sqlStorage) GetMessage(id int64) (*Message, error) {
+	query := `SELECT ` + messageColumns("") + ` FROM messages WHERE id = ?`
+	rows, err := s.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return messages[0], nil
+}
+
+// 根据ID批量获取消息，不存在的ID忽略
+func (s *sqlStorage) GetMessagesByIDs(ids []int64) ([]*Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	query := `SELECT ` + messageColumns("") + ` FROM messages WHERE id IN (` + strings.Join(placeholders, ", ") + `)`
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMessages(rows)
+}
+
+// 撤回消息，清空消息内容并标记为已撤回
+func (s *sqlStorage) RecallMessage(id int64) error {
+	query := `UPDATE messages SET recalled = TRUE, content = '', extra = '', filename = '', filesize = 0, mime_type = ''
+		WHERE id = ?`
+	_, err := s.db.Exec(query, id)
+	return err
+}
+
+// 编辑消息内容
+func (s *sqlStorage) EditMessage(id int64, content string, editedAt int64) error {
+	query := `UPDATE messages SET content = ?, edited_at = ? WHERE id = ? AND recalled = FALSE`
+	_, err := s.db.Exec(query, content, editedAt, id)
+	return err
+}
+
+// ==================== 消息回应相关操作 ====================
+
+// 添加回应，返回是否新增（已回应过同一表情时返回 false）
+func (s *sqlStorage) AddReaction(messageID int64, userID, emoji string) (bool, error) {
+	query := s.insertIgnore + ` INTO message_reactions (message_id, user_id, emoji) VALUES (?, ?, ?)`
+	result, err := s.db.Exec(query, messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// 取消回应，返回是否确有删除
+func (s *sqlStorage) RemoveReaction(messageID int64, userID, emoji string) (bool, error) {
+	query := `DELETE FROM message_reactions WHERE message_id = ? AND user_id = ? AND emoji = ?`
+	result, err := s.db.Exec(query, messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// 批量获取消息的回应汇总: 消息ID -> 按首次回应时间排序的表情列表
+func (s *sqlStorage) GetReactions(messageIDs []int64) (map[int64][]*Reaction, error) {
+	result := make(map[int64][]*Reaction)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+	placeholders := make([]string, len(messageIDs))
+	args := make([]interface{}, len(messageIDs))
+	for i, id := range messageIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	query := `SELECT message_id, emoji, user_id FROM message_reactions
+		WHERE message_id IN (` + strings.Join(placeholders, ", ") + `) ORDER BY created_at, user_id`
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var messageID int64
+		var emoji, userID string
+		if err := rows.Scan(&messageID, &emoji, &userID); err != nil {
+			return nil, err
+		}
+		var reaction *Reaction
+		for _, r := range result[messageID] {
+			if r.Emoji == emoji {
+				reaction = r
+				break
+			}
+		}
+		if reaction == nil {
+			reaction = &Reaction{Emoji: emoji}
+			result[messageID] = append(result[messageID], reaction)
+		}
+		reaction.UserIDs = append(reaction.UserIDs, userID)
+	}
+	return result, rows.Err()
+}
+
+// ==================== 已读相关操作 ====================
+
+// 获取会话已读位置，未读过时返回0
+func (s *sqlStorage) GetReadCursor(userID, peerID string, groupID int64) (int64, error) {
+	if groupID != 0 {
+		peerID = ""
+	}
+	query := `SELECT last_read_id FROM read_cursors WHERE user_id = ? AND peer_id = ? AND group_id = ?`
+	var id int64
+	err := s.db.QueryRow(query, userID, peerID, groupID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// 统计用户每个单聊会话的未读消息数: 好友UID -> 未读数
+func (s *sqlStorage) GetUnreadCounts(userID string) (map[string]int64, error) {
+	query := `SELECT m.from_user_id, COUNT(*) FROM messages m
+		LEFT JOIN read_cursors r ON r.user_id = m.to_user_id AND r.peer_id = m.from_user_id AND r.group_id = 0
+		WHERE m.group_id = 0 AND m.to_user_id = ? AND m.id > COALESCE(r.last_read_id, 0)
+		GROUP BY m.from_user_id`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var peerID string
+		var count int64
+		if err := rows.Scan(&peerID, &count); err != nil {
+			return nil, err
+		}
+		counts[peerID] = count
+	}
+	return counts, rows.Err()
+}
+
+// 统计用户每个群的未读消息数（不含自己发送的消息）: 群ID -> 未读数
+func (s *sqlStorage) GetGroupUnreadCounts(userID string) (map[int64]int64, error) {
+	query := `SELECT m.group_id, COUNT(*) FROM group_members gm
+		JOIN messages m ON m.group_id = gm.group_id
+		LEFT JOIN read_cursors r ON r.user_id = gm.user_id AND r.peer_id = '' AND r.group_id = gm.group_id
+		WHERE gm.user_id = ? AND m.from_user_id <> gm.user_id AND m.id > COALESCE(r.last_read_id, 0)
+		GROUP BY m.group_id`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[int64]int64)
+	for rows.Next() {
+		var groupID, count int64
+		if err := rows.Scan(&groupID, &count); err != nil {
+			return nil, err
+		}
+		counts[groupID] = count
+	}
+	return counts, rows.Err()
+}
+
+// ==================== 在线状态相关操作 ====================
+
+// 获取用户最后在线时间，从未上线时返回0
+func (s *sqlStorage) GetLastSeen(userID string) (int64, error) {
+	query := `SELECT last_seen FROM user_presence WHERE user_id = ?`
+	var lastSeen int64
+	err := s.db.QueryRow(query, userID).Scan(&lastSeen)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return lastSeen, err
+}
+
+// ==================== 令牌相关操作 ====================
+
+// 保存刷新令牌
+func (s *sqlStorage) SaveRefreshToken(t *RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (token_hash, user_id, family_id, expires_at) VALUES (?, ?, ?, ?)`
+	_, err := s.db.Exec(query, t.TokenHash, t.UserID, t.FamilyID, t.ExpiresAt)
+	return err
+}
+
+// 根据哈希获取刷新令牌，不存在时返回 sql.ErrNoRows
+func (s *sqlStorage) GetRefreshToken(tokenHash string) (*RefreshToken, error) {
+	query := `SELECT token_hash, user_id, family_id, expires_at, revoked FROM refresh_tokens WHERE token_hash = ?`
+	t := &RefreshToken{}
+	err := s.db.QueryRow(query, tokenHash).Scan(&t.TokenHash, &t.UserID, &t.FamilyID, &t.ExpiresAt, &t.Revoked)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// 将刷新令牌标记为已使用，返回是否由本次调用标记（并发刷新时只有一个成功）
+func (s *sqlStorage) RevokeRefreshToken(tokenHash string) (bool, error) {
+	result, err := s.db.Exec(`UPDATE refresh_tokens SET revoked = TRUE WHERE token_hash = ? AND revoked = FALSE`, tokenHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// 吊销同一次登录轮换出的全部刷新令牌
+func (s *sqlStorage) RevokeRefreshTokenFamily(familyID string) error {
+	_, err := s.db.Exec(`UPDATE refresh_tokens SET revoked = TRUE WHERE family_id = ?`, familyID)
+	return err
+}
+
+// 吊销用户的全部刷新令牌
+func (s *sqlStorage) RevokeUserRefreshTokens(userID string) error {
+	_, err := s.db.Exec(`UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = ?`, userID)
+	return err
+}
+
+// 获取仍在有效期内的吊销记录
+func (s *sqlStorage) GetRevokedTokens(now int64) ([]*RevokedToken, error) {
+	rows, err := s.db.Query(`SELECT token_id, user_id, revoked_at, expires_at FROM revoked_tokens WHERE expires_at > ?`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*RevokedToken
+	for rows.Next() {
+		t := &RevokedToken{}
+		if err := rows.Scan(&t.TokenID, &t.UserID, &t.RevokedAt, &t.ExpiresAt); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// 清理已过期的刷新令牌和吊销记录
+func (s *sqlStorage) DeleteExpiredTokens(now int64) error {
+	if _, err := s.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at <= ?`, now); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= ?`, now)
+	return err
+}
+
+// ==================== 邮箱验证码相关操作 ====================
+
+// 保存验证码
+func (s *sqlStorage) SaveEmailCode(c *EmailCode) error {
+	query := `INSERT INTO email_codes (email, purpose, code_hash, ip, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := s.db.Exec(query, c.Email, c.Purpose, c.CodeHash, c.IP, c.ExpiresAt, c.CreatedAt)
+	if err != nil {
+		return err
+	}
+	c.ID, err = result.LastInsertId()
+	return err
+}
+
+// 获取邮箱某用途最近发送的验证码，不存在时返回 sql.ErrNoRows
+func (s *sqlStorage) GetLatestEmailCode(email, purpose string) (*EmailCode, error) {
+	query := `SELECT id, email, purpose, code_hash, ip, attempts, used, expires_at, created_at
+		FROM email_codes WHERE email = ? AND purpose = ? ORDER BY id DESC LIMIT 1`
+	c := &EmailCode{}
+	err := s.db.QueryRow(query, email, purpose).Scan(&c.ID, &c.Email, &c.Purpose, &c.CodeHash, &c.IP,
+		&c.Attempts, &c.Used, &c.ExpiresAt, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 验证码校验失败次数加一
+func (s *sqlStorage) IncrEmailCodeAttempts(id int64) error {
+	_, err := s.db.Exec(`UPDATE email_codes SET attempts = attempts + 1 WHERE id = ?`, id)
+	return err
+}
+
+// 将验证码标记为已使用，返回是否由本次调用标记（验证码只能使用一次）
+func (s *sqlStorage) MarkEmailCodeUsed(id int64) (bool, error) {
+	result, err := s.db.Exec(`UPDATE email_codes SET used = TRUE WHERE id = ? AND used = FALSE`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// 统计某时间之后向该邮箱发送的验证码数量
+func (s *sqlStorage) CountEmailCodesByEmail(email string, since int64) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM email_codes WHERE email = ? AND created_at >= ?`, email, since).Scan(&count)
+	return count, err
+}
+
+// 统计某时间之后该IP请求发送的验证码数量
+func (s *sqlStorage) CountEmailCodesByIP(ip string, since int64) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM email_codes WHERE ip = ? AND created_at >= ?`, ip, since).Scan(&count)
+	return count, err
+}
+
+// 清理某时间之前的验证码记录
+func (s *sqlStorage) DeleteEmailCodesBefore(before int64) error {
+	_, err := s.db.Exec(`DELETE FROM email_codes WHERE created_at < ?`, before)
+	return err
+}
+
+// ==================== 两步验证相关操作 ====================
+
+// 获取用户两步验证信息，未设置时返回 sql.ErrNoRows
+func (s *sqlStorage) GetTOTP(userID string) (*TOTPInfo, error) {
+	query := `SELECT user_id, secret, enabled, last_step FROM user_totp WHERE user_id = ?`
+	t := &TOTPInfo{}
+	err := s.db.QueryRow(query, userID).Scan(&t.UserID, &t.Secret, &t.Enabled, &t.LastStep)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// 记录验证通过的时间步，返回是否更新成功（时间步不大于已记录的值时视为重复使用）
+func (s *sqlStorage) UpdateTOTPLastStep(userID string, step int64) (bool, error) {
+	result, err := s.db.Exec(`UPDATE user_totp SET last_step = ? WHERE user_id = ? AND last_step < ?`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// 启用两步验证并替换恢复码
+func (s *sqlStorage) EnableTOTP(userID string, recoveryHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE user_totp SET enabled = TRUE WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, h := range recoveryHashes {
+		if _, err := tx.Exec(`INSERT INTO totp_recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 关闭两步验证，同时删除恢复码
+func (s *sqlStorage) DeleteTOTP(userID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// 使用恢复码，返回恢复码是否有效且未使用
+func (s *sqlStorage) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	result, err := s.db.Exec(`UPDATE totp_recovery_codes SET used = TRUE WHERE user_id = ? AND code_hash = ? AND used = FALSE`,
+		userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// 统计用户剩余可用的恢复码数量
+func (s *sqlStorage) CountRecoveryCodes(userID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM totp_recovery_codes WHERE user_id = ? AND used = FALSE`, userID).Scan(&count)
+	return count, err
+}
+
+// ==================== 登录审计相关操作 ====================
+
+// 记录一次登录尝试
+func (s *sqlStorage) AddLoginAudit(a *LoginAudit) error {
+	query := `INSERT INTO login_audit (user_id, ip, result, reason, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := s.db.Exec(query, a.UserID, a.IP, a.Result, a.Reason, a.CreatedAt)
+	return err
+}
+
+// 统计某时间之后账号的登录失败次数和最后一次失败时间，登录成功后重新计数
+func (s *sqlStorage) GetAccountLoginFailures(userID string, since int64) (int, int64, error) {
+	query := `SELECT COUNT(*), COALESCE(MAX(created_at), 0) FROM login_audit
+		WHERE user_id = ? AND result = ? AND created_at >= ?
+		AND id > COALESCE((SELECT MAX(id) FROM login_audit WHERE user_id = ? AND result = ?), 0)`
+	var count int
+	var lastAt int64
+	err := s.db.QueryRow(query, userID, LoginFailure, since, userID, LoginSuccess).Scan(&count, &lastAt)
+	return count, lastAt, err
+}
+
+// 统计某时间之后该IP的登录失败次数和最后一次失败时间
+func (s *sqlStorage) GetIPLoginFailures(ip string, since int64) (int, int64, error) {
+	query := `SELECT COUNT(*), COALESCE(MAX(created_at), 0) FROM login_audit WHERE ip = ? AND result = ? AND created_at >= ?`
+	var count int
+	var lastAt int64
+	err := s.db.QueryRow(query, ip, LoginFailure, since).Scan(&count, &lastAt)
+	return count, lastAt, err
+}
+
+// 获取账号最近的登录记录
+func (s *sqlStorage) GetLoginAudits(userID string, limit int) ([]*LoginAudit, error) {
+	query := `SELECT id, user_id, ip, result, reason, created_at FROM login_audit
+		WHERE user_id = ? ORDER BY id DESC LIMIT ?`
+	rows, err := s.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var audits []*LoginAudit
+	for rows.Next() {
+		a := &LoginAudit{}
+		if err := rows.Scan(&a.ID, &a.UserID, &a.IP, &a.Result, &a.Reason, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		audits = append(audits, a)
+	}
+	return audits, rows.Err()
+}
+
+// ==================== 角色权限相关操作 ====================
+
+// 获取用户的系统角色
+func (s *sqlStorage) GetUserRoles(userID string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT role FROM user_roles WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// 授予用户系统角色，已有该角色时忽略
+func (s *sqlStorage) AddUserRole(userID, role string, createdAt int64) error {
+	_, err := s.db.Exec(s.insertIgnore+` INTO user_roles (user_id, role, created_at) VALUES (?, ?, ?)`, userID, role, createdAt)
+	return err
+}
+
+// 收回用户系统角色
+func (s *sqlStorage) RemoveUserRole(userID, role string) error {
+	_, err := s.db.Exec(`DELETE FROM user_roles WHERE user_id = ? AND role = ?`, userID, role)
+	return err
+}
+
+// 获取全部角色权限，返回 角色 -> 操作列表
+func (s *sqlStorage) GetRolePermissions() (map[string][]string, error) {
+	rows, err := s.db.Query(`SELECT role, action FROM role_permissions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := make(map[string][]string)
+	for rows.Next() {
+		var role, action string
+		if err := rows.Scan(&role, &action); err != nil {
+			return nil, err
+		}
+		perms[role] = append(perms[role], action)
+	}
+	return perms, rows.Err()
+}
+
+// 权限表为空时写入默认权限，已有数据时不做修改，避免覆盖手动调整过的权限
+func (s *sqlStorage) InitRolePermissions(perms map[string][]string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM role_permissions` + s.forUpdate).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	for role, actions := range perms {
+		for _, action := range actions {
+			if _, err := tx.Exec(`INSERT INTO role_permissions (role, action) VALUES (?, ?)`, role, action); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// ==================== 群组相关操作 ====================
+
+// 创建群，创建者为群主，返回群ID；groupID 为0时由数据库自增分配
+func (s *sqlStorage) CreateGroup(groupID int64, name, ownerID string, memberIDs []string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO chat_groups (id, name, owner_id) VALUES (?, ?, ?)`, nullID(groupID), name, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	if groupID == 0 {
+		if groupID, err = result.LastInsertId(); err != nil {
+			return 0, err
+		}
+	}
+
+	query := s.insertIgnore + ` INTO group_members (group_id, user_id, role) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(query, groupID, ownerID, GroupRoleOwner); err != nil {
+		return 0, err
+	}
+	for _, userID := range memberIDs {
+		if _, err := tx.Exec(query, groupID, userID, GroupRoleMember); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return groupID, nil
+}
+
+// 解散群，删除群及全部成员
+func (s *sqlStorage) DeleteGroup(groupID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM group_members WHERE group_id = ?`, groupID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_groups WHERE id = ?`, groupID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// 获取群信息
+func (s *sqlStorage) GetGroup(groupID int64) (*Group, error) {
+	query := `SELECT id, name, owner_id, created_at, updated_at FROM chat_groups WHERE id = ?`
+	group := &Group{}
+	err := s.db.QueryRow(query, groupID).Scan(&group.ID, &group.Name, &group.OwnerID, &group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// 修改群名称
+func (s *sqlStorage) RenameGroup(groupID int64, name string) error {
+	query := `UPDATE chat_groups SET name = ? WHERE id = ?`
+	_, err := s.db.Exec(query, name, groupID)
+	return err
+}
+
+// 获取用户加入的群
+func (s *sqlStorage) GetUserGroups(userID string) ([]*Group, error) {
+	query := `SELECT g.id, g.name, g.owner_id, g.created_at, g.updated_at
+		FROM group_members gm JOIN chat_groups g ON gm.group_id = g.id
+		WHERE gm.user_id = ? ORDER BY g.id ASC`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*Group
+	for rows.Next() {
+		group := &Group{}
+		if err := rows.Scan(&group.ID, &group.Name, &group.OwnerID, &group.CreatedAt, &group.UpdatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// 添加群成员，已是成员时忽略
+func (s *sqlStorage) AddGroupMember(groupID int64, userID, role string) error {
+	query := s.insertIgnore + ` INTO group_members (group_id, user_id, role) VALUES (?, ?, ?)`
+	_, err := s.db.Exec(query, groupID, userID, role)
+	return err
+}
+
+// 移除群成员
+func (s *sqlStorage) RemoveGroupMember(groupID int64, userID string) error {
+	query := `DELETE FROM group_members WHERE group_id = ? AND user_id = ?`
+	_, err := s.db.Exec(query, groupID, userID)
+	return err
+}
+
+// 获取群成员，不是成员时返回 sql.ErrNoRows
+func (s *sqlStorage) GetGroupMember(groupID int64, userID string) (*GroupMember, error) {
+	query := `SELECT id, group_id, user_id, role, created_at FROM group_members WHERE group_id = ? AND user_id = ?`
+	member := &GroupMember{}
+	err := s.db.QueryRow(query, groupID, userID).Scan(&member.ID, &member.GroupID, &member.UserID, &member.Role, &member.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// 获取全部群成员，群主、管理员在前
+func (s *sqlStorage) GetGroupMembers(groupID int64) ([]*GroupMember, error) {
+	query := `SELECT id, group_id, user_id, role, created_at FROM group_members WHERE group_id = ?
+		ORDER BY CASE role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, id ASC`
+	rows, err := s.db.Query(query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*GroupMember
+	for rows.Next() {
+		member := &GroupMember{}
+		if err := rows.Scan(&member.ID, &member.GroupID, &member.UserID, &member.Role, &member.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// 设置群成员角色
+func (s *sqlStorage) SetGroupMemberRole(groupID int64, userID, role string) error {
+	query := `UPDATE group_members SET role = ? WHERE group_id = ? AND user_id = ?`
+	_, err := s.db.Exec(query, role, groupID, userID)
+	return err
+}
@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLite存储实现，无需单独部署数据库，适合单机部署和开发测试
+type SQLiteStorage struct {
+	sqlStorage
+}
+
+var _ Store = (*SQLiteStorage)(nil)
+
+// 创建SQLite存储实例，并执行未执行的数据库迁移
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	storage, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := storage.migrator().Up(); err != nil {
+		storage.Close()
+		return nil, fmt.Errorf("数据库迁移失败: %v", err)
+	}
+	return storage, nil
+}
+
+// 打开SQLite数据库，数据库文件不存在时自动创建
+func openSQLite(path string) (*SQLiteStorage, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建数据库目录失败: %v", err)
+		}
+	}
+
+	// WAL 模式下读写互不阻塞，写事务开始时即获取写锁，避免并发事务升级锁时死锁
+	dsn := "file:" + path + "?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开SQLite数据库失败: %v", err)
+	}
+	// SQLite 同一时间只允许一个写入者，使用单个连接避免 database is locked 错误
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("SQLite数据库连接测试失败: %v", err)
+	}
+
+	return &SQLiteStorage{sqlStorage{db: db, insertIgnore: "INSERT OR IGNORE"}}, nil
+}
+
+// 初始表结构，与 MySQL 的表结构保持一致
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		uid TEXT UNIQUE NOT NULL,
+		username TEXT NOT NULL,
+		password TEXT NOT NULL,
+		email TEXT UNIQUE NOT NULL COLLATE NOCASE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS friendships (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		friend_id TEXT NOT NULL,
+		remark TEXT DEFAULT '',
+		dnd BOOLEAN DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, friend_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_friendships_friend_id ON friendships (friend_id)`,
+	`CREATE TABLE IF NOT EXISTS friend_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		from_user_id TEXT NOT NULL,
+		to_user_id TEXT NOT NULL,
+		verify_msg TEXT DEFAULT '',
+		status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected')),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (from_user_id, to_user_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_friend_requests_to_user_id ON friend_requests (to_user_id, status)`,
+	`CREATE TABLE IF NOT EXISTS messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		from_user_id TEXT NOT NULL,
+		to_user_id TEXT NOT NULL DEFAULT '',
+		group_id INTEGER NOT NULL DEFAULT 0,
+		type TEXT NOT NULL,
+		content TEXT NOT NULL,
+		extra TEXT DEFAULT '',
+		filename TEXT DEFAULT '',
+		filesize INTEGER DEFAULT 0,
+		mime_type TEXT DEFAULT '',
+		timestamp INTEGER NOT NULL,
+		recalled BOOLEAN NOT NULL DEFAULT FALSE,
+		edited_at INTEGER NOT NULL DEFAULT 0,
+		reply_to_id INTEGER NOT NULL DEFAULT 0,
+		forward_from_id INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_messages_from_to ON messages (from_user_id, to_user_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_messages_to_from ON messages (to_user_id, from_user_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_messages_group_id ON messages (group_id)`,
+	`CREATE TABLE IF NOT EXISTS offline_messages (
+		user_id TEXT NOT NULL,
+		message_id INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, message_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_offline_messages_message_id ON offline_messages (message_id)`,
+	`CREATE TABLE IF NOT EXISTS chat_groups (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		owner_id TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_chat_groups_owner_id ON chat_groups (owner_id)`,
+	`CREATE TABLE IF NOT EXISTS group_members (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		role TEXT DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (group_id, user_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members (user_id)`,
+	`CREATE TABLE IF NOT EXISTS read_cursors (
+		user_id TEXT NOT NULL,
+		peer_id TEXT NOT NULL DEFAULT '',
+		group_id INTEGER NOT NULL DEFAULT 0,
+		last_read_id INTEGER NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, peer_id, group_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS user_presence (
+		user_id TEXT PRIMARY KEY,
+		last_seen INTEGER NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS message_reactions (
+		message_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (message_id, user_id, emoji)
+	)`,
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+		token_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		family_id TEXT NOT NULL,
+		expires_at INTEGER NOT NULL,
+		revoked BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens (expires_at)`,
+	`CREATE TABLE IF NOT EXISTS revoked_tokens (
+		token_id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		revoked_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at)`,
+	`CREATE TABLE IF NOT EXISTS email_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL COLLATE NOCASE,
+		purpose TEXT NOT NULL,
+		code_hash TEXT NOT NULL,
+		ip TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		used BOOLEAN NOT NULL DEFAULT FALSE,
+		expires_at INTEGER NOT NULL,
+		created_at INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_email_codes_email_purpose ON email_codes (email, purpose)`,
+	`CREATE INDEX IF NOT EXISTS idx_email_codes_email_created ON email_codes (email, created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_email_codes_ip_created ON email_codes (ip, created_at)`,
+	`CREATE TABLE IF NOT EXISTS user_totp (
+		user_id TEXT PRIMARY KEY,
+		secret TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		last_step INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+		user_id TEXT NOT NULL,
+		code_hash TEXT NOT NULL,
+		used BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, code_hash)
+	)`,
+	`CREATE TABLE IF NOT EXISTS login_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		ip TEXT NOT NULL DEFAULT '',
+		result TEXT NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_login_audit_user_created ON login_audit (user_id, created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_login_audit_ip_created ON login_audit (ip, created_at)`,
+	`CREATE TABLE IF NOT EXISTS user_roles (
+		user_id TEXT NOT NULL,
+		role TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (user_id, role)
+	)`,
+	`CREATE TABLE IF NOT EXISTS role_permissions (
+		role TEXT NOT NULL,
+		action TEXT NOT NULL,
+		PRIMARY KEY (role, action)
+	)`,
+}
+
+// SQLite 不支持 ON UPDATE CURRENT_TIMESTAMP，用触发器维护 updated_at
+func updatedAtTrigger(table string) string {
+	return fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS trg_%[1]s_updated_at AFTER UPDATE ON %[1]s
+		FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+		BEGIN UPDATE %[1]s SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id; END`, table)
+}
+
+// SQLite 数据库迁移，新增的表结构变更追加到末尾，已发布的迁移不能再修改
+// 版本号与 MySQL 的迁移各自独立
+var sqliteMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "创建初始表结构",
+		Up: execAll(append(sqliteSchema, updatedAtTrigger("users"), updatedAtTrigger("friendships"),
+			updatedAtTrigger("friend_requests"), updatedAtTrigger("chat_groups"))...),
+		Down: dropTables("role_permissions", "user_roles", "login_audit", "totp_recovery_codes", "user_totp",
+			"email_codes", "revoked_tokens", "refresh_tokens", "message_reactions", "user_presence", "read_cursors",
+			"group_members", "chat_groups", "offline_messages", "messages", "friend_requests", "friendships", "users"),
+		Destructive: true,
+	},
+	{
+		Version:     2,
+		Description: "创建ID序列表，并从已有数据的最大ID开始",
+		Up: execAll(
+			`CREATE TABLE IF NOT EXISTS id_sequences (
+				name TEXT PRIMARY KEY,
+				value INTEGER NOT NULL
+			)`,
+			// 只有纯数字且不超过18位的UID才可能与序列分配的UID冲突
+			`INSERT OR IGNORE INTO id_sequences (name, value)
+				SELECT '`+SequenceUser+`', COALESCE(MAX(CAST(uid AS INTEGER)), 0) FROM users
+				WHERE uid != '' AND LENGTH(uid) <= 18 AND uid NOT GLOB '*[^0-9]*'`,
+			`INSERT OR IGNORE INTO id_sequences (name, value)
+				SELECT '`+SequenceMessage+`', COALESCE(MAX(id), 0) FROM messages`,
+			`INSERT OR IGNORE INTO id_sequences (name, value)
+				SELECT '`+SequenceGroup+`', COALESCE(MAX(id), 0) FROM chat_groups`,
+		),
+		Down: dropTables("id_sequences"),
+	},
+}
+
+// 创建迁移执行器，SQLite 的写事务是串行的，迁移事务中会重新检查版本，不需要额外的锁
+func (s *SQLiteStorage) migrator() *Migrator {
+	return newMigrator(s.db, sqliteMigrations, "", "")
+}
+
+// ==================== 聊天消息相关操作 ====================
+
+// 按关键词搜索用户可见的消息，按消息ID倒序，返回本页结果与总数
+// SQLite 没有中文分词，使用 LIKE 做子串匹配
+func (s *SQLiteStorage) SearchMessages(q *SearchQuery) ([]*Message, int, error) {
+	pattern := "%" + escapeLike(q.Keyword) + "%"
+	return s.searchMessages(q, `(content LIKE ? ESCAPE '\' OR extra LIKE ? ESCAPE '\')`, pattern, pattern)
+}
+
+// ==================== 已读相关操作 ====================
+
+// 将会话已读位置推进到 msgID，只前进不后退，返回已读位置是否有变化
+// 单聊传 peerID，群聊传 groupID
+func (s *SQLiteStorage) UpdateReadCursor(userID, peerID string, groupID, msgID int64) (bool, error) {
+	if groupID != 0 {
+		peerID = ""
+	}
+	query := `INSERT INTO read_cursors (user_id, peer_id, group_id, last_read_id) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, peer_id, group_id) DO UPDATE
+		SET last_read_id = excluded.last_read_id, updated_at = CURRENT_TIMESTAMP
+		WHERE excluded.last_read_id > read_cursors.last_read_id`
+	result, err := s.db.Exec(query, userID, peerID, groupID, msgID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	// 插入或推进时返回1，未推进时返回0
+	return rows > 0, nil
+}
+
+// ==================== 在线状态相关操作 ====================
+
+// 记录用户最后在线时间（Unix秒）
+func (s *SQLiteStorage) SetLastSeen(userID string, lastSeen int64) error {
+	query := `INSERT INTO user_presence (user_id, last_seen) VALUES (?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET last_seen = excluded.last_seen, updated_at = CURRENT_TIMESTAMP`
+	_, err := s.db.Exec(query, userID, lastSeen)
+	return err
+}
+
+// ==================== 令牌相关操作 ====================
+
+// 添加吊销记录，重复吊销时更新时间
+func (s *SQLiteStorage) AddRevokedToken(t *RevokedToken) error {
+	query := `INSERT INTO revoked_tokens (token_id, user_id, revoked_at, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (token_id) DO UPDATE SET revoked_at = excluded.revoked_at, expires_at = excluded.expires_at`
+	_, err := s.db.Exec(query, t.TokenID, t.UserID, t.RevokedAt, t.ExpiresAt)
+	return err
+}
+
+// ==================== 两步验证相关操作 ====================
+
+// 保存待确认的两步验证密钥，覆盖之前未确认的密钥
+func (s *SQLiteStorage) SaveTOTPSecret(userID, secret string) error {
+	query := `INSERT INTO user_totp (user_id, secret, enabled, last_step) VALUES (?, ?, FALSE, 0)
+		ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, enabled = FALSE, last_step = 0,
+		updated_at = CURRENT_TIMESTAMP`
+	_, err := s.db.Exec(query, userID, secret)
+	return err
+}
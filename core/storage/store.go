@@ -0,0 +1,369 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// Store 存储后端需要实现的全部操作
+// MySQL、SQLite 和内存存储各自实现该接口，由配置选择使用哪一种
+type Store interface {
+	// 关闭存储
+	Close() error
+
+	// 获取命名序列的下一个值，从1开始递增，重启后和多实例之间都不会重复
+	NextSequence(name string) (int64, error)
+
+	// 创建用户
+	CreateUser(uid, username, password, email string) error
+	// 根据UID获取用户
+	GetUserByUID(uid string) (*User, error)
+	// 根据邮箱获取用户
+	GetUserByEmail(email string) (*User, error)
+	// 更新用户昵称
+	UpdateUsername(uid, newUsername string) error
+	// 更新用户密码
+	UpdatePassword(uid, newPassword string) error
+	// 更新用户邮箱
+	UpdateEmail(uid, newEmail string) error
+	// 删除用户
+	DeleteUser(uid string) error
+
+	// 添加好友关系
+	AddFriendship(userID, friendID string) error
+	// 删除好友关系
+	DeleteFriendship(userID, friendID string) error
+	// 获取好友列表
+	GetFriends(userID string) ([]string, error)
+	// 检查是否为好友
+	IsFriend(userID, friendID string) (bool, error)
+
+	// 添加好友请求
+	AddFriendRequest(fromUserID, toUserID, verifyMsg string) error
+	// 获取收到的好友请求
+	GetFriendRequests(toUserID string) (map[string]string, error)
+	// 处理待处理的好友请求，请求不存在或已处理时返回 ErrFriendRequestNotFound
+	HandleFriendRequest(fromUserID, toUserID string, accept bool) error
+
+	// 设置好友备注
+	SetFriendRemark(userID, friendID, remark string) error
+	// 获取好友备注
+	GetFriendRemark(userID, friendID string) (string, error)
+	// 设置免打扰
+	SetFriendDND(userID, friendID string, dnd bool) error
+	// 获取免打扰状态
+	GetFriendDND(userID, friendID string) (bool, error)
+
+	// 保存消息，并为每个接收者记录一条离线消息，返回消息ID
+	// msg.ID 不为0时使用调用方分配的ID，否则由数据库自增分配
+	SaveMessage(msg *Message, recipients []string) (int64, error)
+	// 获取用户的离线消息，按消息ID升序
+	GetOfflineMessages(userID string) ([]*Message, error)
+	// 删除离线消息（消息已送达）
+	RemoveOfflineMessage(userID string, messageID int64) error
+	// 分页查询两人之间或群内的聊天记录，结果按消息ID升序
+	// 指定 AfterID/AfterTime 且未指定 Before 条件时向后翻页，否则从最新消息向前翻页
+	// 多取一条用于判断翻页方向上是否还有更多消息
+	GetChatHistory(q *HistoryQuery) ([]*Message, bool, error)
+	// 获取用户可见的、ID大于 afterID 的消息，按消息ID升序，用于断线重连后补发
+	GetMessagesAfter(userID string, afterID int64, limit int) ([]*Message, error)
+	// 按关键词搜索用户可见的消息，按消息ID倒序，返回本页结果与总数
+	// 关键词使用 ngram 全文索引做短语匹配，短于分词长度时退化为 LIKE
+	SearchMessages(q *SearchQuery) ([]*Message, int, error)
+	// 获取用户可见的最新消息ID，没有消息时返回0
+	GetLatestMessageID(userID string) (int64, error)
+	// 根据ID获取消息
+	GetMessage(id int64) (*Message, error)
+	// 根据ID批量获取消息，不存在的ID忽略
+	GetMessagesByIDs(ids []int64) ([]*Message, error)
+	// 撤回消息，清空消息内容并标记为已撤回
+	RecallMessage(id int64) error
+	// 编辑消息内容
+	EditMessage(id int64, content string, editedAt int64) error
+
+	// 添加回应，返回是否新增（已回应过同一表情时返回 false）
+	AddReaction(messageID int64, userID, emoji string) (bool, error)
+	// 取消回应，返回是否确有删除
+	RemoveReaction(messageID int64, userID, emoji string) (bool, error)
+	// 批量获取消息的回应汇总: 消息ID -> 按首次回应时间排序的表情列表
+	GetReactions(messageIDs []int64) (map[int64][]*Reaction, error)
+
+	// 将会话已读位置推进到 msgID，只前进不后退，返回已读位置是否有变化
+	// 单聊传 peerID，群聊传 groupID
+	UpdateReadCursor(userID, peerID string, groupID, msgID int64) (bool, error)
+	// 获取会话已读位置，未读过时返回0
+	GetReadCursor(userID, peerID string, groupID int64) (int64, error)
+	// 统计用户每个单聊会话的未读消息数: 好友UID -> 未读数
+	GetUnreadCounts(userID string) (map[string]int64, error)
+	// 统计用户每个群的未读消息数（不含自己发送的消息）: 群ID -> 未读数
+	GetGroupUnreadCounts(userID string) (map[int64]int64, error)
+
+	// 记录用户最后在线时间（Unix秒）
+	SetLastSeen(userID string, lastSeen int64) error
+	// 获取用户最后在线时间，从未上线时返回0
+	GetLastSeen(userID string) (int64, error)
+
+	// 保存刷新令牌
+	SaveRefreshToken(t *RefreshToken) error
+	// 根据哈希获取刷新令牌，不存在时返回 sql.ErrNoRows
+	GetRefreshToken(tokenHash string) (*RefreshToken, error)
+	// 将刷新令牌标记为已使用，返回是否由本次调用标记（并发刷新时只有一个成功）
+	RevokeRefreshToken(tokenHash string) (bool, error)
+	// 吊销同一次登录轮换出的全部刷新令牌
+	RevokeRefreshTokenFamily(familyID string) error
+	// 吊销用户的全部刷新令牌
+	RevokeUserRefreshTokens(userID string) error
+	// 添加吊销记录，重复吊销时更新时间
+	AddRevokedToken(t *RevokedToken) error
+	// 获取仍在有效期内的吊销记录
+	GetRevokedTokens(now int64) ([]*RevokedToken, error)
+	// 清理已过期的刷新令牌和吊销记录
+	DeleteExpiredTokens(now int64) error
+
+	// 保存验证码
+	SaveEmailCode(c *EmailCode) error
+	// 获取邮箱某用途最近发送的验证码，不存在时返回 sql.ErrNoRows
+	GetLatestEmailCode(email, purpose string) (*EmailCode, error)
+	// 验证码校验失败次数加一
+	IncrEmailCodeAttempts(id int64) error
+	// 将验证码标记为已使用，返回是否由本次调用标记（验证码只能使用一次）
+	MarkEmailCodeUsed(id int64) (bool, error)
+	// 统计某时间之后向该邮箱发送的验证码数量
+	CountEmailCodesByEmail(email string, since int64) (int, error)
+	// 统计某时间之后该IP请求发送的验证码数量
+	CountEmailCodesByIP(ip string, since int64) (int, error)
+	// 清理某时间之前的验证码记录
+	DeleteEmailCodesBefore(before int64) error
+
+	// 保存待确认的两步验证密钥，覆盖之前未确认的密钥
+	SaveTOTPSecret(userID, secret string) error
+	// 获取用户两步验证信息，未设置时返回 sql.ErrNoRows
+	GetTOTP(userID string) (*TOTPInfo, error)
+	// 记录验证通过的时间步，返回是否更新成功（时间步不大于已记录的值时视为重复使用）
+	UpdateTOTPLastStep(userID string, step int64) (bool, error)
+	// 启用两步验证并替换恢复码
+	EnableTOTP(userID string, recoveryHashes []string) error
+	// 关闭两步验证，同时删除恢复码
+	DeleteTOTP(userID string) error
+	// 使用恢复码，返回恢复码是否有效且未使用
+	UseRecoveryCode(userID, codeHash string) (bool, error)
+	// 统计用户剩余可用的恢复码数量
+	CountRecoveryCodes(userID string) (int, error)
+
+	// 记录一次登录尝试
+	AddLoginAudit(a *LoginAudit) error
+	// 统计某时间之后账号的登录失败次数和最后一次失败时间，登录成功后重新计数
+	GetAccountLoginFailures(userID string, since int64) (int, int64, error)
+	// 统计某时间之后该IP的登录失败次数和最后一次失败时间
+	GetIPLoginFailures(ip string, since int64) (int, int64, error)
+	// 获取账号最近的登录记录
+	GetLoginAudits(userID string, limit int) ([]*LoginAudit, error)
+
+	// 获取用户的系统角色
+	GetUserRoles(userID string) ([]string, error)
+	// 授予用户系统角色，已有该角色时忽略
+	AddUserRole(userID, role string, createdAt int64) error
+	// 收回用户系统角色
+	RemoveUserRole(userID, role string) error
+	// 获取全部角色权限，返回 角色 -> 操作列表
+	GetRolePermissions() (map[string][]string, error)
+	// 权限表为空时写入默认权限，已有数据时不做修改，避免覆盖手动调整过的权限
+	InitRolePermissions(perms map[string][]string) error
+
+	// 创建群，创建者为群主，返回群ID；groupID 为0时由数据库自增分配
+	CreateGroup(groupID int64, name, ownerID string, memberIDs []string) (int64, error)
+	// 解散群，删除群及全部成员
+	DeleteGroup(groupID int64) error
+	// 获取群信息
+	GetGroup(groupID int64) (*Group, error)
+	// 修改群名称
+	RenameGroup(groupID int64, name string) error
+	// 获取用户加入的群
+	GetUserGroups(userID string) ([]*Group, error)
+	// 添加群成员，已是成员时忽略
+	AddGroupMember(groupID int64, userID, role string) error
+	// 移除群成员
+	RemoveGroupMember(groupID int64, userID string) error
+	// 获取群成员，不是成员时返回 sql.ErrNoRows
+	GetGroupMember(groupID int64, userID string) (*GroupMember, error)
+	// 获取全部群成员，群主、管理员在前
+	GetGroupMembers(groupID int64) ([]*GroupMember, error)
+	// 设置群成员角色
+	SetGroupMemberRole(groupID int64, userID, role string) error
+}
+
+// 要处理的好友请求不存在，或已被接受或拒绝
+var ErrFriendRequestNotFound = errors.New("好友请求不存在或已处理")
+
+// 命名序列，用于分配用户UID、消息ID和群ID
+const (
+	SequenceUser    = "user"
+	SequenceMessage = "message"
+	SequenceGroup   = "group"
+)
+
+// 用户信息表结构
+type User struct {
+	ID        int64     `db:"id"`
+	UID       string    `db:"uid"`
+	Username  string    `db:"username"`
+	Password  string    `db:"password"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// 好友关系表结构
+type Friendship struct {
+	ID        int64     `db:"id"`
+	UserID    string    `db:"user_id"`
+	FriendID  string    `db:"friend_id"`
+	Remark    string    `db:"remark"`
+	DND       bool      `db:"dnd"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// 好友请求表结构
+type FriendRequest struct {
+	ID         int64     `db:"id"`
+	FromUserID string    `db:"from_user_id"`
+	ToUserID   string    `db:"to_user_id"`
+	VerifyMsg  string    `db:"verify_msg"`
+	Status     string    `db:"status"` // pending, accepted, rejected
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// 聊天消息表结构
+type Message struct {
+	ID            int64     `db:"id"`
+	FromUserID    string    `db:"from_user_id"`
+	ToUserID      string    `db:"to_user_id"`
+	GroupID       int64     `db:"group_id"` // 群消息的群ID，单聊为0
+	Type          string    `db:"type"`     // chat, emoji, image, file, group_chat
+	Content       string    `db:"content"`
+	Extra         string    `db:"extra"`
+	Filename      string    `db:"filename"`
+	Filesize      int64     `db:"filesize"`
+	MimeType      string    `db:"mime_type"`
+	Timestamp     int64     `db:"timestamp"`
+	Recalled      bool      `db:"recalled"`        // 已撤回，内容已清空
+	EditedAt      int64     `db:"edited_at"`       // 最后编辑时间（Unix秒），未编辑为0
+	ReplyToID     int64     `db:"reply_to_id"`     // 回复的消息ID
+	ForwardFromID int64     `db:"forward_from_id"` // 转发的原始消息ID
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+// 群组表结构
+type Group struct {
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	OwnerID   string    `db:"owner_id"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// 群成员角色
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleAdmin  = "admin"
+	GroupRoleMember = "member"
+)
+
+// 群成员表结构
+type GroupMember struct {
+	ID        int64     `db:"id"`
+	GroupID   int64     `db:"group_id"`
+	UserID    string    `db:"user_id"`
+	Role      string    `db:"role"` // owner, admin, member
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// 消息搜索条件
+type SearchQuery struct {
+	UserID    string   // 只搜索该用户可见的消息
+	Keyword   string   // 在消息内容和文件名中搜索
+	SenderID  string   // 发送者，为空时不限
+	FriendID  string   // 限定与该好友的单聊
+	GroupID   int64    // 限定该群，不为0时忽略 FriendID
+	StartTime int64    // 消息时间戳下限（含）
+	EndTime   int64    // 消息时间戳上限（不含）
+	Types     []string // 消息类型，为空时不限
+	Offset    int
+	Limit     int
+}
+
+// 消息上某个表情的回应汇总
+type Reaction struct {
+	Emoji   string
+	UserIDs []string // 按回应时间排序
+}
+
+// 聊天记录查询条件
+type HistoryQuery struct {
+	UserID     string
+	FriendID   string
+	GroupID    int64 // 不为0时查询群聊记录，忽略 FriendID
+	BeforeID   int64 // 只返回ID小于该值的消息
+	AfterID    int64 // 只返回ID大于该值的消息
+	BeforeTime int64 // 只返回时间戳早于该值的消息
+	AfterTime  int64 // 只返回时间戳晚于该值的消息
+	Limit      int
+}
+
+// 刷新令牌记录
+type RefreshToken struct {
+	TokenHash string
+	UserID    string
+	FamilyID  string
+	ExpiresAt int64 // Unix秒
+	Revoked   bool
+}
+
+// 吊销记录，TokenID 可以是令牌ID、登录会话ID或用户标识
+type RevokedToken struct {
+	TokenID   string
+	UserID    string
+	RevokedAt int64 // Unix秒
+	ExpiresAt int64 // Unix秒，之后记录不再需要
+}
+
+// 邮箱验证码记录，只保存验证码的哈希
+type EmailCode struct {
+	ID        int64
+	Email     string
+	Purpose   string // 用途，如 register、reset_pwd、change_email
+	CodeHash  string
+	IP        string // 请求发送的客户端IP
+	Attempts  int    // 已校验失败次数
+	Used      bool
+	ExpiresAt int64 // Unix秒
+	CreatedAt int64 // Unix秒
+}
+
+// 用户两步验证信息
+type TOTPInfo struct {
+	UserID   string
+	Secret   string // Base32编码的密钥
+	Enabled  bool
+	LastStep int64 // 最近一次验证通过的时间步，防止同一验证码重复使用
+}
+
+// 登录结果
+const (
+	LoginSuccess   = "success"   // 登录成功并签发令牌
+	LoginFailure   = "failure"   // 密码或两步验证码错误，计入失败次数
+	LoginBlocked   = "blocked"   // 因锁定或尝试过快被拒绝，不计入失败次数
+	LoginChallenge = "challenge" // 密码正确，等待两步验证
+)
+
+// 登录审计记录
+type LoginAudit struct {
+	ID        int64
+	UserID    string
+	IP        string
+	Result    string // 见 LoginSuccess 等常量
+	Reason    string
+	CreatedAt int64 // Unix秒
+}
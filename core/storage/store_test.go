@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// 在内存和 SQLite 存储上分别运行同一组测试，保证两种实现行为一致
+func forEachStore(t *testing.T, fn func(t *testing.T, s Store)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) Store
+	}{
+		{"memory", func(t *testing.T) Store { return NewMemoryStorage() }},
+		{"sqlite", func(t *testing.T) Store {
+			s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "im.db"))
+			if err != nil {
+				t.Fatalf("打开SQLite存储失败: %v", err)
+			}
+			return s
+		}},
+	}
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			s := b.open(t)
+			defer s.Close()
+			fn(t, s)
+		})
+	}
+}
+
+func mustCreateUser(t *testing.T, s Store, uid string) {
+	t.Helper()
+	if err := s.CreateUser(uid, "user"+uid, "hash", uid+"@example.com"); err != nil {
+		t.Fatalf("创建用户 %s 失败: %v", uid, err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		mustCreateUser(t, s, "1")
+		if err := s.CreateUser("1", "dup", "hash", "other@example.com"); err == nil {
+			t.Error("重复的UID应创建失败")
+		}
+		if err := s.CreateUser("2", "dup", "hash", "1@example.com"); err == nil {
+			t.Error("重复的邮箱应创建失败")
+		}
+		if _, err := s.GetUserByUID("missing"); err != sql.ErrNoRows {
+			t.Errorf("查询不存在的用户应返回 sql.ErrNoRows，实际为 %v", err)
+		}
+		if err := s.UpdateUsername("1", "alice"); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.GetUserByEmail("1@example.com")
+		if err != nil || u.UID != "1" || u.Username != "alice" {
+			t.Errorf("GetUserByEmail = %+v, %v", u, err)
+		}
+	})
+}
+
+func TestHandleFriendRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		sendFirst  bool
+		accept     bool
+		wantErr    error
+		wantFriend bool
+	}{
+		{"没有请求时接受", false, true, ErrFriendRequestNotFound, false},
+		{"没有请求时拒绝", false, false, ErrFriendRequestNotFound, false},
+		{"接受待处理的请求", true, true, nil, true},
+		{"拒绝待处理的请求", true, false, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, s Store) {
+				mustCreateUser(t, s, "1")
+				mustCreateUser(t, s, "2")
+				if tt.sendFirst {
+					if err := s.AddFriendRequest("1", "2", "hi"); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := s.HandleFriendRequest("1", "2", tt.accept); err != tt.wantErr {
+					t.Fatalf("HandleFriendRequest 返回 %v，期望 %v", err, tt.wantErr)
+				}
+				for _, pair := range [][2]string{{"1", "2"}, {"2", "1"}} {
+					if ok, err := s.IsFriend(pair[0], pair[1]); err != nil || ok != tt.wantFriend {
+						t.Errorf("IsFriend(%s, %s) = %v, %v，期望 %v", pair[0], pair[1], ok, err, tt.wantFriend)
+					}
+				}
+				// 已处理的请求不能再次处理
+				if tt.sendFirst {
+					if err := s.HandleFriendRequest("1", "2", true); err != ErrFriendRequestNotFound {
+						t.Errorf("重复处理请求返回 %v，期望 ErrFriendRequestNotFound", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestNextSequence(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		for want := int64(1); want <= 3; want++ {
+			if got, err := s.NextSequence(SequenceUser); err != nil || got != want {
+				t.Fatalf("NextSequence(user) = %d, %v，期望 %d", got, err, want)
+			}
+		}
+		// 各序列互不影响
+		if got, err := s.NextSequence(SequenceMessage); err != nil || got != 1 {
+			t.Errorf("NextSequence(message) = %d, %v，期望 1", got, err)
+		}
+	})
+}
+
+func TestSaveMessage(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		save := func(id int64, content string) (int64, error) {
+			return s.SaveMessage(&Message{ID: id, FromUserID: "1", ToUserID: "2", Type: "chat", Content: content,
+				Timestamp: 1}, []string{"2"})
+		}
+		first, err := save(0, "auto")
+		if err != nil || first == 0 {
+			t.Fatalf("自增ID保存失败: %d, %v", first, err)
+		}
+		if id, err := save(100, "explicit"); err != nil || id != 100 {
+			t.Fatalf("指定ID保存 = %d, %v，期望 100", id, err)
+		}
+		if _, err := save(100, "duplicate"); err == nil {
+			t.Error("重复的消息ID应保存失败")
+		}
+		if id, err := save(50, "middle"); err != nil || id != 50 {
+			t.Fatalf("指定ID保存 = %d, %v，期望 50", id, err)
+		}
+		// 自增ID从当前最大值继续
+		if id, err := save(0, "next"); err != nil || id != 101 {
+			t.Errorf("自增ID = %d, %v，期望 101", id, err)
+		}
+
+		history, hasMore, err := s.GetChatHistory(&HistoryQuery{UserID: "2", FriendID: "1", Limit: 10})
+		if err != nil || hasMore {
+			t.Fatalf("GetChatHistory: hasMore=%v, %v", hasMore, err)
+		}
+		want := []int64{first, 50, 100, 101}
+		if len(history) != len(want) {
+			t.Fatalf("聊天记录有 %d 条，期望 %d 条", len(history), len(want))
+		}
+		for i, m := range history {
+			if m.ID != want[i] {
+				t.Errorf("第 %d 条消息ID为 %d，期望 %d", i, m.ID, want[i])
+			}
+		}
+
+		offline, err := s.GetOfflineMessages("2")
+		if err != nil || len(offline) != len(want) {
+			t.Fatalf("离线消息 %d 条, %v，期望 %d 条", len(offline), err, len(want))
+		}
+		if err := s.RemoveOfflineMessage("2", 100); err != nil {
+			t.Fatal(err)
+		}
+		if offline, _ := s.GetOfflineMessages("2"); len(offline) != len(want)-1 {
+			t.Errorf("删除后离线消息 %d 条，期望 %d 条", len(offline), len(want)-1)
+		}
+	})
+}
+
+func TestCreateGroup(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		auto, err := s.CreateGroup(0, "auto", "1", []string{"2"})
+		if err != nil || auto == 0 {
+			t.Fatalf("自增群ID创建失败: %d, %v", auto, err)
+		}
+		if id, err := s.CreateGroup(42, "explicit", "1", nil); err != nil || id != 42 {
+			t.Fatalf("指定群ID创建 = %d, %v，期望 42", id, err)
+		}
+		if _, err := s.CreateGroup(42, "duplicate", "1", nil); err == nil {
+			t.Error("重复的群ID应创建失败")
+		}
+		members, err := s.GetGroupMembers(auto)
+		if err != nil || len(members) != 2 || members[0].UserID != "1" || members[0].Role != GroupRoleOwner {
+			t.Errorf("群成员 = %+v, %v", members, err)
+		}
+	})
+}
+
+func TestRefreshTokenFamily(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		for _, hash := range []string{"a", "b"} {
+			if err := s.SaveRefreshToken(&RefreshToken{TokenHash: hash, UserID: "1", FamilyID: "f", ExpiresAt: 1 << 40}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// 令牌只能被使用一次
+		if ok, err := s.RevokeRefreshToken("a"); err != nil || !ok {
+			t.Fatalf("首次使用令牌 = %v, %v", ok, err)
+		}
+		if ok, err := s.RevokeRefreshToken("a"); err != nil || ok {
+			t.Errorf("重复使用令牌 = %v, %v，期望 false", ok, err)
+		}
+		if err := s.RevokeRefreshTokenFamily("f"); err != nil {
+			t.Fatal(err)
+		}
+		if tok, err := s.GetRefreshToken("b"); err != nil || !tok.Revoked {
+			t.Errorf("吊销令牌族后 b = %+v, %v", tok, err)
+		}
+	})
+}
+
+func TestTOTPReplayAndRecoveryCodes(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		mustCreateUser(t, s, "1")
+		if err := s.SaveTOTPSecret("1", "SECRET"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.EnableTOTP("1", []string{"r1", "r2"}); err != nil {
+			t.Fatal(err)
+		}
+		steps := []struct {
+			step int64
+			want bool
+		}{{10, true}, {10, false}, {9, false}, {11, true}}
+		for _, st := range steps {
+			if ok, err := s.UpdateTOTPLastStep("1", st.step); err != nil || ok != st.want {
+				t.Errorf("UpdateTOTPLastStep(%d) = %v, %v，期望 %v", st.step, ok, err, st.want)
+			}
+		}
+		codes := []struct {
+			hash string
+			want bool
+		}{{"r1", true}, {"r1", false}, {"unknown", false}}
+		for _, c := range codes {
+			if ok, err := s.UseRecoveryCode("1", c.hash); err != nil || ok != c.want {
+				t.Errorf("UseRecoveryCode(%s) = %v, %v，期望 %v", c.hash, ok, err, c.want)
+			}
+		}
+		if n, err := s.CountRecoveryCodes("1"); err != nil || n != 1 {
+			t.Errorf("剩余恢复码 %d, %v，期望 1", n, err)
+		}
+	})
+}
+
+func TestUninitializedManager(t *testing.T) {
+	sm := &StorageManager{Store: uninitializedStore{}}
+	if _, err := sm.GetUserByUID("1"); err != ErrNotInitialized {
+		t.Errorf("初始化前访问存储返回 %v，期望 ErrNotInitialized", err)
+	}
+	if err := sm.Close(); err != nil {
+		t.Errorf("初始化前关闭存储返回 %v", err)
+	}
+}
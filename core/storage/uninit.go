@@ -0,0 +1,331 @@
+package storage
+
+// 存储后端初始化前使用的占位实现，全部操作返回 ErrNotInitialized
+// 避免在调用 StorageManager.Init 之前访问存储时发生空指针错误
+type uninitializedStore struct{}
+
+var _ Store = uninitializedStore{}
+
+func (uninitializedStore) Close() error {
+	return nil
+}
+
+func (uninitializedStore) NextSequence(name string) (int64, error) {
+	return 0, ErrNotInitialized
+}
+
+func (uninitializedStore) CreateUser(uid, username, password, email string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetUserByUID(uid string) (*User, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) GetUserByEmail(email string) (*User, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) UpdateUsername(uid, newUsername string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) UpdatePassword(uid, newPassword string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) UpdateEmail(uid, newEmail string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) DeleteUser(uid string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) AddFriendship(userID, friendID string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) DeleteFriendship(userID, friendID string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetFriends(userID string) ([]string, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) IsFriend(userID, friendID string) (bool, error) {
+	return false, ErrNotInitialized
+}
+
+func (uninitializedStore) AddFriendRequest(fromUserID, toUserID, verifyMsg string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetFriendRequests(toUserID string) (map[string]string, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) HandleFriendRequest(fromUserID, toUserID string, accept bool) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) SetFriendRemark(userID, friendID, remark string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetFriendRemark(userID, friendID string) (string, error) {
+	return "", ErrNotInitialized
+}
+
+func (uninitializedStore) SetFriendDND(userID, friendID string, dnd bool) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetFriendDND(userID, friendID string) (bool, error) {
+	return false, ErrNotInitialized
+}
+
+func (uninitializedStore) SaveMessage(msg *Message, recipients []string) (int64, error) {
+	return 0, ErrNotInitialized
+}
+
+func (uninitializedStore) GetOfflineMessages(userID string) ([]*Message, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) RemoveOfflineMessage(userID string, messageID int64) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetChatHistory(q *HistoryQuery) ([]*Message, bool, error) {
+	return nil, false, ErrNotInitialized
+}
+
+func (uninitializedStore) GetMessagesAfter(userID string, afterID int64, limit int) ([]*Message, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) SearchMessages(q *SearchQuery) ([]*Message, int, error) {
+	return nil, 0, ErrNotInitialized
+}
+
+func (uninitializedStore) GetLatestMessageID(userID string) (int64, error) {
+	return 0, ErrNotInitialized
+}
+
+func (uninitializedStore) GetMessage(id int64) (*Message, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) GetMessagesByIDs(ids []int64) ([]*Message, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) RecallMessage(id int64) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) EditMessage(id int64, content string, editedAt int64) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) AddReaction(messageID int64, userID, emoji string) (bool, error) {
+	return false, ErrNotInitialized
+}
+
+func (uninitializedStore) RemoveReaction(messageID int64, userID, emoji string) (bool, error) {
+	return false, ErrNotInitialized
+}
+
+func (uninitializedStore) GetReactions(messageIDs []int64) (map[int64][]*Reaction, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) UpdateReadCursor(userID, peerID string, groupID, msgID int64) (bool, error) {
+	return false, ErrNotInitialized
+}
+
+func (uninitializedStore) GetReadCursor(userID, peerID string, groupID int64) (int64, error) {
+	return 0, ErrNotInitialized
+}
+
+func (uninitializedStore) GetUnreadCounts(userID string) (map[string]int64, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) GetGroupUnreadCounts(userID string) (map[int64]int64, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) SetLastSeen(userID string, lastSeen int64) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetLastSeen(userID string) (int64, error) {
+	return 0, ErrNotInitialized
+}
+
+func (uninitializedStore) SaveRefreshToken(t *RefreshToken) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetRefreshToken(tokenHash string) (*RefreshToken, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) RevokeRefreshToken(tokenHash string) (bool, error) {
+	return false, ErrNotInitialized
+}
+
+func (uninitializedStore) RevokeRefreshTokenFamily(familyID string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) RevokeUserRefreshTokens(userID string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) AddRevokedToken(t *RevokedToken) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetRevokedTokens(now int64) ([]*RevokedToken, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) DeleteExpiredTokens(now int64) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) SaveEmailCode(c *EmailCode) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetLatestEmailCode(email, purpose string) (*EmailCode, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) IncrEmailCodeAttempts(id int64) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) MarkEmailCodeUsed(id int64) (bool, error) {
+	return false, ErrNotInitialized
+}
+
+func (uninitializedStore) CountEmailCodesByEmail(email string, since int64) (int, error) {
+	return 0, ErrNotInitialized
+}
+
+func (uninitializedStore) CountEmailCodesByIP(ip string, since int64) (int, error) {
+	return 0, ErrNotInitialized
+}
+
+func (uninitializedStore) DeleteEmailCodesBefore(before int64) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) SaveTOTPSecret(userID, secret string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetTOTP(userID string) (*TOTPInfo, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) UpdateTOTPLastStep(userID string, step int64) (bool, error) {
+	return false, ErrNotInitialized
+}
+
+func (uninitializedStore) EnableTOTP(userID string, recoveryHashes []string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) DeleteTOTP(userID string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	return false, ErrNotInitialized
+}
+
+func (uninitializedStore) CountRecoveryCodes(userID string) (int, error) {
+	return 0, ErrNotInitialized
+}
+
+func (uninitializedStore) AddLoginAudit(a *LoginAudit) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetAccountLoginFailures(userID string, since int64) (int, int64, error) {
+	return 0, 0, ErrNotInitialized
+}
+
+func (uninitializedStore) GetIPLoginFailures(ip string, since int64) (int, int64, error) {
+	return 0, 0, ErrNotInitialized
+}
+
+func (uninitializedStore) GetLoginAudits(userID string, limit int) ([]*LoginAudit, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) GetUserRoles(userID string) ([]string, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) AddUserRole(userID, role string, createdAt int64) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) RemoveUserRole(userID, role string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetRolePermissions() (map[string][]string, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) InitRolePermissions(perms map[string][]string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) CreateGroup(groupID int64, name, ownerID string, memberIDs []string) (int64, error) {
+	return 0, ErrNotInitialized
+}
+
+func (uninitializedStore) DeleteGroup(groupID int64) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetGroup(groupID int64) (*Group, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) RenameGroup(groupID int64, name string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetUserGroups(userID string) ([]*Group, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) AddGroupMember(groupID int64, userID, role string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) RemoveGroupMember(groupID int64, userID string) error {
+	return ErrNotInitialized
+}
+
+func (uninitializedStore) GetGroupMember(groupID int64, userID string) (*GroupMember, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) GetGroupMembers(groupID int64) ([]*GroupMember, error) {
+	return nil, ErrNotInitialized
+}
+
+func (uninitializedStore) SetGroupMemberRole(groupID int64, userID, role string) error {
+	return ErrNotInitialized
+}